@@ -0,0 +1,104 @@
+package hetzner
+
+import (
+	"sync"
+	"time"
+
+	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/instancegroup"
+)
+
+const (
+	breakerMinCooldown = 30 * time.Second
+	breakerMaxCooldown = 15 * time.Minute
+)
+
+// circuitBreaker stops the instance creation after capacity errors, for example when
+// the project reached its server limit or when all the server types are unavailable.
+//
+// After a capacity error, the breaker opens for a cool-down period, doubled after each
+// consecutive capacity error. Once the cool-down period is over, a single instance is
+// created to probe whether the capacity recovered.
+//
+// The capacity reported to the autoscaler is lowered by the number of instances
+// returned by Increase. The ProviderInfo.MaxSize is only read by the autoscaler on Init,
+// and cannot be lowered while the breaker is open.
+//
+// The zero value is ready to use, a nil breaker never opens.
+type circuitBreaker struct {
+	mu sync.Mutex
+
+	// now is used in tests to control the time.
+	now func() time.Time
+
+	failures  int
+	openUntil time.Time
+}
+
+func (b *circuitBreaker) timeNow() time.Time {
+	if b.now != nil {
+		return b.now()
+	}
+	return time.Now()
+}
+
+// allow returns the number of instances that may be created, and the remaining
+// cool-down period when the breaker is open.
+func (b *circuitBreaker) allow(delta int) (int, time.Duration) {
+	if b == nil {
+		return delta, 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures == 0 {
+		return delta, 0
+	}
+
+	if remaining := b.openUntil.Sub(b.timeNow()); remaining > 0 {
+		return 0, remaining
+	}
+
+	// Probe with a single instance.
+	return min(delta, 1), 0
+}
+
+// record updates the breaker with the result of an instance creation. It returns the
+// cool-down period when the breaker opened.
+func (b *circuitBreaker) record(created int, err error) time.Duration {
+	if b == nil {
+		return 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !isCapacityError(err) {
+		if created > 0 {
+			b.failures = 0
+		}
+		return 0
+	}
+
+	b.failures++
+
+	cooldown := breakerMaxCooldown
+	if b.failures < 16 {
+		cooldown = min(breakerMaxCooldown, breakerMinCooldown<<(b.failures-1))
+	}
+	b.openUntil = b.timeNow().Add(cooldown)
+
+	return cooldown
+}
+
+// isCapacityError returns whether an instance failed to be created because of a lack
+// of capacity.
+func isCapacityError(err error) bool {
+	for _, instanceErr := range instancegroup.InstanceErrors(err) {
+		switch instanceErr.Cause {
+		case instancegroup.CauseLimitExceeded, instancegroup.CauseUnavailable:
+			return true
+		}
+	}
+	return false
+}
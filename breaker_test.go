@@ -0,0 +1,68 @@
+package hetzner
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/instancegroup"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := &circuitBreaker{now: func() time.Time { return now }}
+
+	limitErr := errors.Join(&instancegroup.InstanceError{
+		Name: "fleeting-a", Cause: instancegroup.CauseLimitExceeded, Err: fmt.Errorf("limit"),
+	})
+	transientErr := errors.Join(&instancegroup.InstanceError{
+		Name: "fleeting-a", Cause: instancegroup.CauseTransient, Err: fmt.Errorf("transient"),
+	})
+
+	// Closed
+	allowed, _ := b.allow(5)
+	require.Equal(t, 5, allowed)
+	require.Equal(t, time.Duration(0), b.record(2, transientErr))
+
+	// Opened
+	require.Equal(t, 30*time.Second, b.record(2, limitErr))
+
+	allowed, remaining := b.allow(5)
+	require.Equal(t, 0, allowed)
+	require.Equal(t, 30*time.Second, remaining)
+
+	// Probe failed, cool-down doubled
+	now = now.Add(30 * time.Second)
+	allowed, _ = b.allow(5)
+	require.Equal(t, 1, allowed)
+	require.Equal(t, time.Minute, b.record(0, limitErr))
+
+	now = now.Add(59 * time.Second)
+	allowed, _ = b.allow(5)
+	require.Equal(t, 0, allowed)
+
+	// Probe succeeded, closed
+	now = now.Add(time.Second)
+	allowed, _ = b.allow(5)
+	require.Equal(t, 1, allowed)
+	require.Equal(t, time.Duration(0), b.record(1, nil))
+
+	allowed, _ = b.allow(5)
+	require.Equal(t, 5, allowed)
+
+	// Cool-down is capped
+	for range 20 {
+		b.record(0, limitErr)
+	}
+	_, remaining = b.allow(5)
+	require.Equal(t, breakerMaxCooldown, remaining)
+
+	// Nil breaker
+	var nilBreaker *circuitBreaker
+	allowed, _ = nilBreaker.allow(5)
+	require.Equal(t, 5, allowed)
+	require.Equal(t, time.Duration(0), nilBreaker.record(0, limitErr))
+}
@@ -0,0 +1,30 @@
+package hetzner
+
+import (
+	"context"
+
+	"github.com/hashicorp/go-hclog"
+	"gitlab.com/gitlab-org/fleeting/fleeting/provider"
+
+	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/instancegroup"
+)
+
+// Check validates the plugin config and resolves the configured resources using the
+// API, without creating any resource. Unlike [InstanceGroup.Init], the connector SSH
+// key is not uploaded.
+func (g *InstanceGroup) Check(ctx context.Context, log hclog.Logger, settings provider.Settings) (*instancegroup.Report, error) {
+	if err := g.Open(ctx, log, settings); err != nil {
+		return nil, err
+	}
+
+	report, err := g.group.Report(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !g.windowsEnabled() && g.settings.UseStaticCredentials && len(g.settings.Key) == 0 {
+		report.Warnings = append(report.Warnings, "connector uses static credentials without a key, no connector ssh key will be added to the instances")
+	}
+
+	return report, nil
+}
@@ -0,0 +1,126 @@
+package hetzner
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/gitlab-org/fleeting/fleeting/provider"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/exp/mockutil"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/schema"
+
+	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/testutils"
+)
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		name     string
+		requests []mockutil.Request
+		run      func(t *testing.T, group *InstanceGroup, settings provider.Settings)
+	}{
+		{name: "success",
+			requests: []mockutil.Request{
+				testutils.GetLocationHel1Request,
+				testutils.GetServerTypeCPX11Request,
+				testutils.GetImageDebian12Request,
+				{Method: "GET", Path: "/ssh_keys?name=admin",
+					Status: 200,
+					JSON: schema.SSHKeyListResponse{
+						SSHKeys: []schema.SSHKey{{ID: 2, Name: "admin"}},
+					},
+				},
+				{Method: "GET", Path: "/datacenters?page=1&per_page=50",
+					Status: 200,
+					JSON: schema.DatacenterListResponse{
+						Datacenters: []schema.Datacenter{
+							{ID: 3, Name: "hel1-dc2",
+								Location:    schema.Location{ID: 3, Name: "hel1"},
+								ServerTypes: schema.DatacenterServerTypes{Available: []int64{1}},
+							},
+						},
+					},
+				},
+			},
+			run: func(t *testing.T, group *InstanceGroup, settings provider.Settings) {
+				group.SSHKeys = []string{"admin"}
+
+				report, err := group.Check(context.Background(), hclog.NewNullLogger(), settings)
+				require.NoError(t, err)
+				require.Equal(t, "hel1", report.Location.Name)
+				require.Equal(t, "admin", report.SSHKeys[0].Name)
+				require.Empty(t, report.Warnings)
+			},
+		},
+		{name: "static credentials without key",
+			requests: []mockutil.Request{
+				testutils.GetLocationHel1Request,
+				testutils.GetServerTypeCPX11Request,
+				testutils.GetImageDebian12Request,
+				{Method: "GET", Path: "/datacenters?page=1&per_page=50",
+					Status: 200,
+					JSON: schema.DatacenterListResponse{
+						Datacenters: []schema.Datacenter{
+							{ID: 3, Name: "hel1-dc2",
+								Location:    schema.Location{ID: 3, Name: "hel1"},
+								ServerTypes: schema.DatacenterServerTypes{Available: []int64{1}},
+							},
+						},
+					},
+				},
+			},
+			run: func(t *testing.T, group *InstanceGroup, settings provider.Settings) {
+				settings.UseStaticCredentials = true
+
+				report, err := group.Check(context.Background(), hclog.NewNullLogger(), settings)
+				require.NoError(t, err)
+				require.Equal(t, []string{
+					"connector uses static credentials without a key, no connector ssh key will be added to the instances",
+				}, report.Warnings)
+			},
+		},
+		{name: "invalid config",
+			requests: []mockutil.Request{},
+			run: func(t *testing.T, group *InstanceGroup, settings provider.Settings) {
+				group.Image = ""
+
+				_, err := group.Check(context.Background(), hclog.NewNullLogger(), settings)
+				require.EqualError(t, err, "missing required plugin config: image")
+			},
+		},
+		{name: "server type not found",
+			requests: []mockutil.Request{
+				testutils.GetLocationHel1Request,
+				{Method: "GET", Path: "/server_types?name=cpx11",
+					Status: 200,
+					JSON:   schema.ServerTypeListResponse{ServerTypes: []schema.ServerType{}},
+				},
+			},
+			run: func(t *testing.T, group *InstanceGroup, settings provider.Settings) {
+				_, err := group.Check(context.Background(), hclog.NewNullLogger(), settings)
+				require.EqualError(t, err, "server type not found: cpx11")
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Setenv("HCLOUD_TOKEN", "")
+			t.Setenv("HCLOUD_ENDPOINT", "")
+
+			server := httptest.NewServer(mockutil.Handler(t, testCase.requests))
+
+			group := &InstanceGroup{
+				Name:        "fleeting",
+				Token:       "dummy",
+				Endpoint:    server.URL,
+				Location:    "hel1",
+				ServerTypes: []string{"cpx11"},
+				Image:       "debian-12",
+			}
+
+			testCase.run(t, group, provider.Settings{})
+		})
+	}
+}
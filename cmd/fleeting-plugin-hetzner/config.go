@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+	"gitlab.com/gitlab-org/fleeting/fleeting/provider"
+
+	hetzner "gitlab.com/hetznercloud/fleeting-plugin-hetzner"
+)
+
+// pluginConfig holds a plugin config loaded from a file.
+type pluginConfig struct {
+	// Runner is the name of the runner the config was loaded from, empty when loaded
+	// from a JSON plugin config.
+	Runner string
+
+	Group    *hetzner.InstanceGroup
+	Settings provider.Settings
+
+	// UseExternalAddr is the runner connector config use_external_addr.
+	UseExternalAddr bool
+}
+
+// runnerConfig is the subset of the gitlab-runner config.toml relevant to the plugin.
+type runnerConfig struct {
+	Runners []struct {
+		Name       string `toml:"name"`
+		Autoscaler *struct {
+			Plugin          string          `toml:"plugin"`
+			PluginConfig    map[string]any  `toml:"plugin_config"`
+			ConnectorConfig connectorConfig `toml:"connector_config"`
+		} `toml:"autoscaler"`
+	} `toml:"runners"`
+}
+
+type connectorConfig struct {
+	OS                   string `toml:"os"`
+	Arch                 string `toml:"arch"`
+	Protocol             string `toml:"protocol"`
+	ProtocolPort         int    `toml:"protocol_port"`
+	Username             string `toml:"username"`
+	Password             string `toml:"password"`
+	KeyPath              string `toml:"key_path"`
+	UseStaticCredentials bool   `toml:"use_static_credentials"`
+	UseExternalAddr      bool   `toml:"use_external_addr"`
+}
+
+// loadConfigs loads the plugin configs from a gitlab-runner config.toml file, or from
+// a JSON file containing a single plugin config. When runner is not empty, only the
+// config of the runner with this name is returned.
+func loadConfigs(path, runner string) ([]pluginConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read config file: %w", err)
+	}
+
+	if filepath.Ext(path) == ".json" {
+		group := &hetzner.InstanceGroup{}
+		if err := json.Unmarshal(data, group); err != nil {
+			return nil, fmt.Errorf("could not parse plugin config: %w", err)
+		}
+		return []pluginConfig{{Group: group}}, nil
+	}
+
+	var config runnerConfig
+	if err := toml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("could not parse runner config: %w", err)
+	}
+
+	result := make([]pluginConfig, 0, len(config.Runners))
+	for _, r := range config.Runners {
+		if r.Autoscaler == nil || r.Autoscaler.PluginConfig == nil {
+			continue
+		}
+		if runner != "" && r.Name != runner {
+			continue
+		}
+
+		// The plugin config is passed to the plugin as JSON by gitlab-runner.
+		pluginData, err := json.Marshal(r.Autoscaler.PluginConfig)
+		if err != nil {
+			return nil, fmt.Errorf("could not encode plugin config of runner %q: %w", r.Name, err)
+		}
+
+		group := &hetzner.InstanceGroup{}
+		if err := json.Unmarshal(pluginData, group); err != nil {
+			return nil, fmt.Errorf("could not parse plugin config of runner %q: %w", r.Name, err)
+		}
+
+		connector := r.Autoscaler.ConnectorConfig
+		settings := provider.Settings{
+			ConnectorConfig: provider.ConnectorConfig{
+				OS:                   connector.OS,
+				Arch:                 connector.Arch,
+				Protocol:             provider.Protocol(connector.Protocol),
+				ProtocolPort:         connector.ProtocolPort,
+				Username:             connector.Username,
+				Password:             connector.Password,
+				UseStaticCredentials: connector.UseStaticCredentials,
+			},
+		}
+
+		if connector.KeyPath != "" {
+			settings.Key, err = os.ReadFile(connector.KeyPath)
+			if err != nil {
+				return nil, fmt.Errorf("could not read connector key of runner %q: %w", r.Name, err)
+			}
+		}
+
+		result = append(result, pluginConfig{
+			Runner:          r.Name,
+			Group:           group,
+			Settings:        settings,
+			UseExternalAddr: connector.UseExternalAddr,
+		})
+	}
+
+	if len(result) == 0 {
+		if runner != "" {
+			return nil, fmt.Errorf("no plugin config found for runner: %s", runner)
+		}
+		return nil, fmt.Errorf("no plugin config found in: %s", path)
+	}
+
+	return result, nil
+}
@@ -1,11 +1,36 @@
 package main
 
 import (
+	"context"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+
 	"gitlab.com/gitlab-org/fleeting/fleeting/plugin"
 
 	hetzner "gitlab.com/hetznercloud/fleeting-plugin-hetzner"
 )
 
+// commands are the operator subcommands, other arguments are handled by the fleeting
+// plugin.
+var commands = map[string]func(ctx context.Context, name string, args []string, stdout, stderr io.Writer) int{
+	"validate": runValidate,
+	"list":     runList,
+	"describe": runDescribe,
+	"delete":   runDelete,
+	"ssh":      runSSH,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			code := command(ctx, filepath.Base(os.Args[0]), os.Args[2:], os.Stdout, os.Stderr)
+			stop()
+			os.Exit(code)
+		}
+	}
+
 	plugin.Main(&hetzner.InstanceGroup{}, hetzner.Version)
 }
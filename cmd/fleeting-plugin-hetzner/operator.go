@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"gitlab.com/gitlab-org/fleeting/fleeting/provider"
+
+	hetzner "gitlab.com/hetznercloud/fleeting-plugin-hetzner"
+)
+
+const defaultConfigPath = "/etc/gitlab-runner/config.toml"
+
+// operatorCommand holds the state shared by the operator subcommands.
+type operatorCommand struct {
+	stderr io.Writer
+
+	flags      *flag.FlagSet
+	configPath *string
+	runner     *string
+	ownerToken *string
+
+	config pluginConfig
+}
+
+func newOperatorCommand(name, command, usage string, stderr io.Writer) *operatorCommand {
+	c := &operatorCommand{stderr: stderr}
+
+	c.flags = flag.NewFlagSet(command, flag.ContinueOnError)
+	c.flags.SetOutput(stderr)
+	c.flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: %s %s [options] %s\n\nOptions:\n", name, command, usage)
+		c.flags.PrintDefaults()
+	}
+	c.configPath = c.flags.String("config", defaultConfigPath, "Path to the runner config.toml or to a JSON plugin config")
+	c.runner = c.flags.String("runner", "", "Name of the runner to use, required when the config has multiple runners")
+	c.ownerToken = c.flags.String("owner-token", "", "Owner token of the runner manager, overrides the owner_token of the plugin config")
+
+	return c
+}
+
+// open parses the arguments, loads the plugin config and initializes the instance
+// group. It returns the process exit code on failure.
+func (c *operatorCommand) open(ctx context.Context, args []string, minArgs, maxArgs int) (int, bool) {
+	if err := c.flags.Parse(args); err != nil {
+		return 2, false
+	}
+	if c.flags.NArg() < minArgs || (maxArgs >= 0 && c.flags.NArg() > maxArgs) {
+		c.flags.Usage()
+		return 2, false
+	}
+
+	configs, err := loadConfigs(*c.configPath, *c.runner)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "error: %s\n", err)
+		return 1, false
+	}
+	if len(configs) > 1 {
+		fmt.Fprintf(c.stderr, "error: multiple plugin configs found, select a runner using -runner\n")
+		return 2, false
+	}
+	c.config = configs[0]
+	if *c.ownerToken != "" {
+		c.config.Group.OwnerToken = *c.ownerToken
+	}
+
+	log := hclog.New(&hclog.LoggerOptions{Output: c.stderr, Level: hclog.Warn})
+
+	if err := c.config.Group.Open(ctx, log, c.config.Settings); err != nil {
+		fmt.Fprintf(c.stderr, "error: %s\n", err)
+		return 1, false
+	}
+
+	return 0, true
+}
+
+// runList runs the list subcommand, and returns the process exit code.
+func runList(ctx context.Context, name string, args []string, stdout, stderr io.Writer) int {
+	c := newOperatorCommand(name, "list", "", stderr)
+	if code, ok := c.open(ctx, args, 0, 0); !ok {
+		return code
+	}
+
+	instances, err := c.config.Group.Instances(ctx)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return 1
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "IID\tSTATE\tSTATUS\tSERVER TYPE\tEXTERNAL ADDR\tINTERNAL ADDR\tAGE")
+	for _, details := range instances {
+		server := details.Instance.Server
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			details.Instance.IID(),
+			orDash(string(details.State)),
+			server.Status,
+			serverTypeName(details),
+			orDash(details.ConnectInfo.ExternalAddr),
+			orDash(details.ConnectInfo.InternalAddr),
+			formatAge(server.Created),
+		)
+	}
+	tw.Flush()
+
+	return 0
+}
+
+// runDescribe runs the describe subcommand, and returns the process exit code.
+func runDescribe(ctx context.Context, name string, args []string, stdout, stderr io.Writer) int {
+	c := newOperatorCommand(name, "describe", "<iid>", stderr)
+	if code, ok := c.open(ctx, args, 1, 1); !ok {
+		return code
+	}
+
+	details, err := c.instance(ctx, c.flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return 1
+	}
+
+	server := details.Instance.Server
+
+	tw := tabwriter.NewWriter(stdout, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(tw, "IID:\t%s\n", details.Instance.IID())
+	fmt.Fprintf(tw, "Name:\t%s\n", server.Name)
+	fmt.Fprintf(tw, "ID:\t%d\n", server.ID)
+	fmt.Fprintf(tw, "State:\t%s\n", orDash(string(details.State)))
+	fmt.Fprintf(tw, "Status:\t%s\n", server.Status)
+	fmt.Fprintf(tw, "Created:\t%s (%s ago)\n", server.Created.Format(time.RFC3339), formatAge(server.Created))
+	fmt.Fprintf(tw, "Server type:\t%s\n", serverTypeName(*details))
+	if server.Datacenter != nil {
+		fmt.Fprintf(tw, "Datacenter:\t%s\n", server.Datacenter.Name)
+	}
+	if server.Image != nil {
+		fmt.Fprintf(tw, "Image:\t%s (id %d)\n", orDash(server.Image.Name), server.Image.ID)
+	}
+	fmt.Fprintf(tw, "OS:\t%s\n", orDash(details.ConnectInfo.OS))
+	fmt.Fprintf(tw, "Arch:\t%s\n", orDash(details.ConnectInfo.Arch))
+	fmt.Fprintf(tw, "External addr:\t%s\n", orDash(details.ConnectInfo.ExternalAddr))
+	fmt.Fprintf(tw, "Internal addr:\t%s\n", orDash(details.ConnectInfo.InternalAddr))
+
+	volumes := make([]string, 0, len(server.Volumes))
+	for _, volume := range server.Volumes {
+		volumes = append(volumes, fmt.Sprint(volume.ID))
+	}
+	fmt.Fprintf(tw, "Volumes:\t%s\n", orDash(strings.Join(volumes, ",")))
+
+	labels := make([]string, 0, len(server.Labels))
+	for _, key := range slices.Sorted(maps.Keys(server.Labels)) {
+		labels = append(labels, key+"="+server.Labels[key])
+	}
+	fmt.Fprintf(tw, "Labels:\t%s\n", orDash(strings.Join(labels, ",")))
+	tw.Flush()
+
+	return 0
+}
+
+// runDelete runs the delete subcommand, and returns the process exit code.
+func runDelete(ctx context.Context, name string, args []string, stdout, stderr io.Writer) int {
+	c := newOperatorCommand(name, "delete", "<iid>...", stderr)
+	if code, ok := c.open(ctx, args, 1, -1); !ok {
+		return code
+	}
+
+	// Only delete instances that belong to the instance group, a typo in an IID must
+	// never delete an unrelated server.
+	instances, err := c.config.Group.Instances(ctx)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return 1
+	}
+	known := make(map[string]*hetzner.InstanceDetails, len(instances))
+	for _, details := range instances {
+		known[details.Instance.IID()] = &details
+	}
+	for _, iid := range c.flags.Args() {
+		details, ok := known[iid]
+		if !ok {
+			fmt.Fprintf(stderr, "error: instance not found in instance group: %s\n", iid)
+			return 1
+		}
+		// Without owner token, the instances of every owner are listed.
+		if owner := details.Instance.Owner(); owner != "" && c.config.Group.OwnerToken == "" {
+			fmt.Fprintf(stderr, "error: instance %s belongs to owner %s, set the owner token using -owner-token\n", iid, owner)
+			return 1
+		}
+	}
+
+	deleted, err := c.config.Group.Delete(ctx, c.flags.Args())
+	for _, iid := range deleted {
+		fmt.Fprintf(stdout, "deleted %s\n", iid)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// runSSH runs the ssh subcommand, and returns the process exit code.
+func runSSH(ctx context.Context, name string, args []string, stdout, stderr io.Writer) int {
+	c := newOperatorCommand(name, "ssh", "<iid> [-- <ssh args>...]", stderr)
+	if code, ok := c.open(ctx, args, 1, -1); !ok {
+		return code
+	}
+
+	details, err := c.instance(ctx, c.flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return 1
+	}
+
+	sshArgs, err := c.sshArgs(details.ConnectInfo)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return 1
+	}
+
+	// The connector static key is passed using a temporary identity file.
+	if len(details.ConnectInfo.Key) > 0 {
+		keyFile, err := os.CreateTemp("", "fleeting-plugin-hetzner-*")
+		if err != nil {
+			fmt.Fprintf(stderr, "error: could not create identity file: %s\n", err)
+			return 1
+		}
+		defer os.Remove(keyFile.Name())
+
+		if _, err := keyFile.Write(details.ConnectInfo.Key); err != nil {
+			fmt.Fprintf(stderr, "error: could not write identity file: %s\n", err)
+			return 1
+		}
+		keyFile.Close()
+
+		sshArgs = append([]string{"-i", keyFile.Name()}, sshArgs...)
+	}
+
+	extraArgs := c.flags.Args()[1:]
+	if len(extraArgs) > 0 && extraArgs[0] == "--" {
+		extraArgs = extraArgs[1:]
+	}
+	sshArgs = append(sshArgs, extraArgs...)
+
+	cmd := exec.CommandContext(ctx, "ssh", sshArgs...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode()
+		}
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// instance returns the details of an instance that belongs to the instance group.
+func (c *operatorCommand) instance(ctx context.Context, iid string) (*hetzner.InstanceDetails, error) {
+	details, err := c.config.Group.Instance(ctx, iid)
+	if err != nil {
+		return nil, err
+	}
+	if details.Instance.Server == nil || details.Instance.Server.Labels["instance-group"] != c.config.Group.Name {
+		return nil, fmt.Errorf("instance not found in instance group: %s", iid)
+	}
+	return details, nil
+}
+
+// sshArgs returns the ssh arguments to connect to an instance, using the same address
+// as the connector.
+func (c *operatorCommand) sshArgs(info provider.ConnectInfo) ([]string, error) {
+	if info.Protocol != provider.ProtocolSSH {
+		return nil, fmt.Errorf("unsupported connector protocol: %s", info.Protocol)
+	}
+
+	addr := info.InternalAddr
+	if c.config.UseExternalAddr || addr == "" {
+		addr = info.ExternalAddr
+	}
+	if addr == "" {
+		return nil, fmt.Errorf("instance has no address: %s", info.ID)
+	}
+
+	args := []string{"-l", info.Username}
+	if info.ProtocolPort != 0 {
+		args = append(args, "-p", fmt.Sprint(info.ProtocolPort))
+	}
+	args = append(args, addr)
+
+	return args, nil
+}
+
+func serverTypeName(details hetzner.InstanceDetails) string {
+	if details.Instance.Server.ServerType == nil {
+		return "-"
+	}
+	return details.Instance.Server.ServerType.Name
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+func formatAge(created time.Time) string {
+	if created.IsZero() {
+		return "-"
+	}
+	return time.Since(created).Truncate(time.Second).String()
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gitlab.com/gitlab-org/fleeting/fleeting/provider"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/exp/mockutil"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/schema"
+
+	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/testutils"
+)
+
+func writeTestPluginConfig(t *testing.T, requests []mockutil.Request) string {
+	t.Helper()
+
+	server := httptest.NewServer(mockutil.Handler(t, append([]mockutil.Request{
+		testutils.GetLocationHel1Request,
+		testutils.GetServerTypeCPX11Request,
+		testutils.GetImageDebian12Request,
+	}, requests...)))
+	t.Cleanup(server.Close)
+
+	return writeFile(t, "plugin_config.json", fmt.Sprintf(`{
+		"name": "fleeting", "token": "dummy", "endpoint": %q,
+		"location": "hel1", "server_type": "cpx11", "image": "debian-12"
+	}`, server.URL))
+}
+
+var listServersRequest = mockutil.Request{
+	Method: "GET", Path: "/servers?label_selector=instance-group%3Dfleeting&page=1",
+	Status: 200,
+	JSON: schema.ServerListResponse{
+		Servers: []schema.Server{
+			{ID: 1, Name: "fleeting-a", Status: "running",
+				ServerType: schema.ServerType{Name: "cpx11", Architecture: "x86"},
+				PublicNet: schema.ServerPublicNet{
+					IPv4: schema.ServerPublicNetIPv4{IP: "37.1.1.1"},
+				},
+				Labels: map[string]string{"instance-group": "fleeting"},
+			},
+		},
+	},
+}
+
+func TestRunList(t *testing.T) {
+	t.Setenv("HCLOUD_TOKEN", "")
+	t.Setenv("HCLOUD_ENDPOINT", "")
+
+	path := writeTestPluginConfig(t, []mockutil.Request{listServersRequest})
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := runList(context.Background(), "fleeting-plugin-hetzner", []string{"-config", path}, stdout, stderr)
+	require.Equal(t, 0, code, stderr.String())
+	require.Equal(t, `IID           STATE    STATUS   SERVER TYPE  EXTERNAL ADDR  INTERNAL ADDR  AGE
+fleeting-a:1  running  running  cpx11        37.1.1.1       -              -
+`, stdout.String())
+}
+
+func TestRunDescribe(t *testing.T) {
+	t.Setenv("HCLOUD_TOKEN", "")
+	t.Setenv("HCLOUD_ENDPOINT", "")
+
+	t.Run("other instance group", func(t *testing.T) {
+		path := writeTestPluginConfig(t, []mockutil.Request{
+			{
+				Method: "GET", Path: "/servers/2",
+				Status: 200,
+				JSON: schema.ServerGetResponse{
+					Server: schema.Server{ID: 2, Name: "other", Status: "running",
+						ServerType: schema.ServerType{Name: "cpx11", Architecture: "x86"},
+						Labels:     map[string]string{"instance-group": "other"},
+					},
+				},
+			},
+		})
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		code := runDescribe(context.Background(), "fleeting-plugin-hetzner", []string{"-config", path, "other:2"}, stdout, stderr)
+		require.Equal(t, 1, code)
+		require.Equal(t, "error: instance not found in instance group: other:2\n", stderr.String())
+	})
+
+	t.Run("usage", func(t *testing.T) {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		code := runDescribe(context.Background(), "fleeting-plugin-hetzner", []string{}, stdout, stderr)
+		require.Equal(t, 2, code)
+		require.Contains(t, stderr.String(), "Usage: fleeting-plugin-hetzner describe [options] <iid>")
+	})
+}
+
+func TestRunDelete(t *testing.T) {
+	t.Setenv("HCLOUD_TOKEN", "")
+	t.Setenv("HCLOUD_ENDPOINT", "")
+
+	t.Run("unknown instance", func(t *testing.T) {
+		path := writeTestPluginConfig(t, []mockutil.Request{listServersRequest})
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		code := runDelete(context.Background(), "fleeting-plugin-hetzner", []string{"-config", path, "fleeting-a:1", "other:2"}, stdout, stderr)
+		require.Equal(t, 1, code)
+		require.Equal(t, "error: instance not found in instance group: other:2\n", stderr.String())
+		require.Empty(t, stdout.String())
+	})
+
+	t.Run("instance of an owner", func(t *testing.T) {
+		request := listServersRequest
+		request.JSON = schema.ServerListResponse{
+			Servers: []schema.Server{
+				{ID: 1, Name: "fleeting-a", Status: "running",
+					ServerType: schema.ServerType{Name: "cpx11", Architecture: "x86"},
+					Labels:     map[string]string{"instance-group": "fleeting", "owner": "runner"},
+				},
+			},
+		}
+		path := writeTestPluginConfig(t, []mockutil.Request{request})
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		code := runDelete(context.Background(), "fleeting-plugin-hetzner", []string{"-config", path, "fleeting-a:1"}, stdout, stderr)
+		require.Equal(t, 1, code)
+		require.Equal(t, "error: instance fleeting-a:1 belongs to owner runner, set the owner token using -owner-token\n", stderr.String())
+		require.Empty(t, stdout.String())
+	})
+}
+
+func TestSSHArgs(t *testing.T) {
+	testCases := []struct {
+		name            string
+		useExternalAddr bool
+		info            provider.ConnectInfo
+		want            []string
+		wantErr         string
+	}{
+		{
+			name: "internal addr",
+			info: provider.ConnectInfo{ConnectorConfig: provider.ConnectorConfig{Protocol: provider.ProtocolSSH, Username: "root"},
+				InternalAddr: "10.0.1.2", ExternalAddr: "37.1.1.1"},
+			want: []string{"-l", "root", "10.0.1.2"},
+		},
+		{
+			name:            "external addr",
+			useExternalAddr: true,
+			info: provider.ConnectInfo{ConnectorConfig: provider.ConnectorConfig{Protocol: provider.ProtocolSSH, Username: "root", ProtocolPort: 2222},
+				InternalAddr: "10.0.1.2", ExternalAddr: "37.1.1.1"},
+			want: []string{"-l", "root", "-p", "2222", "37.1.1.1"},
+		},
+		{
+			name: "no internal addr",
+			info: provider.ConnectInfo{ConnectorConfig: provider.ConnectorConfig{Protocol: provider.ProtocolSSH, Username: "root"},
+				ExternalAddr: "37.1.1.1"},
+			want: []string{"-l", "root", "37.1.1.1"},
+		},
+		{
+			name:    "winrm",
+			info:    provider.ConnectInfo{ConnectorConfig: provider.ConnectorConfig{Protocol: provider.ProtocolWinRM}},
+			wantErr: "unsupported connector protocol: winrm",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			c := &operatorCommand{config: pluginConfig{UseExternalAddr: testCase.useExternalAddr}}
+
+			args, err := c.sshArgs(testCase.info)
+			if testCase.wantErr != "" {
+				require.EqualError(t, err, testCase.wantErr)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, testCase.want, args)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+
+	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/instancegroup"
+)
+
+const validateUsage = `Usage: %s validate [options] <config.toml|plugin_config.json>
+
+Validate the plugin config against the Hetzner Cloud API, without creating any
+resource, and print a report of the resolved resources.
+
+Options:
+`
+
+// runValidate runs the validate subcommand, and returns the process exit code.
+func runValidate(ctx context.Context, name string, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, validateUsage, name)
+		flags.PrintDefaults()
+	}
+	runner := flags.String("runner", "", "Only validate the plugin config of the runner with this name")
+	ownerToken := flags.String("owner-token", "", "Owner token of the runner manager, overrides the owner_token of the plugin config")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	configs, err := loadConfigs(flags.Arg(0), *runner)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return 1
+	}
+
+	log := hclog.New(&hclog.LoggerOptions{Output: stderr, Level: hclog.Warn})
+
+	exitCode := 0
+	for i, config := range configs {
+		if i > 0 {
+			fmt.Fprintln(stdout)
+		}
+		if config.Runner != "" {
+			fmt.Fprintf(stdout, "Runner %q\n", config.Runner)
+		}
+
+		if *ownerToken != "" {
+			config.Group.OwnerToken = *ownerToken
+		}
+
+		report, err := config.Group.Check(ctx, log, config.Settings)
+		if err != nil {
+			fmt.Fprintf(stdout, "Result: invalid\n%s\n", indent(err.Error()))
+			exitCode = 1
+			continue
+		}
+
+		printReport(stdout, report)
+
+		if len(report.Warnings) > 0 {
+			fmt.Fprintf(stdout, "Result: valid with %d warning(s)\n", len(report.Warnings))
+		} else {
+			fmt.Fprintln(stdout, "Result: valid")
+		}
+	}
+
+	return exitCode
+}
+
+func printReport(w io.Writer, report *instancegroup.Report) {
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+
+	fmt.Fprintf(tw, "Location:\t%s (%s, network zone %s)\n",
+		report.Location.Name, report.Location.Description, report.Location.NetworkZone)
+
+	for i, serverType := range report.ServerTypes {
+		label := ""
+		if i == 0 {
+			label = "Server types:"
+		}
+		fmt.Fprintf(tw, "%s\t%s (id %d, %s, %d cores, %g GB memory, %d GB disk)\n",
+			label, serverType.Name, serverType.ID, serverType.Architecture,
+			serverType.Cores, serverType.Memory, serverType.Disk)
+	}
+
+	fmt.Fprintf(tw, "Image:\t%s\n", formatImage(report.Image))
+
+	for i, network := range report.PrivateNetworks {
+		label := ""
+		if i == 0 {
+			label = "Private networks:"
+		}
+		fmt.Fprintf(tw, "%s\t%s (id %d, %s)\n", label, network.Name, network.ID, network.IPRange)
+	}
+
+	for i, loadBalancer := range report.LoadBalancers {
+		label := ""
+		if i == 0 {
+			label = "Load balancers:"
+		}
+		fmt.Fprintf(tw, "%s\t%s (id %d)\n", label, loadBalancer.Name, loadBalancer.ID)
+	}
+
+	for i, sshKey := range report.SSHKeys {
+		label := ""
+		if i == 0 {
+			label = "SSH keys:"
+		}
+		fmt.Fprintf(tw, "%s\t%s (id %d, %s)\n", label, sshKey.Name, sshKey.ID, sshKey.Fingerprint)
+	}
+
+	labels := make([]string, 0, len(report.Labels))
+	for _, key := range slices.Sorted(maps.Keys(report.Labels)) {
+		labels = append(labels, key+"="+report.Labels[key])
+	}
+	fmt.Fprintf(tw, "Labels:\t%s\n", strings.Join(labels, ","))
+
+	tw.Flush()
+
+	if len(report.Warnings) > 0 {
+		fmt.Fprintln(w, "Warnings:")
+		for _, warning := range report.Warnings {
+			fmt.Fprintf(w, "  - %s\n", warning)
+		}
+	}
+}
+
+func formatImage(image *hcloud.Image) string {
+	name := image.Name
+	if name == "" {
+		name = image.Description
+	}
+	return fmt.Sprintf("%s (id %d, %s, %s, os flavor %s)", name, image.ID, image.Type, image.Architecture, image.OSFlavor)
+}
+
+func indent(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = "  - " + line
+	}
+	return strings.Join(lines, "\n")
+}
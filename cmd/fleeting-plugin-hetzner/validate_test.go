@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/exp/mockutil"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/schema"
+
+	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/testutils"
+)
+
+func TestRunValidate(t *testing.T) {
+	t.Setenv("HCLOUD_TOKEN", "")
+	t.Setenv("HCLOUD_ENDPOINT", "")
+
+	t.Run("valid", func(t *testing.T) {
+		server := httptest.NewServer(mockutil.Handler(t, []mockutil.Request{
+			testutils.GetLocationHel1Request,
+			testutils.GetServerTypeCPX11Request,
+			testutils.GetImageDebian12Request,
+			{Method: "GET", Path: "/datacenters?page=1&per_page=50",
+				Status: 200,
+				JSON: schema.DatacenterListResponse{
+					Datacenters: []schema.Datacenter{
+						{ID: 3, Name: "hel1-dc2",
+							Location:    schema.Location{ID: 3, Name: "hel1"},
+							ServerTypes: schema.DatacenterServerTypes{Available: []int64{}},
+						},
+					},
+				},
+			},
+		}))
+
+		path := writeFile(t, "plugin_config.json", fmt.Sprintf(`{
+			"name": "fleeting", "token": "dummy", "endpoint": %q,
+			"location": "hel1", "server_type": "cpx11", "image": "debian-12"
+		}`, server.URL))
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		code := runValidate(context.Background(), "fleeting-plugin-hetzner", []string{"-owner-token", "runner", path}, stdout, stderr)
+		require.Equal(t, 0, code, stderr.String())
+		require.Equal(t, `Location:     hel1 (, network zone )
+Server types: cpx11 (id 1, x86, 0 cores, 0 GB memory, 0 GB disk)
+Image:        debian-12 (id 114690387, , x86, os flavor debian)
+Labels:       instance-group=fleeting,managed-by=fleeting-plugin-hetzner,owner=runner
+Warnings:
+  - server type cpx11 is currently not available in location hel1
+Result: valid with 1 warning(s)
+`, stdout.String())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		path := writeFile(t, "plugin_config.json", `{"name": "fleeting"}`)
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		code := runValidate(context.Background(), "fleeting-plugin-hetzner", []string{path}, stdout, stderr)
+		require.Equal(t, 1, code)
+		require.Equal(t, `Result: invalid
+  - missing required plugin config: token
+  - missing required plugin config: location
+  - missing required plugin config: server_type
+  - missing required plugin config: image
+`, stdout.String())
+	})
+
+	t.Run("usage", func(t *testing.T) {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		code := runValidate(context.Background(), "fleeting-plugin-hetzner", []string{}, stdout, stderr)
+		require.Equal(t, 2, code)
+		require.Contains(t, stderr.String(), "Usage: fleeting-plugin-hetzner validate")
+	})
+}
@@ -3,13 +3,29 @@ package hetzner
 import (
 	"errors"
 	"fmt"
+	"maps"
 	"os"
+	"slices"
+	"time"
 
 	"gitlab.com/gitlab-org/fleeting/fleeting/provider"
 
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
 	"github.com/hetznercloud/hcloud-go/v2/hcloud/exp/kit/envutil"
+
+	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/instancegroup"
 )
 
+// reservedLabels are the labels managed by the plugin, which must not be overwritten
+// by the user defined labels.
+var reservedLabels = []string{
+	"managed-by",
+	"instance-group",
+	"owner",
+	"parked",
+	"ssh-keys",
+}
+
 func (g *InstanceGroup) validate() error {
 	errs := []error{}
 
@@ -19,7 +35,11 @@ func (g *InstanceGroup) validate() error {
 	}
 
 	if g.settings.Username == "" {
-		g.settings.Username = "root"
+		if g.windowsEnabled() {
+			g.settings.Username = "Administrator"
+		} else {
+			g.settings.Username = "root"
+		}
 	}
 
 	// Environment variables
@@ -39,6 +59,14 @@ func (g *InstanceGroup) validate() error {
 			g.Endpoint = value
 		}
 	}
+	if g.DNS != nil {
+		value, err := envutil.LookupEnvWithFile("HETZNER_DNS_TOKEN")
+		if err != nil {
+			errs = append(errs, err)
+		} else if value != "" {
+			g.DNS.Token = value
+		}
+	}
 
 	// Checks
 	if g.Name == "" {
@@ -65,14 +93,112 @@ func (g *InstanceGroup) validate() error {
 		errs = append(errs, fmt.Errorf("invalid plugin config value: volume_size must be >= 10"))
 	}
 
+	switch g.RecycleMode {
+	case "", instancegroup.RecycleModeDelete, instancegroup.RecycleModeRebuild:
+	default:
+		errs = append(errs, fmt.Errorf("invalid plugin config value: recycle_mode must be one of: %s, %s",
+			instancegroup.RecycleModeDelete, instancegroup.RecycleModeRebuild))
+	}
+
+	if g.ParkingMargin < 0 || time.Duration(g.ParkingMargin) >= time.Hour {
+		errs = append(errs, fmt.Errorf("invalid plugin config value: parking_margin must be >= 0 and < 1h"))
+	}
+
+	if g.InstanceCacheTTL < 0 {
+		errs = append(errs, fmt.Errorf("invalid plugin config value: instance_cache_ttl must be >= 0"))
+	}
+
+	if g.GracefulShutdownTimeout < 0 {
+		errs = append(errs, fmt.Errorf("invalid plugin config value: graceful_shutdown_timeout must be >= 0"))
+	}
+
+	if g.ServerCreateTimeout < 0 {
+		errs = append(errs, fmt.Errorf("invalid plugin config value: server_create_timeout must be >= 0"))
+	}
+	if g.VolumeCreateTimeout < 0 {
+		errs = append(errs, fmt.Errorf("invalid plugin config value: volume_create_timeout must be >= 0"))
+	}
+	if g.ServerDeleteTimeout < 0 {
+		errs = append(errs, fmt.Errorf("invalid plugin config value: server_delete_timeout must be >= 0"))
+	}
+
+	for _, hook := range []struct {
+		name string
+		hook *Hook
+	}{
+		{"pre_create", g.Hooks.PreCreate},
+		{"post_create", g.Hooks.PostCreate},
+		{"pre_delete", g.Hooks.PreDelete},
+		{"post_delete", g.Hooks.PostDelete},
+	} {
+		if hook.hook == nil {
+			continue
+		}
+		if len(hook.hook.Command) == 0 || hook.hook.Command[0] == "" {
+			errs = append(errs, fmt.Errorf("missing required plugin config: hooks.%s.command", hook.name))
+		}
+		if hook.hook.Timeout < 0 {
+			errs = append(errs, fmt.Errorf("invalid plugin config value: hooks.%s.timeout must be >= 0", hook.name))
+		}
+		switch hook.hook.OnFailure {
+		case "", instancegroup.HookOnFailureFail, instancegroup.HookOnFailureLog:
+		default:
+			errs = append(errs, fmt.Errorf("invalid plugin config value: hooks.%s.on_failure must be one of: %s, %s",
+				hook.name, instancegroup.HookOnFailureFail, instancegroup.HookOnFailureLog))
+		}
+	}
+
+	if g.DNS != nil {
+		if g.DNS.Zone == "" {
+			errs = append(errs, fmt.Errorf("missing required plugin config: dns.zone"))
+		}
+		if g.DNS.Token == "" {
+			errs = append(errs, fmt.Errorf("missing required plugin config: dns.token"))
+		}
+		if g.DNS.TTL < 0 {
+			errs = append(errs, fmt.Errorf("invalid plugin config value: dns.ttl must be >= 0"))
+		}
+	}
+
+	if slices.Contains(g.LoadBalancers, "") {
+		errs = append(errs, fmt.Errorf("invalid plugin config value: load_balancers must not contain empty values"))
+	}
+	if g.LoadBalancerUsePrivateIP && len(g.PrivateNetworks) == 0 {
+		errs = append(errs, fmt.Errorf("missing required plugin config: private_networks, required by load_balancer_use_private_ip"))
+	}
+
 	if g.UserData != "" && g.UserDataFile != "" {
 		errs = append(errs, fmt.Errorf("mutually exclusive plugin config provided: user_data, user_data_file"))
 	}
 
-	if g.settings.Protocol == provider.ProtocolWinRM {
+	if slices.Contains(g.SSHKeys, "") {
+		errs = append(errs, fmt.Errorf("invalid plugin config value: ssh_keys must not contain empty values"))
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(g.Labels)) {
+		if slices.Contains(reservedLabels, key) {
+			errs = append(errs, fmt.Errorf("invalid plugin config value: labels must not contain reserved label: %s", key))
+			continue
+		}
+		if _, err := hcloud.ValidateResourceLabels(map[string]any{key: g.Labels[key]}); err != nil {
+			errs = append(errs, fmt.Errorf("invalid plugin config value: labels: %w", err))
+		}
+	}
+
+	if g.OwnerToken != "" {
+		if _, err := hcloud.ValidateResourceLabels(map[string]any{"owner": g.OwnerToken}); err != nil {
+			errs = append(errs, fmt.Errorf("invalid plugin config value: owner_token: %w", err))
+		}
+	}
+
+	if err := g.settings.Protocol.Valid(); err != nil {
 		errs = append(errs, fmt.Errorf("unsupported connector config protocol: %s", g.settings.Protocol))
 	}
 
+	if g.windowsEnabled() && g.settings.UseStaticCredentials && g.settings.Password == "" {
+		errs = append(errs, fmt.Errorf("missing required connector config: password"))
+	}
+
 	return errors.Join(errs...)
 }
 
@@ -85,9 +211,9 @@ func (g *InstanceGroup) populate() error {
 		g.UserData = string(userData)
 	}
 
-	g.labels = map[string]string{
-		"managed-by": Version.Name,
-	}
+	g.labels = make(map[string]string, len(g.Labels)+1)
+	maps.Copy(g.labels, g.Labels)
+	g.labels["managed-by"] = Version.Name
 
 	return nil
 }
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -78,9 +79,49 @@ missing required plugin config: image`, err.Error())
 					},
 				},
 			},
+			assert: func(t *testing.T, group InstanceGroup, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, provider.ProtocolWinRM, group.settings.Protocol)
+				assert.Equal(t, "Administrator", group.settings.Username)
+			},
+		},
+		{
+			name: "winrm static credentials",
+			group: InstanceGroup{
+				Name:        "fleeting",
+				Token:       "dummy",
+				Location:    "hel1",
+				ServerTypes: []string{"cpx11"},
+				Image:       "debian-12",
+				settings: provider.Settings{
+					ConnectorConfig: provider.ConnectorConfig{
+						Protocol:             "winrm",
+						UseStaticCredentials: true,
+					},
+				},
+			},
+			assert: func(t *testing.T, group InstanceGroup, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, "missing required connector config: password", err.Error())
+			},
+		},
+		{
+			name: "invalid protocol",
+			group: InstanceGroup{
+				Name:        "fleeting",
+				Token:       "dummy",
+				Location:    "hel1",
+				ServerTypes: []string{"cpx11"},
+				Image:       "debian-12",
+				settings: provider.Settings{
+					ConnectorConfig: provider.ConnectorConfig{
+						Protocol: "telnet",
+					},
+				},
+			},
 			assert: func(t *testing.T, group InstanceGroup, err error) {
 				assert.Error(t, err)
-				assert.Equal(t, "unsupported connector config protocol: winrm", err.Error())
+				assert.Equal(t, "unsupported connector config protocol: telnet", err.Error())
 			},
 		},
 		{
@@ -99,6 +140,73 @@ missing required plugin config: image`, err.Error())
 				assert.Equal(t, "mutually exclusive plugin config provided: user_data, user_data_file", err.Error())
 			},
 		},
+		{
+			name: "ssh keys",
+			group: InstanceGroup{
+				Name:        "fleeting",
+				Token:       "dummy",
+				Location:    "hel1",
+				ServerTypes: []string{"cpx11"},
+				Image:       "debian-12",
+				SSHKeys:     []string{"admin", ""},
+			},
+			assert: func(t *testing.T, group InstanceGroup, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, "invalid plugin config value: ssh_keys must not contain empty values", err.Error())
+			},
+		},
+		{
+			name: "labels",
+			group: InstanceGroup{
+				Name:        "fleeting",
+				Token:       "dummy",
+				Location:    "hel1",
+				ServerTypes: []string{"cpx11"},
+				Image:       "debian-12",
+				Labels: map[string]string{
+					"team":           "ci",
+					"cost-center":    "in valid",
+					"-invalid":       "value",
+					"instance-group": "other",
+					"managed-by":     "me",
+				},
+			},
+			assert: func(t *testing.T, group InstanceGroup, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, `invalid plugin config value: labels: label key '-invalid' is not correctly formatted
+invalid plugin config value: labels: label value 'in valid' (key: cost-center) is not correctly formatted
+invalid plugin config value: labels must not contain reserved label: instance-group
+invalid plugin config value: labels must not contain reserved label: managed-by`, err.Error())
+			},
+		},
+		{
+			name: "parked label",
+			group: InstanceGroup{
+				Name:        "fleeting",
+				Token:       "dummy",
+				Location:    "hel1",
+				ServerTypes: []string{"cpx11"},
+				Image:       "debian-12",
+				Labels:      map[string]string{"parked": "true"},
+			},
+			assert: func(t *testing.T, group InstanceGroup, err error) {
+				assert.EqualError(t, err, "invalid plugin config value: labels must not contain reserved label: parked")
+			},
+		},
+		{
+			name: "ssh keys label",
+			group: InstanceGroup{
+				Name:        "fleeting",
+				Token:       "dummy",
+				Location:    "hel1",
+				ServerTypes: []string{"cpx11"},
+				Image:       "debian-12",
+				Labels:      map[string]string{"ssh-keys": "other"},
+			},
+			assert: func(t *testing.T, group InstanceGroup, err error) {
+				assert.EqualError(t, err, "invalid plugin config value: labels must not contain reserved label: ssh-keys")
+			},
+		},
 		{
 			name: "volume size",
 			group: InstanceGroup{
@@ -114,6 +222,158 @@ missing required plugin config: image`, err.Error())
 				assert.Equal(t, "invalid plugin config value: volume_size must be >= 10", err.Error())
 			},
 		},
+		{
+			name: "recycle mode",
+			group: InstanceGroup{
+				Name:        "fleeting",
+				Token:       "dummy",
+				Location:    "hel1",
+				ServerTypes: []string{"cpx11"},
+				Image:       "debian-12",
+				RecycleMode: "reinstall",
+			},
+			assert: func(t *testing.T, group InstanceGroup, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, "invalid plugin config value: recycle_mode must be one of: delete, rebuild", err.Error())
+			},
+		},
+		{
+			name: "parking margin",
+			group: InstanceGroup{
+				Name:           "fleeting",
+				Token:          "dummy",
+				Location:       "hel1",
+				ServerTypes:    []string{"cpx11"},
+				Image:          "debian-12",
+				ParkingEnabled: true,
+				ParkingMargin:  Duration(time.Hour),
+			},
+			assert: func(t *testing.T, group InstanceGroup, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, "invalid plugin config value: parking_margin must be >= 0 and < 1h", err.Error())
+			},
+		},
+		{
+			name: "graceful shutdown timeout",
+			group: InstanceGroup{
+				Name:                    "fleeting",
+				Token:                   "dummy",
+				Location:                "hel1",
+				ServerTypes:             []string{"cpx11"},
+				Image:                   "debian-12",
+				GracefulShutdownTimeout: Duration(-time.Second),
+			},
+			assert: func(t *testing.T, group InstanceGroup, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, "invalid plugin config value: graceful_shutdown_timeout must be >= 0", err.Error())
+			},
+		},
+		{
+			name: "action timeouts",
+			group: InstanceGroup{
+				Name:                "fleeting",
+				Token:               "dummy",
+				Location:            "hel1",
+				ServerTypes:         []string{"cpx11"},
+				Image:               "debian-12",
+				ServerCreateTimeout: Duration(-time.Second),
+				VolumeCreateTimeout: Duration(time.Minute),
+				ServerDeleteTimeout: Duration(-time.Second),
+			},
+			assert: func(t *testing.T, group InstanceGroup, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, `invalid plugin config value: server_create_timeout must be >= 0
+invalid plugin config value: server_delete_timeout must be >= 0`, err.Error())
+			},
+		},
+		{
+			name: "hooks",
+			group: InstanceGroup{
+				Name:        "fleeting",
+				Token:       "dummy",
+				Location:    "hel1",
+				ServerTypes: []string{"cpx11"},
+				Image:       "debian-12",
+				Hooks: Hooks{
+					PreCreate:  &Hook{Command: []string{"/usr/local/bin/register"}, OnFailure: "log"},
+					PostCreate: &Hook{},
+					PostDelete: &Hook{Command: []string{"/usr/local/bin/unregister"}, Timeout: Duration(-time.Second), OnFailure: "retry"},
+				},
+			},
+			assert: func(t *testing.T, group InstanceGroup, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, `missing required plugin config: hooks.post_create.command
+invalid plugin config value: hooks.post_delete.timeout must be >= 0
+invalid plugin config value: hooks.post_delete.on_failure must be one of: fail, log`, err.Error())
+			},
+		},
+		{
+			name: "dns",
+			group: InstanceGroup{
+				Name:        "fleeting",
+				Token:       "dummy",
+				Location:    "hel1",
+				ServerTypes: []string{"cpx11"},
+				Image:       "debian-12",
+				DNS:         &DNS{TTL: -1},
+			},
+			assert: func(t *testing.T, group InstanceGroup, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, `missing required plugin config: dns.zone
+missing required plugin config: dns.token
+invalid plugin config value: dns.ttl must be >= 0`, err.Error())
+			},
+		},
+		{
+			name: "dns with env",
+			group: InstanceGroup{
+				Name:        "fleeting",
+				Token:       "dummy",
+				Location:    "hel1",
+				ServerTypes: []string{"cpx11"},
+				Image:       "debian-12",
+				DNS:         &DNS{Zone: "example.com"},
+			},
+			env: map[string]string{
+				"HETZNER_DNS_TOKEN": "value",
+			},
+			assert: func(t *testing.T, group InstanceGroup, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, "value", group.DNS.Token)
+			},
+		},
+		{
+			name: "load balancers",
+			group: InstanceGroup{
+				Name:                     "fleeting",
+				Token:                    "dummy",
+				Location:                 "hel1",
+				ServerTypes:              []string{"cpx11"},
+				Image:                    "debian-12",
+				LoadBalancers:            []string{"buildkit", ""},
+				LoadBalancerUsePrivateIP: true,
+			},
+			assert: func(t *testing.T, group InstanceGroup, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, `invalid plugin config value: load_balancers must not contain empty values
+missing required plugin config: private_networks, required by load_balancer_use_private_ip`, err.Error())
+			},
+		},
+		{
+			name: "owner token",
+			group: InstanceGroup{
+				Name:        "fleeting",
+				Token:       "dummy",
+				Location:    "hel1",
+				ServerTypes: []string{"cpx11"},
+				Image:       "debian-12",
+				OwnerToken:  "runner/1",
+			},
+			assert: func(t *testing.T, group InstanceGroup, err error) {
+				assert.Error(t, err)
+				assert.ErrorContains(t, err, "invalid plugin config value: owner_token")
+			},
+		},
 	}
 	for _, testCase := range testCases {
 		t.Run(testCase.name, func(t *testing.T) {
@@ -143,3 +403,14 @@ func TestPopulateUserData(t *testing.T) {
 	require.NoError(t, group.populate())
 	require.Equal(t, "my-user-data", group.UserData)
 }
+
+func TestPopulateLabels(t *testing.T) {
+	group := InstanceGroup{
+		Name:   "fleeting",
+		Labels: map[string]string{"team": "ci"},
+	}
+
+	require.NoError(t, group.populate())
+	require.Equal(t, map[string]string{"managed-by": Version.Name, "team": "ci"}, group.labels)
+	require.Equal(t, map[string]string{"team": "ci"}, group.Labels)
+}
@@ -3,7 +3,11 @@ package hetzner
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"reflect"
+	"time"
+
+	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/instancegroup"
 )
 
 type LaxStringList []string
@@ -41,3 +45,71 @@ func (o *LaxStringList) UnmarshalJSON(data []byte) error {
 
 	return nil
 }
+
+// Duration is a [time.Duration] decoded from a duration string, for example "1m30s",
+// or from a number of seconds.
+type Duration time.Duration
+
+var _ json.Unmarshaler = (*Duration)(nil)
+
+func (o *Duration) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	switch typed := v.(type) {
+	case string:
+		value, err := time.ParseDuration(typed)
+		if err != nil {
+			return fmt.Errorf("invalid duration: %w", err)
+		}
+		*o = Duration(value)
+	case float64:
+		*o = Duration(typed * float64(time.Second))
+	default:
+		return &json.UnmarshalTypeError{
+			Value: string(data),
+			Type:  reflect.TypeOf(*o),
+		}
+	}
+
+	return nil
+}
+
+// Hook is the config of a local command run around the instances creation and
+// deletion.
+type Hook struct {
+	Command   LaxStringList `json:"command"`
+	Timeout   Duration      `json:"timeout"`
+	OnFailure string        `json:"on_failure"`
+}
+
+// Hooks is the config of the hooks run around the instances creation and deletion.
+type Hooks struct {
+	PreCreate  *Hook `json:"pre_create"`
+	PostCreate *Hook `json:"post_create"`
+	PreDelete  *Hook `json:"pre_delete"`
+	PostDelete *Hook `json:"post_delete"`
+}
+
+// DNS is the config of the DNS records of the instances.
+type DNS struct {
+	Endpoint       string `json:"endpoint"`
+	Token          string `json:"token"`
+	Zone           string `json:"zone"`
+	RecordTemplate string `json:"record_template"`
+	TTL            int    `json:"ttl"`
+}
+
+// groupHook creates the instance group hook from the hook config.
+func (o *Hook) groupHook() *instancegroup.Hook {
+	if o == nil {
+		return nil
+	}
+	return &instancegroup.Hook{
+		Command:   o.Command,
+		Timeout:   time.Duration(o.Timeout),
+		OnFailure: o.OnFailure,
+	}
+}
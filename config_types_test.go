@@ -3,6 +3,7 @@ package hetzner
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -60,3 +61,45 @@ func TestLaxStringListUnmarshalJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestDurationUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    Duration
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "success string",
+			data:    `"1m30s"`,
+			want:    Duration(90 * time.Second),
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "success number",
+			data:    `45`,
+			want:    Duration(45 * time.Second),
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "failure invalid string",
+			data:    `"soon"`,
+			want:    Duration(0),
+			wantErr: assert.Error,
+		},
+		{
+			name:    "failure bool",
+			data:    `true`,
+			want:    Duration(0),
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result Duration
+
+			tt.wantErr(t, result.UnmarshalJSON([]byte(tt.data)), fmt.Sprintf("UnmarshalJSON(%v)", tt.data))
+			assert.Equal(t, tt.want, result)
+		})
+	}
+}
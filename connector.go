@@ -2,43 +2,97 @@ package hetzner
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"maps"
 
 	"github.com/hetznercloud/hcloud-go/v2/hcloud"
 	"github.com/hetznercloud/hcloud-go/v2/hcloud/exp/kit/sshutil"
+
+	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/instancegroup"
 )
 
+// sshKeyUserLabel returns the label referencing the instance group on the SSH keys it
+// uses, unique for each owner and instance group name.
+func (g *InstanceGroup) sshKeyUserLabel() string {
+	sum := sha256.Sum256([]byte(g.OwnerToken + "/" + g.Name))
+	return instancegroup.SSHKeyUserLabelPrefix + hex.EncodeToString(sum[:])[:16]
+}
+
+// UploadSSHPublicKey uploads the connector public key, or reuses the existing SSH key
+// with the same fingerprint. The SSH keys managed by the plugin are labeled as used by
+// the instance group, so they are only deleted once no instance group uses them.
 func (g *InstanceGroup) UploadSSHPublicKey(ctx context.Context, pub []byte) (sshKey *hcloud.SSHKey, err error) {
 	fingerprint, err := sshutil.GetPublicKeyFingerprint(pub)
 	if err != nil {
 		return nil, fmt.Errorf("could not get ssh key fingerprint: %w", err)
 	}
 
+	userLabel := g.sshKeyUserLabel()
+
 	sshKey, _, err = g.client.SSHKey.GetByFingerprint(ctx, fingerprint)
 	if err != nil {
 		return nil, fmt.Errorf("could not get ssh key: %w", err)
 	}
 	if sshKey != nil {
 		g.log.Info("using existing ssh key", "name", sshKey.Name, "fingerprint", sshKey.Fingerprint)
+
+		if _, managed := sshKey.Labels["managed-by"]; managed && sshKey.Labels[userLabel] == "" {
+			labels := maps.Clone(sshKey.Labels)
+			labels[userLabel] = "true"
+
+			sshKey, _, err = g.client.SSHKey.Update(ctx, sshKey, hcloud.SSHKeyUpdateOpts{Labels: labels})
+			if err != nil {
+				return nil, fmt.Errorf("could not update ssh key: %w", err)
+			}
+		}
 		return sshKey, nil
 	}
 
-	sshKey, _, err = g.client.SSHKey.GetByName(ctx, g.Name)
-	if err != nil {
-		return nil, fmt.Errorf("could not get ssh key: %w", err)
-	}
-	if sshKey != nil {
+	// Fallback to a name unique to the instance group, when the instance group name is
+	// used by a SSH key of another instance group.
+	names := []string{g.Name, g.Name + "-" + userLabel[len(instancegroup.SSHKeyUserLabelPrefix):]}
+
+	name := ""
+	for _, candidate := range names {
+		sshKey, _, err = g.client.SSHKey.GetByName(ctx, candidate)
+		if err != nil {
+			return nil, fmt.Errorf("could not get ssh key: %w", err)
+		}
+		if sshKey == nil {
+			name = candidate
+			break
+		}
+
+		// Only delete the SSH keys left over by the instance group.
+		labels := maps.Clone(sshKey.Labels)
+		delete(labels, userLabel)
+		if _, managed := labels["managed-by"]; !managed || instancegroup.SSHKeyUsed(labels) {
+			g.log.Warn("ssh key name is used by another ssh key", "name", sshKey.Name, "fingerprint", sshKey.Fingerprint)
+			continue
+		}
+
 		g.log.Warn("deleting existing ssh key", "name", sshKey.Name, "fingerprint", sshKey.Fingerprint)
 		_, err = g.client.SSHKey.Delete(ctx, sshKey)
 		if err != nil {
 			return nil, fmt.Errorf("could not delete ssh key: %w", err)
 		}
+		name = candidate
+		break
 	}
+	if name == "" {
+		return nil, fmt.Errorf("could not upload ssh key: name is already used: %s", g.Name)
+	}
+
+	labels := make(map[string]string, len(g.labels)+1)
+	maps.Copy(labels, g.labels)
+	labels[userLabel] = "true"
 
-	g.log.Info("uploading ssh key", "name", g.Name, "fingerprint", fingerprint)
+	g.log.Info("uploading ssh key", "name", name, "fingerprint", fingerprint)
 	sshKey, _, err = g.client.SSHKey.Create(ctx, hcloud.SSHKeyCreateOpts{
-		Name:      g.Name,
-		Labels:    g.labels,
+		Name:      name,
+		Labels:    labels,
 		PublicKey: string(pub),
 	})
 	if err != nil {
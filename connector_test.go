@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/go-hclog"
@@ -49,6 +50,43 @@ func TestUploadSSHPublicKey(t *testing.T) {
 				require.Equal(t, "fleeting", result.Name)
 			},
 		},
+		{
+			name: "existing fingerprint managed by the plugin",
+			run: func(t *testing.T, ctx context.Context, group *InstanceGroup, server *mockutil.Server) {
+				_, sshKey := sshKeyFixture(t)
+				sshKey.Labels = map[string]string{"managed-by": "fleeting-plugin-hetzner", "used-by-other": "true"}
+
+				server.Expect([]mockutil.Request{
+					{
+						Method: "GET", Path: "/ssh_keys?fingerprint=" + url.QueryEscape(sshKey.Fingerprint),
+						Status: 200,
+						JSON:   schema.SSHKeyListResponse{SSHKeys: []schema.SSHKey{sshKey}},
+					},
+					{
+						Method: "PUT", Path: "/ssh_keys/1",
+						Want: func(t *testing.T, r *http.Request) {
+							body, err := io.ReadAll(r.Body)
+							require.NoError(t, err)
+
+							require.JSONEq(t, fmt.Sprintf(`{
+								"labels": {
+									"managed-by": "fleeting-plugin-hetzner",
+									"used-by-other": "true",
+									%q: "true"
+								}
+							}`, group.sshKeyUserLabel()), string(body))
+						},
+						Status: 200,
+						JSON:   schema.SSHKeyUpdateResponse{SSHKey: sshKey},
+					},
+				})
+
+				result, err := group.UploadSSHPublicKey(ctx, []byte(sshKey.PublicKey))
+				require.NoError(t, err)
+
+				require.Equal(t, int64(1), result.ID)
+			},
+		},
 		{
 			name: "new",
 			run: func(t *testing.T, ctx context.Context, group *InstanceGroup, server *mockutil.Server) {
@@ -79,8 +117,9 @@ func TestUploadSSHPublicKey(t *testing.T) {
 
 							require.JSONEq(t, fmt.Sprintf(`{
 								"name": "fleeting",
+								"labels": {%q: "true"},
 								"public_key": %s
-							}`, publicKey), string(body))
+							}`, group.sshKeyUserLabel(), publicKey), string(body))
 						},
 						Status: 201,
 						JSON:   schema.SSHKeyCreateResponse{SSHKey: sshKey},
@@ -111,7 +150,9 @@ func TestUploadSSHPublicKey(t *testing.T) {
 					{
 						Method: "GET", Path: "/ssh_keys?name=fleeting",
 						Status: 200,
-						JSON:   schema.SSHKeyListResponse{SSHKeys: []schema.SSHKey{sshKey}},
+						JSON: schema.SSHKeyListResponse{SSHKeys: []schema.SSHKey{
+							{ID: 1, Name: "fleeting", Labels: map[string]string{"managed-by": "fleeting-plugin-hetzner"}},
+						}},
 					},
 					{
 						Method: "DELETE", Path: "/ssh_keys/1",
@@ -128,8 +169,9 @@ func TestUploadSSHPublicKey(t *testing.T) {
 
 							require.JSONEq(t, fmt.Sprintf(`{
 								"name": "fleeting",
+								"labels": {%q: "true"},
 								"public_key": %s
-							}`, publicKey), string(body))
+							}`, group.sshKeyUserLabel(), publicKey), string(body))
 						},
 						Status: 201,
 						JSON:   schema.SSHKeyCreateResponse{SSHKey: sshKey},
@@ -143,6 +185,58 @@ func TestUploadSSHPublicKey(t *testing.T) {
 				require.Equal(t, "fleeting", result.Name)
 			},
 		},
+		{
+			name: "new with name used by another instance group",
+			run: func(t *testing.T, ctx context.Context, group *InstanceGroup, server *mockutil.Server) {
+				_, sshKey := sshKeyFixture(t)
+
+				name := "fleeting-" + strings.TrimPrefix(group.sshKeyUserLabel(), "used-by-")
+
+				server.Expect([]mockutil.Request{
+					{
+						Method: "GET", Path: "/ssh_keys?fingerprint=" + url.QueryEscape(sshKey.Fingerprint),
+						Status: 200,
+						JSON:   schema.SSHKeyListResponse{SSHKeys: []schema.SSHKey{}},
+					},
+					{
+						Method: "GET", Path: "/ssh_keys?name=fleeting",
+						Status: 200,
+						JSON: schema.SSHKeyListResponse{SSHKeys: []schema.SSHKey{
+							{ID: 1, Name: "fleeting", Labels: map[string]string{"managed-by": "fleeting-plugin-hetzner", "used-by-other": "true"}},
+						}},
+					},
+					{
+						Method: "GET", Path: "/ssh_keys?name=" + name,
+						Status: 200,
+						JSON:   schema.SSHKeyListResponse{SSHKeys: []schema.SSHKey{}},
+					},
+					{
+						Method: "POST", Path: "/ssh_keys",
+						Want: func(t *testing.T, r *http.Request) {
+							body, err := io.ReadAll(r.Body)
+							require.NoError(t, err)
+
+							publicKey, err := json.Marshal(sshKey.PublicKey)
+							require.NoError(t, err)
+
+							require.JSONEq(t, fmt.Sprintf(`{
+								"name": %q,
+								"labels": {%q: "true"},
+								"public_key": %s
+							}`, name, group.sshKeyUserLabel(), publicKey), string(body))
+						},
+						Status: 201,
+						JSON:   schema.SSHKeyCreateResponse{SSHKey: schema.SSHKey{ID: 2, Name: name}},
+					},
+				})
+
+				result, err := group.UploadSSHPublicKey(ctx, []byte(sshKey.PublicKey))
+				require.NoError(t, err)
+
+				require.Equal(t, int64(2), result.ID)
+				require.Equal(t, name, result.Name)
+			},
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -0,0 +1,200 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultEndpoint is the endpoint of the Hetzner DNS API.
+const DefaultEndpoint = "https://dns.hetzner.com/api/v1"
+
+// perPage is the number of records requested per page.
+const perPage = 100
+
+// Zone is a DNS zone.
+type Zone struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Record is a DNS record, the name is relative to the zone.
+type Record struct {
+	ID     string `json:"id,omitempty"`
+	ZoneID string `json:"zone_id"`
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	TTL    int    `json:"ttl,omitempty"`
+}
+
+// Error is an error returned by the DNS API.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("dns: server responded with status code %d", e.StatusCode)
+	}
+	return fmt.Sprintf("dns: %s (status code %d)", e.Message, e.StatusCode)
+}
+
+// IsNotFound returns whether the error is a not found error of the DNS API.
+func IsNotFound(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// Client is a minimal client of the Hetzner DNS API.
+type Client struct {
+	endpoint   string
+	token      string
+	httpClient *http.Client
+}
+
+// NewClient creates a DNS API client. The endpoint defaults to [DefaultEndpoint].
+func NewClient(endpoint, token string) *Client {
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+	return &Client{
+		endpoint:   strings.TrimSuffix(endpoint, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Zone returns the zone with the given name, or nil if not found.
+func (c *Client) Zone(ctx context.Context, name string) (*Zone, error) {
+	var body struct {
+		Zones []Zone `json:"zones"`
+	}
+	err := c.do(ctx, http.MethodGet, "/zones?"+url.Values{"name": {name}}.Encode(), nil, &body)
+	if err != nil {
+		if IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	for _, zone := range body.Zones {
+		if zone.Name == name {
+			return &zone, nil
+		}
+	}
+	return nil, nil
+}
+
+// Records returns all the records of a zone.
+func (c *Client) Records(ctx context.Context, zoneID string) ([]Record, error) {
+	records := make([]Record, 0)
+	for page := 1; ; page++ {
+		var body struct {
+			Records []Record `json:"records"`
+			Meta    struct {
+				Pagination struct {
+					LastPage int `json:"last_page"`
+				} `json:"pagination"`
+			} `json:"meta"`
+		}
+		query := url.Values{
+			"zone_id":  {zoneID},
+			"page":     {strconv.Itoa(page)},
+			"per_page": {strconv.Itoa(perPage)},
+		}
+		if err := c.do(ctx, http.MethodGet, "/records?"+query.Encode(), nil, &body); err != nil {
+			return nil, err
+		}
+
+		records = append(records, body.Records...)
+		if page >= body.Meta.Pagination.LastPage {
+			return records, nil
+		}
+	}
+}
+
+// CreateRecord creates a record, and returns the created record.
+func (c *Client) CreateRecord(ctx context.Context, record Record) (*Record, error) {
+	var body struct {
+		Record Record `json:"record"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/records", record, &body); err != nil {
+		return nil, err
+	}
+	return &body.Record, nil
+}
+
+// DeleteRecord deletes a record.
+func (c *Client) DeleteRecord(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/records/"+url.PathEscape(id), nil, nil)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, reqBody, respBody any) error {
+	var reader io.Reader
+	if reqBody != nil {
+		data, err := json.Marshal(reqBody)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Auth-API-Token", c.token)
+	if reqBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("dns: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("dns: %w", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		return &Error{StatusCode: resp.StatusCode, Message: errorMessage(data)}
+	}
+
+	if respBody != nil {
+		if err := json.Unmarshal(data, respBody); err != nil {
+			return fmt.Errorf("dns: could not decode response: %w", err)
+		}
+	}
+	return nil
+}
+
+// errorMessage extracts the message from an error response body, which is either
+// {"error": {"message": "..."}} or {"message": "..."}.
+func errorMessage(data []byte) string {
+	var body struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return ""
+	}
+	if body.Error.Message != "" {
+		return body.Error.Message
+	}
+	return body.Message
+}
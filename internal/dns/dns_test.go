@@ -0,0 +1,82 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/testutils"
+)
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+
+	api := testutils.NewFakeDNS(t, "token", "example.com", "example.org")
+	client := NewClient(api.URL(), "token")
+
+	zone, err := client.Zone(ctx, "example.com")
+	require.NoError(t, err)
+	require.NotNil(t, zone)
+	assert.Equal(t, "example.com", zone.Name)
+
+	// More records than a page
+	for i := range perPage + 5 {
+		_, err := client.CreateRecord(ctx, Record{ZoneID: zone.ID, Type: "A", Name: fmt.Sprintf("host-%d", i), Value: "192.0.2.1"})
+		require.NoError(t, err)
+	}
+
+	record, err := client.CreateRecord(ctx, Record{ZoneID: zone.ID, Type: "TXT", Name: "host-0", Value: "owner", TTL: 60})
+	require.NoError(t, err)
+	assert.NotEmpty(t, record.ID)
+	assert.Equal(t, 60, record.TTL)
+
+	records, err := client.Records(ctx, zone.ID)
+	require.NoError(t, err)
+	assert.Len(t, records, perPage+6)
+
+	require.NoError(t, client.DeleteRecord(ctx, record.ID))
+
+	records, err = client.Records(ctx, zone.ID)
+	require.NoError(t, err)
+	assert.Len(t, records, perPage+5)
+
+	err = client.DeleteRecord(ctx, record.ID)
+	require.EqualError(t, err, "dns: record not found (status code 404)")
+	assert.True(t, IsNotFound(err))
+}
+
+func TestClientZone(t *testing.T) {
+	ctx := context.Background()
+
+	api := testutils.NewFakeDNS(t, "token", "example.com")
+
+	t.Run("not found", func(t *testing.T) {
+		client := NewClient(api.URL(), "token")
+
+		zone, err := client.Zone(ctx, "example.net")
+		require.NoError(t, err)
+		assert.Nil(t, zone)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		client := NewClient(api.URL(), "invalid")
+
+		_, err := client.Zone(ctx, "example.com")
+		require.EqualError(t, err, "dns: invalid authentication credentials (status code 401)")
+		assert.False(t, IsNotFound(err))
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		api.SetFailStatus(http.StatusInternalServerError)
+		defer api.SetFailStatus(0)
+
+		client := NewClient(api.URL(), "token")
+
+		_, err := client.Zone(ctx, "example.com")
+		require.EqualError(t, err, "dns: injected failure (status code 500)")
+	})
+}
@@ -0,0 +1,140 @@
+package eventlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// Type is the type of a lifecycle [Event].
+type Type string
+
+const (
+	// InstanceRequested is recorded when the creation of an instance is requested.
+	InstanceRequested Type = "instance_requested"
+	// ServerTypeFallback is recorded when a server type is unavailable, and the next
+	// server type is tried.
+	ServerTypeFallback Type = "server_type_fallback"
+	// InstanceCreated is recorded when an instance is created.
+	InstanceCreated Type = "instance_created"
+	// InstanceCreateFailed is recorded when an instance failed to be created.
+	InstanceCreateFailed Type = "instance_create_failed"
+	// InstanceCleanup is recorded when an instance that failed to be created is cleaned up.
+	InstanceCleanup Type = "instance_cleanup"
+	// InstanceDeleted is recorded when an instance is deleted.
+	InstanceDeleted Type = "instance_deleted"
+	// InstanceDeleteFailed is recorded when an instance failed to be deleted.
+	InstanceDeleteFailed Type = "instance_delete_failed"
+	// InstanceStateChanged is recorded when the state of an instance reported to the
+	// autoscaler changed.
+	InstanceStateChanged Type = "instance_state_changed"
+	// DanglingVolumeDeleted is recorded when a volume without server is deleted.
+	DanglingVolumeDeleted Type = "dangling_volume_deleted"
+)
+
+// Event is a lifecycle event, recorded as a JSON line.
+type Event struct {
+	Time          time.Time `json:"time"`
+	Type          Type      `json:"event"`
+	InstanceGroup string    `json:"instance_group"`
+
+	IID        string `json:"iid,omitempty"`
+	Name       string `json:"name,omitempty"`
+	ID         int64  `json:"id,omitempty"`
+	ServerType string `json:"server_type,omitempty"`
+	VolumeID   int64  `json:"volume_id,omitempty"`
+
+	State         string `json:"state,omitempty"`
+	PreviousState string `json:"previous_state,omitempty"`
+
+	// StartedAt is the time at which the recorded operation started.
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	// Duration is the duration of the recorded operation, in seconds.
+	Duration float64 `json:"duration_seconds,omitempty"`
+
+	Handler   string `json:"handler,omitempty"`
+	Cause     string `json:"error_cause,omitempty"`
+	ErrorCode string `json:"error_code,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Since sets the start time and the duration of the event operation.
+func (e Event) Since(start time.Time) Event {
+	e.StartedAt = &start
+	e.Duration = time.Since(start).Seconds()
+	return e
+}
+
+// Log appends the lifecycle events to a file. A nil Log discards the events.
+type Log struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+
+	instanceGroup string
+	log           hclog.Logger
+}
+
+// Open opens the event log file in append mode, creating it if necessary.
+func Open(path string, instanceGroup string, log hclog.Logger) (*Log, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open event log: %w", err)
+	}
+
+	return &Log{
+		file:          file,
+		enc:           json.NewEncoder(file),
+		instanceGroup: instanceGroup,
+		log:           log,
+	}, nil
+}
+
+// Record appends an event to the log. Write errors are only logged, so the events
+// never interrupt the instances lifecycle.
+func (l *Log) Record(event Event) {
+	if l == nil {
+		return
+	}
+
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	event.InstanceGroup = l.instanceGroup
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return
+	}
+
+	if err := l.enc.Encode(event); err != nil {
+		l.log.Warn("could not record event", "event", event.Type, "error", err)
+	}
+}
+
+// Close closes the event log file.
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+
+	err := l.file.Close()
+	l.file = nil
+	if err != nil {
+		return fmt.Errorf("could not close event log: %w", err)
+	}
+	return nil
+}
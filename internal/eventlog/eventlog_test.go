@@ -0,0 +1,58 @@
+package eventlog
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte(`{"event":"previous"}`+"\n"), 0o644))
+
+	log, err := Open(path, "fleeting", hclog.NewNullLogger())
+	require.NoError(t, err)
+
+	start := time.Now().Add(-time.Minute)
+	log.Record(Event{Type: InstanceRequested, Name: "fleeting-a"})
+	log.Record(Event{Type: InstanceCreated, IID: "fleeting-a:1", Name: "fleeting-a", ID: 1}.Since(start))
+	require.NoError(t, log.Close())
+
+	// Events recorded after close are discarded
+	log.Record(Event{Type: InstanceDeleted})
+	require.NoError(t, log.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 3)
+	assert.JSONEq(t, `{"event":"previous"}`, lines[0])
+
+	var requested Event
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &requested))
+	assert.Equal(t, InstanceRequested, requested.Type)
+	assert.Equal(t, "fleeting", requested.InstanceGroup)
+	assert.False(t, requested.Time.IsZero())
+	assert.Nil(t, requested.StartedAt)
+
+	var created Event
+	require.NoError(t, json.Unmarshal([]byte(lines[2]), &created))
+	assert.Equal(t, InstanceCreated, created.Type)
+	assert.Equal(t, "fleeting-a:1", created.IID)
+	assert.True(t, start.Equal(*created.StartedAt))
+	assert.GreaterOrEqual(t, created.Duration, time.Minute.Seconds())
+}
+
+func TestNilLog(t *testing.T) {
+	var log *Log
+	log.Record(Event{Type: InstanceRequested})
+	require.NoError(t, log.Close())
+}
@@ -0,0 +1,30 @@
+package instancegroup
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// waitForActions waits for the actions of an operation to complete, for at most the
+// timeout after the operation was requested when the timeout is not zero. A
+// [TimeoutError] is returned once the timeout is reached.
+//
+// The timeout starts when the operation is requested, so the waits of the instances
+// overlap, and the scale operation is delayed at most once by the timeout.
+func (g *instanceGroup) waitForActions(ctx context.Context, operation string, timeout time.Duration, requested time.Time, actions ...*hcloud.Action) error {
+	if timeout == 0 {
+		return g.client.Action.WaitFor(ctx, actions...)
+	}
+
+	waitCtx, cancel := context.WithDeadline(ctx, requested.Add(timeout))
+	defer cancel()
+
+	err := g.client.Action.WaitFor(waitCtx, actions...)
+	if err != nil && ctx.Err() == nil && errors.Is(waitCtx.Err(), context.DeadlineExceeded) {
+		return &TimeoutError{Operation: operation, Timeout: timeout}
+	}
+	return err
+}
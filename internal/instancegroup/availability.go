@@ -0,0 +1,74 @@
+package instancegroup
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// availabilityCacheTTL is the duration during which the server types availability is
+// cached.
+const availabilityCacheTTL = time.Minute
+
+// serverTypesAvailability holds the server types supported and currently available in
+// the instance group location, indexed by server type ID.
+type serverTypesAvailability struct {
+	supported map[int64]bool
+	available map[int64]bool
+}
+
+// availabilityCache caches the server types availability of the instance group.
+type availabilityCache struct {
+	mu        sync.Mutex
+	value     *serverTypesAvailability
+	fetchedAt time.Time
+}
+
+// serverTypesAvailability returns the server types availability in the instance group
+// location, using the datacenters of the location.
+func (g *instanceGroup) serverTypesAvailability(ctx context.Context) (*serverTypesAvailability, error) {
+	datacenters, err := g.client.Datacenter.All(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not list datacenters: %w", err)
+	}
+
+	result := &serverTypesAvailability{
+		supported: make(map[int64]bool),
+		available: make(map[int64]bool),
+	}
+	for _, datacenter := range datacenters {
+		if datacenter.Location == nil || datacenter.Location.ID != g.location.ID {
+			continue
+		}
+		for _, serverType := range datacenter.ServerTypes.Supported {
+			result.supported[serverType.ID] = true
+		}
+		for _, serverType := range datacenter.ServerTypes.Available {
+			result.available[serverType.ID] = true
+		}
+	}
+
+	return result, nil
+}
+
+// cachedServerTypesAvailability returns the server types availability, refreshed when
+// older than [availabilityCacheTTL].
+func (g *instanceGroup) cachedServerTypesAvailability(ctx context.Context) (*serverTypesAvailability, error) {
+	g.availability.mu.Lock()
+	defer g.availability.mu.Unlock()
+
+	if g.availability.value != nil && time.Since(g.availability.fetchedAt) < availabilityCacheTTL {
+		return g.availability.value, nil
+	}
+
+	value, err := g.serverTypesAvailability(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	g.availability.value = value
+	g.availability.fetchedAt = time.Now()
+
+	return value, nil
+}
@@ -0,0 +1,111 @@
+package instancegroup
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// instanceCache caches the instances of the instance group, filled by
+// [instanceGroup.List] and read by [instanceGroup.Get].
+//
+// The zero value is disabled, as is a cache with a zero TTL.
+type instanceCache struct {
+	mu  sync.Mutex
+	ttl time.Duration
+
+	// instances holds the cached instances, indexed by server ID.
+	instances map[int64]*Instance
+	fetchedAt time.Time
+}
+
+func (c *instanceCache) fresh() bool {
+	return c.ttl > 0 && c.instances != nil && time.Since(c.fetchedAt) < c.ttl
+}
+
+// list returns the cached instances sorted by ID, or false when the cache is stale.
+func (c *instanceCache) list() ([]*Instance, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.fresh() {
+		return nil, false
+	}
+
+	instances := make([]*Instance, 0, len(c.instances))
+	for _, id := range slices.Sorted(maps.Keys(c.instances)) {
+		instances = append(instances, c.instances[id])
+	}
+	return instances, true
+}
+
+// get returns a cached instance, or false when the instance is not cached.
+func (c *instanceCache) get(id int64) (*Instance, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.fresh() {
+		return nil, false
+	}
+
+	instance, ok := c.instances[id]
+	return instance, ok
+}
+
+// fill replaces the cached instances.
+func (c *instanceCache) fill(instances []*Instance) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ttl == 0 {
+		return
+	}
+
+	c.instances = make(map[int64]*Instance, len(instances))
+	for _, instance := range instances {
+		c.instances[instance.ID] = instance
+	}
+	c.fetchedAt = time.Now()
+}
+
+// add adds or updates instances in a fresh cache.
+func (c *instanceCache) add(instances ...*Instance) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.fresh() {
+		return
+	}
+
+	for _, instance := range instances {
+		if instance.Server == nil {
+			// Only complete instances are cached, refresh the cache on the next list.
+			c.instances = nil
+			return
+		}
+		c.instances[instance.ID] = instance
+	}
+}
+
+// remove removes instances from a fresh cache.
+func (c *instanceCache) remove(instances ...*Instance) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.fresh() {
+		return
+	}
+
+	for _, instance := range instances {
+		delete(c.instances, instance.ID)
+	}
+}
+
+// invalidate drops the cached instances, for example after an error.
+func (c *instanceCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.instances = nil
+}
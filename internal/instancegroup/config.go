@@ -1,5 +1,71 @@
 package instancegroup
 
+import (
+	"time"
+
+	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/eventlog"
+)
+
+const (
+	// RecycleModeDelete deletes the servers of the removed instances.
+	RecycleModeDelete = "delete"
+	// RecycleModeRebuild keeps the servers of the removed instances for a short time,
+	// and rebuilds them from the image to replace the next instances.
+	RecycleModeRebuild = "rebuild"
+)
+
+const (
+	// HookOnFailureFail fails the instance when the hook fails.
+	HookOnFailureFail = "fail"
+	// HookOnFailureLog only logs the hook failures.
+	HookOnFailureLog = "log"
+
+	// DefaultHookTimeout is the default maximum duration of a hook.
+	DefaultHookTimeout = time.Minute
+)
+
+// Hook is a local command run for each instance, with the instance JSON on stdin, see
+// [HookPayload].
+type Hook struct {
+	// Command is the path of the executable to run, followed by its arguments.
+	Command []string
+	// Timeout is the maximum duration of the command, after which the command is
+	// killed. Defaults to [DefaultHookTimeout].
+	Timeout time.Duration
+	// OnFailure defines how a failure of the command is handled, either
+	// [HookOnFailureFail] or [HookOnFailureLog]. Defaults to [HookOnFailureFail].
+	OnFailure string
+}
+
+// DNSConfig configures the DNS records of the instances, managed using the Hetzner DNS
+// API.
+type DNSConfig struct {
+	// Endpoint of the Hetzner DNS API. Defaults to the public endpoint.
+	Endpoint string
+	// Token of the Hetzner DNS API.
+	Token string
+	// Zone is the name of the zone in which the records are created.
+	Zone string
+	// RecordTemplate is a Go template used to generate the record names, relative to
+	// the zone, see [DNSRecordTemplateData]. Defaults to [DefaultDNSRecordTemplate].
+	RecordTemplate string
+	// TTL of the records in seconds. Defaults to [DefaultDNSRecordTTL].
+	TTL int
+}
+
+// Hooks are the hooks run around the instances creation and deletion. A hook is not
+// run if nil.
+type Hooks struct {
+	// PreCreate is run before the resources of the instance are created.
+	PreCreate *Hook
+	// PostCreate is run once the server of the instance is created.
+	PostCreate *Hook
+	// PreDelete is run before the server of the instance is deleted.
+	PreDelete *Hook
+	// PostDelete is run once the resources of the instance are deleted.
+	PostDelete *Hook
+}
+
 type Config struct {
 	// Location is the Hetzner Cloud "Location" (name or id) to create the server in.
 	// Run `hcloud location list` to list available locations.
@@ -21,6 +87,10 @@ type Config struct {
 	// with. Run `hcloud ssh-key list` to list available ssh-keys.
 	SSHKeys []string
 
+	// SSHKeyUserLabel is the label referencing the instance group on the SSH keys it
+	// uses. The leaked SSH keys with this label are released by the sanity checks.
+	SSHKeyUserLabel string
+
 	// PublicIPv4Disabled disables the server public IPv4.
 	PublicIPv4Disabled bool
 	// PublicIPv6Disabled disables the server public IPv6.
@@ -36,9 +106,86 @@ type Config struct {
 	// the server. Run `hcloud network list` to list available ssh-keys.
 	PrivateNetworks []string
 
+	// LoadBalancers is a list of Hetzner Cloud "Load Balancer" (name or id) to register
+	// the servers as targets of. Run `hcloud load-balancer list` to list available load
+	// balancers.
+	LoadBalancers []string
+	// LoadBalancerUsePrivateIP makes the load balancers reach the servers using their
+	// private IP, the load balancers must be attached to one of the private networks.
+	LoadBalancerUsePrivateIP bool
+
 	// VolumeSize is the size in GB of the volume that will be attached to the server.
 	VolumeSize int
 
+	// InstanceCacheTTL is the duration during which the instances are cached between
+	// the list and get calls. The instances are not cached if zero.
+	InstanceCacheTTL time.Duration
+
+	// AsyncIncrease returns from the increase once the servers creation is requested,
+	// without waiting for the servers to be created.
+	AsyncIncrease bool
+
+	// RecycleMode defines how the instances are removed during a decrease, either
+	// [RecycleModeDelete] or [RecycleModeRebuild]. Defaults to [RecycleModeDelete].
+	RecycleMode string
+
+	// ParkingEnabled keeps the servers of the removed instances until the end of their
+	// billed hour, and reuses them for the next instances.
+	ParkingEnabled bool
+	// ParkingMargin is the billed time left under which a parked server is deleted.
+	// Defaults to [DefaultParkingMargin].
+	ParkingMargin time.Duration
+
+	// GracefulShutdownTimeout is the maximum duration to wait for the server to power
+	// off after an ACPI shutdown request, before deleting the server. The server is
+	// deleted right away if zero.
+	GracefulShutdownTimeout time.Duration
+
+	// ServerCreateTimeout is the maximum duration to wait for the server creation, the
+	// instance is failed and cleaned up once reached. No timeout if zero.
+	ServerCreateTimeout time.Duration
+	// VolumeCreateTimeout is the maximum duration to wait for the volume creation, the
+	// instance is failed and cleaned up once reached. No timeout if zero.
+	VolumeCreateTimeout time.Duration
+	// ServerDeleteTimeout is the maximum duration to wait for the server deletion, the
+	// instance deletion is failed once reached. No timeout if zero.
+	ServerDeleteTimeout time.Duration
+
+	// ProtectInstances enables the delete protection of the servers and volumes of the
+	// instances, so they cannot be deleted out of band. The protection is disabled right
+	// before the instance group deletes them.
+	ProtectInstances bool
+
+	// Hooks are the local commands run around the instances creation and deletion.
+	Hooks Hooks
+
+	// DNS configures the DNS records of the instances, the records are not managed if
+	// nil.
+	DNS *DNSConfig
+
+	// EventLog records the lifecycle events of the instances, the events are discarded
+	// if nil.
+	EventLog *eventlog.Log
+
+	// WindowsEnabled configures the administrator account of the instances using a
+	// Cloudbase-Init user data script.
+	WindowsEnabled bool
+	// WindowsUsername is the name of the administrator account configured on Windows
+	// instances.
+	WindowsUsername string
+	// WindowsPassword is the password of the administrator account configured on
+	// Windows instances. A random password is generated for each instance if empty.
+	WindowsPassword string
+
+	// NameTemplate is a Go template used to generate the instance names, see
+	// [NameTemplateData] for the available values. Defaults to [DefaultNameTemplate].
+	NameTemplate string
+
 	// Labels is a map of key value pairs to create the server with.
 	Labels map[string]string
+
+	// OwnerToken identifies the owner of the instance group resources. Resources of
+	// another owner using the same instance group name are ignored. The resources are
+	// not labeled with an owner if empty.
+	OwnerToken string
 }
@@ -0,0 +1,178 @@
+package instancegroup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+
+	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/ippool"
+)
+
+// ErrInstanceNotFound is returned when the server of an instance does not exist, or
+// does not belong to the instance group.
+var ErrInstanceNotFound = errors.New("instance not found")
+
+// ErrorCause classifies the cause of an [InstanceError].
+type ErrorCause string
+
+const (
+	// CauseUnknown is used when the cause could not be classified.
+	CauseUnknown ErrorCause = "unknown"
+	// CauseUnavailable is used when the requested resources are currently not available,
+	// for example when a server type is out of stock in the location.
+	CauseUnavailable ErrorCause = "unavailable"
+	// CauseLimitExceeded is used when a limit of the project is reached.
+	CauseLimitExceeded ErrorCause = "limit_exceeded"
+	// CauseTransient is used for errors that are likely to succeed when retried.
+	CauseTransient ErrorCause = "transient"
+	// CauseInvalidConfig is used when the request was rejected, most likely because of
+	// the instance group config.
+	CauseInvalidConfig ErrorCause = "invalid_config"
+)
+
+// InstanceError is returned when a handler failed for an instance.
+type InstanceError struct {
+	// Name of the instance.
+	Name string
+	// ID of the instance server, 0 when the server was not created.
+	ID int64
+	// Handler is the name of the handler that failed, empty when the error happened
+	// outside of a handler.
+	Handler string
+	// Cause is the classified cause of the error.
+	Cause ErrorCause
+
+	Err error
+}
+
+func (e *InstanceError) Error() string {
+	return fmt.Sprintf("instance %s: %s", e.Name, e.Err)
+}
+
+func (e *InstanceError) Unwrap() error {
+	return e.Err
+}
+
+func newInstanceError(handler any, instance *Instance, err error) *InstanceError {
+	e := &InstanceError{
+		Name:  instance.Name,
+		ID:    instance.ID,
+		Cause: classifyError(err),
+		Err:   err,
+	}
+	if handler != nil {
+		e.Handler = handlerName(handler)
+	}
+	return e
+}
+
+// TimeoutError is returned when the actions of an operation did not complete within
+// the configured timeout.
+type TimeoutError struct {
+	// Operation that timed out, for example "server creation".
+	Operation string
+	Timeout   time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s timed out after %s", e.Operation, e.Timeout)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// handlerName returns the type name of a handler.
+func handlerName(handler any) string {
+	return reflect.TypeOf(handler).Elem().Name()
+}
+
+// InstanceErrors returns the instance errors found in an error returned by
+// [InstanceGroup.Increase] or [InstanceGroup.Decrease].
+func InstanceErrors(err error) []*InstanceError {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		result := make([]*InstanceError, 0)
+		for _, err := range joined.Unwrap() {
+			result = append(result, InstanceErrors(err)...)
+		}
+		return result
+	}
+
+	var instanceErr *InstanceError
+	if errors.As(err, &instanceErr) {
+		return []*InstanceError{instanceErr}
+	}
+
+	return nil
+}
+
+// classifyError returns the cause of an error returned by the API client.
+func classifyError(err error) ErrorCause {
+	var netErr net.Error
+
+	code := errorCode(err)
+
+	switch {
+	case code != "":
+	case errors.Is(err, ippool.ErrEmpty):
+		return CauseUnavailable
+	case errors.Is(err, hcloud.ErrStatusCode):
+		// Responses without a valid error body, for example from a proxy.
+		return CauseTransient
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr):
+		return CauseTransient
+	default:
+		return CauseUnknown
+	}
+
+	switch code {
+	case hcloud.ErrorCodeResourceUnavailable,
+		hcloud.ErrorCodePlacementError,
+		hcloud.ErrorCodeNoSpaceLeftInLocation:
+		return CauseUnavailable
+
+	case hcloud.ErrorCodeResourceLimitExceeded:
+		return CauseLimitExceeded
+
+	case hcloud.ErrorCodeRateLimitExceeded,
+		hcloud.ErrorCodeServiceError,
+		hcloud.ErrorCodeUnknownError,
+		hcloud.ErrorCodeConflict,
+		hcloud.ErrorCodeLocked,
+		hcloud.ErrorCodeMaintenance:
+		return CauseTransient
+
+	case hcloud.ErrorCodeInvalidInput,
+		hcloud.ErrorCodeJSONError,
+		hcloud.ErrorCodeForbidden,
+		hcloud.ErrorCodeUnauthorized,
+		hcloud.ErrorCodeNotFound,
+		hcloud.ErrorCodeInvalidServerType,
+		hcloud.ErrorCodeUniquenessError:
+		return CauseInvalidConfig
+
+	default:
+		return CauseUnknown
+	}
+}
+
+// errorCode returns the API error code of an error, or an empty code when the error
+// was not returned by the API.
+func errorCode(err error) hcloud.ErrorCode {
+	var apiErr hcloud.Error
+	var actionErr hcloud.ActionError
+
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr.Code
+	case errors.As(err, &actionErr):
+		return hcloud.ErrorCode(actionErr.Code)
+	default:
+		return ""
+	}
+}
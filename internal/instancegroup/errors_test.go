@@ -0,0 +1,69 @@
+package instancegroup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+
+	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/ippool"
+)
+
+func TestClassifyError(t *testing.T) {
+	testCases := []struct {
+		err  error
+		want ErrorCause
+	}{
+		{err: hcloud.Error{Code: hcloud.ErrorCodeResourceUnavailable}, want: CauseUnavailable},
+		{err: hcloud.Error{Code: hcloud.ErrorCodeResourceLimitExceeded}, want: CauseLimitExceeded},
+		{err: hcloud.Error{Code: hcloud.ErrorCodeRateLimitExceeded}, want: CauseTransient},
+		{err: hcloud.Error{Code: hcloud.ErrorCodeServiceError}, want: CauseTransient},
+		{err: hcloud.Error{Code: hcloud.ErrorCodeInvalidInput}, want: CauseInvalidConfig},
+		{err: hcloud.Error{Code: "something_new"}, want: CauseUnknown},
+		{err: fmt.Errorf("could not create instance: %w", hcloud.ActionError{Code: "placement_error"}), want: CauseUnavailable},
+		{err: fmt.Errorf("could not get ipv4 from pool: %w", ippool.ErrEmpty), want: CauseUnavailable},
+		{err: fmt.Errorf("hcloud: %w", hcloud.ErrStatusCode), want: CauseTransient},
+		{err: context.DeadlineExceeded, want: CauseTransient},
+		{err: fmt.Errorf("some error"), want: CauseUnknown},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.err.Error(), func(t *testing.T) {
+			assert.Equal(t, testCase.want, classifyError(testCase.err))
+		})
+	}
+}
+
+func TestInstanceErrors(t *testing.T) {
+	errA := &InstanceError{Name: "fleeting-a", Cause: CauseTransient, Err: fmt.Errorf("a")}
+	errB := &InstanceError{Name: "fleeting-b", Cause: CauseUnavailable, Err: fmt.Errorf("b")}
+
+	require.Nil(t, InstanceErrors(nil))
+	require.Nil(t, InstanceErrors(fmt.Errorf("some error")))
+	require.Equal(t, []*InstanceError{errA}, InstanceErrors(fmt.Errorf("wrapped: %w", errA)))
+	require.Equal(t, []*InstanceError{errA, errB}, InstanceErrors(errors.Join(errA, fmt.Errorf("other"), errB)))
+
+	require.EqualError(t, errA, "instance fleeting-a: a")
+}
+
+func TestIncreaseInstanceErrors(t *testing.T) {
+	ctx := context.Background()
+	config := DefaultTestConfig
+
+	group, api := setupInstanceGroupWithFakeAPI(t, config)
+	api.SetAvailableServerTypes("hel1-dc2")
+
+	_, err := group.Increase(ctx, 1)
+	require.Error(t, err)
+
+	instanceErrs := InstanceErrors(err)
+	require.Len(t, instanceErrs, 1)
+	require.Equal(t, "fleeting-a", instanceErrs[0].Name)
+	require.Equal(t, "ServerHandler", instanceErrs[0].Handler)
+	require.Equal(t, CauseUnavailable, instanceErrs[0].Cause)
+	require.True(t, hcloud.IsError(err, hcloud.ErrorCodeResourceUnavailable))
+}
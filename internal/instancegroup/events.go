@@ -0,0 +1,40 @@
+package instancegroup
+
+import (
+	"errors"
+	"time"
+
+	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/eventlog"
+)
+
+// record records a lifecycle event of an instance in the event log. The duration is
+// set when the start time is not zero, and the error details when the error is not nil.
+func (g *instanceGroup) record(typ eventlog.Type, instance *Instance, start time.Time, err error) {
+	if g.config.EventLog == nil {
+		return
+	}
+
+	event := eventlog.Event{Type: typ, Name: instance.Name, ID: instance.ID, ServerType: instance.serverType()}
+	if instance.ID != 0 {
+		event.IID = instance.IID()
+	}
+
+	if !start.IsZero() {
+		event = event.Since(start)
+	}
+
+	if err != nil {
+		event.Error = err.Error()
+		event.ErrorCode = string(errorCode(err))
+
+		var instanceErr *InstanceError
+		if errors.As(err, &instanceErr) {
+			event.Handler = instanceErr.Handler
+			event.Cause = string(instanceErr.Cause)
+		} else {
+			event.Cause = string(classifyError(err))
+		}
+	}
+
+	g.config.EventLog.Record(event)
+}
@@ -11,9 +11,10 @@ type BaseHandler struct{}
 
 var _ CreateHandler = (*BaseHandler)(nil)
 
-func (h *BaseHandler) Create(_ context.Context, _ *instanceGroup, instance *Instance) error {
+func (h *BaseHandler) Create(_ context.Context, group *instanceGroup, instance *Instance) error {
 	instance.opts = &hcloud.ServerCreateOpts{}
 	instance.opts.PublicNet = &hcloud.ServerCreatePublicNet{}
+	instance.opts.UserData = group.config.UserData
 
 	return nil
 }
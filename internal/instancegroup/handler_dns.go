@@ -0,0 +1,319 @@
+package instancegroup
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+
+	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/dns"
+)
+
+const (
+	// DefaultDNSRecordTemplate is the template used to name the records when no
+	// template is configured.
+	DefaultDNSRecordTemplate = "{{ .Name }}"
+	// DefaultDNSRecordTTL is the TTL of the records when no TTL is configured.
+	DefaultDNSRecordTTL = 60
+)
+
+// dnsHeritage identifies the TXT records owning the records of the instances.
+const dnsHeritage = "fleeting"
+
+// DNSRecordTemplateData holds the values available in the DNS record template.
+type DNSRecordTemplateData struct {
+	// Name of the instance.
+	Name string
+	// ID of the instance server.
+	ID int64
+	// InstanceGroup is the name of the instance group.
+	InstanceGroup string
+}
+
+// DNSHandler creates the A and AAAA records of the instances once their servers are
+// created, and deletes them with the instances.
+//
+// The records are owned using a TXT record with the same name, holding the instance
+// group, the owner and the server of the records. A DNS failure never fails the
+// instance, the leaked records are deleted by the sanity checks.
+type DNSHandler struct {
+	// records are the records of the zone, listed once per handler.
+	records []dns.Record
+}
+
+var _ CreateHandler = (*DNSHandler)(nil)
+var _ CleanupHandler = (*DNSHandler)(nil)
+var _ SanityHandler = (*DNSHandler)(nil)
+
+func (h *DNSHandler) Create(ctx context.Context, group *instanceGroup, instance *Instance) error {
+	if group.dns == nil || instance.ID == 0 {
+		return nil
+	}
+
+	if err := group.createRecords(ctx, instance); err != nil {
+		group.log.Warn("could not create instance dns records", "name", instance.Name, "id", instance.ID, "error", err)
+	}
+
+	return nil
+}
+
+func (h *DNSHandler) Cleanup(ctx context.Context, group *instanceGroup, instance *Instance) error {
+	if group.dns == nil || instance.ID == 0 {
+		return nil
+	}
+
+	if h.records == nil {
+		records, err := group.dns.Records(ctx, group.dnsZone.ID)
+		if err != nil {
+			group.log.Warn("could not delete instance dns records", "name", instance.Name, "id", instance.ID, "error", fmt.Errorf("could not list dns records: %w", err))
+			return nil
+		}
+		h.records = records
+	}
+
+	err := group.deleteRecords(ctx, h.records, func(serverID int64, _ string) bool {
+		return serverID == instance.ID
+	})
+	if err != nil {
+		group.log.Warn("could not delete instance dns records", "name", instance.Name, "id", instance.ID, "error", err)
+	}
+
+	return nil
+}
+
+// Sanity deletes the records of the servers that no longer exist, or that were renamed.
+func (h *DNSHandler) Sanity(ctx context.Context, group *instanceGroup) error {
+	if group.dns == nil {
+		return nil
+	}
+
+	records, err := group.dns.Records(ctx, group.dnsZone.ID)
+	if err != nil {
+		return fmt.Errorf("could not list dns records: %w", err)
+	}
+
+	// The servers are listed after the records, so the records created in the meantime
+	// belong to listed servers.
+	servers, err := group.client.Server.AllWithOpts(ctx,
+		hcloud.ServerListOpts{
+			ListOpts: hcloud.ListOpts{
+				LabelSelector: fmt.Sprintf("instance-group=%s", group.name),
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("could not list servers: %w", err)
+	}
+
+	names := make(map[int64]string, len(servers))
+	for _, server := range servers {
+		if !group.owns("server", server.ID, server.Name, server.Labels) {
+			continue
+		}
+		name, err := group.dnsRecordName(server.Name, server.ID)
+		if err != nil {
+			return err
+		}
+		names[server.ID] = name
+	}
+
+	return group.deleteRecords(ctx, records, func(serverID int64, name string) bool {
+		expected, ok := names[serverID]
+		if !ok || expected != name {
+			group.log.Warn("deleting stale dns records", "record", name, "id", serverID)
+			return true
+		}
+		return false
+	})
+}
+
+// initDNS creates the DNS API client, and checks the zone and the record template.
+func (g *instanceGroup) initDNS(ctx context.Context) error {
+	g.dns = dns.NewClient(g.config.DNS.Endpoint, g.config.DNS.Token)
+
+	zone, err := g.dns.Zone(ctx, g.config.DNS.Zone)
+	if err != nil {
+		return fmt.Errorf("could not get dns zone: %w", err)
+	}
+	if zone == nil {
+		return fmt.Errorf("dns zone not found: %s", g.config.DNS.Zone)
+	}
+	g.dnsZone = zone
+
+	text := g.config.DNS.RecordTemplate
+	if text == "" {
+		text = DefaultDNSRecordTemplate
+	}
+	g.dnsRecordTemplate, err = template.New("record").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return fmt.Errorf("could not parse dns record template: %w", err)
+	}
+
+	// Execute the template once to detect errors early.
+	if _, err := g.dnsRecordName(g.name+"-0", 1); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// dnsRecordName returns the name of the records of an instance, relative to the zone.
+func (g *instanceGroup) dnsRecordName(name string, id int64) (string, error) {
+	var builder strings.Builder
+	err := g.dnsRecordTemplate.Execute(&builder, DNSRecordTemplateData{
+		Name:          name,
+		ID:            id,
+		InstanceGroup: g.name,
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not execute dns record template: %w", err)
+	}
+
+	// Turn each label of the record into a valid hostname label.
+	labels := strings.Split(strings.ToLower(builder.String()), ".")
+	for i, label := range labels {
+		labels[i] = sanitizeName(label)
+		if labels[i] == "" {
+			return "", fmt.Errorf("dns record template produced an invalid name: %s", builder.String())
+		}
+	}
+
+	return strings.Join(labels, "."), nil
+}
+
+// dnsOwnerValue returns the value of the TXT record owning the records of a server.
+func (g *instanceGroup) dnsOwnerValue(serverID int64) string {
+	return fmt.Sprintf("heritage=%s,instance-group=%s,owner=%s,server=%d", dnsHeritage, g.name, g.config.OwnerToken, serverID)
+}
+
+// dnsOwnedServer returns the server owning the records, when the TXT record value is
+// owned by the instance group.
+func (g *instanceGroup) dnsOwnedServer(value string) (int64, bool) {
+	fields := make(map[string]string)
+	for _, field := range strings.Split(strings.Trim(value, `"`), ",") {
+		key, value, _ := strings.Cut(field, "=")
+		fields[key] = value
+	}
+
+	if fields["heritage"] != dnsHeritage ||
+		fields["instance-group"] != g.name ||
+		fields["owner"] != g.config.OwnerToken {
+		return 0, false
+	}
+
+	serverID, err := strconv.ParseInt(fields["server"], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return serverID, true
+}
+
+// createRecords creates the owner TXT record and the A and AAAA records of an instance.
+func (g *instanceGroup) createRecords(ctx context.Context, instance *Instance) error {
+	// The server is unknown after a rebuild, and the private IPs might be assigned
+	// while the server is created.
+	if instance.Server == nil || len(dnsAddresses(instance.Server)) == 0 {
+		g.refreshServer(ctx, instance)
+	}
+	if instance.Server == nil {
+		return fmt.Errorf("instance server not found")
+	}
+
+	name, err := g.dnsRecordName(instance.Name, instance.ID)
+	if err != nil {
+		return err
+	}
+
+	ttl := g.config.DNS.TTL
+	if ttl == 0 {
+		ttl = DefaultDNSRecordTTL
+	}
+
+	// The owner record is created first, so the sanity checks find the other records
+	// when their creation fails.
+	records := []dns.Record{{Type: "TXT", Value: g.dnsOwnerValue(instance.ID)}}
+	records = append(records, dnsAddresses(instance.Server)...)
+
+	for _, record := range records {
+		record.ZoneID = g.dnsZone.ID
+		record.Name = name
+		record.TTL = ttl
+
+		if _, err := g.dns.CreateRecord(ctx, record); err != nil {
+			return fmt.Errorf("could not create dns record: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// deleteRecords deletes the A and AAAA records, and the owner TXT record, of the
+// records owned by the instance group and matching the server ID and record name.
+func (g *instanceGroup) deleteRecords(ctx context.Context, records []dns.Record, match func(serverID int64, name string) bool) error {
+	names := make(map[string]bool)
+	owners := make(map[string]bool)
+	for _, record := range records {
+		if record.Type != "TXT" {
+			continue
+		}
+		serverID, ok := g.dnsOwnedServer(record.Value)
+		if !ok || !match(serverID, record.Name) {
+			continue
+		}
+		names[record.Name] = true
+		owners[record.ID] = true
+	}
+
+	for _, record := range records {
+		if !names[record.Name] {
+			continue
+		}
+		if record.Type != "A" && record.Type != "AAAA" && !owners[record.ID] {
+			continue
+		}
+
+		if err := g.dns.DeleteRecord(ctx, record.ID); err != nil && !dns.IsNotFound(err) {
+			return fmt.Errorf("could not delete dns record: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// renameRecords replaces the records of the instances renamed after a rebuild.
+func (g *instanceGroup) renameRecords(ctx context.Context, instances []*Instance) {
+	if g.dns == nil || len(instances) == 0 {
+		return
+	}
+
+	handler := &DNSHandler{}
+	for _, instance := range instances {
+		_ = handler.Cleanup(ctx, g, instance)
+		_ = handler.Create(ctx, g, instance)
+	}
+}
+
+// dnsAddresses returns the A record of the public IPv4, or of the first private IP
+// when the public IPv4 is disabled, and the AAAA record of the public IPv6.
+func dnsAddresses(server *hcloud.Server) []dns.Record {
+	records := make([]dns.Record, 0, 2)
+
+	switch {
+	case !server.PublicNet.IPv4.IsUnspecified():
+		records = append(records, dns.Record{Type: "A", Value: server.PublicNet.IPv4.IP.String()})
+	case len(server.PrivateNet) > 0:
+		records = append(records, dns.Record{Type: "A", Value: server.PrivateNet[0].IP.String()})
+	}
+
+	if !server.PublicNet.IPv6.IsUnspecified() {
+		if network, ok := netip.AddrFromSlice(server.PublicNet.IPv6.IP); ok {
+			records = append(records, dns.Record{Type: "AAAA", Value: network.Next().String()})
+		}
+	}
+
+	return records
+}
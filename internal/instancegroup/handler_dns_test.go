@@ -0,0 +1,225 @@
+package instancegroup
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/testutils"
+)
+
+func setupInstanceGroupWithFakeDNS(t *testing.T, config Config) (*instanceGroup, *testutils.FakeAPI, *testutils.FakeDNS) {
+	t.Helper()
+
+	dnsAPI := testutils.NewFakeDNS(t, "token", "example.com")
+
+	config.DNS = &DNSConfig{
+		Endpoint:       dnsAPI.URL(),
+		Token:          "token",
+		Zone:           "example.com",
+		RecordTemplate: "{{ .Name }}.runners",
+	}
+
+	group, api := setupInstanceGroupWithFakeAPI(t, config)
+
+	return group, api, dnsAPI
+}
+
+// recordsByName returns the records types and values, indexed by record name.
+func recordsByName(dnsAPI *testutils.FakeDNS) map[string][]string {
+	result := make(map[string][]string)
+	for _, record := range dnsAPI.Records() {
+		result[record.Name] = append(result[record.Name], record.Type+" "+record.Value)
+	}
+	return result
+}
+
+func TestDNSHandler(t *testing.T) {
+	t.Run("increase and decrease", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.OwnerToken = "runner"
+
+		group, api, dnsAPI := setupInstanceGroupWithFakeDNS(t, config)
+
+		// Foreign records are never touched
+		dnsAPI.AddRecord(testutils.FakeDNSRecord{ZoneID: dnsAPI.ZoneID("example.com"), Type: "A", Name: "www", Value: "192.0.2.1"})
+
+		created, err := group.Increase(ctx, 2)
+		require.NoError(t, err)
+		require.Len(t, created, 2)
+
+		servers := api.Servers()
+		require.Len(t, servers, 2)
+
+		records := recordsByName(dnsAPI)
+		require.Len(t, records, 3)
+		for _, server := range servers {
+			values := records[server.Name+".runners"]
+			require.Len(t, values, 2, server.Name)
+			assert.Equal(t, "AAAA "+strings.TrimSuffix(server.PublicNet.IPv6.IP, "/64")+"1", values[0])
+			assert.Regexp(t, `^TXT heritage=fleeting,instance-group=fleeting,owner=runner,server=\d+$`, values[1])
+		}
+		for _, record := range dnsAPI.Records() {
+			if record.Name != "www" {
+				assert.Equal(t, DefaultDNSRecordTTL, record.TTL)
+			}
+		}
+
+		deleted, err := group.Decrease(ctx, created)
+		require.NoError(t, err)
+		require.Len(t, deleted, 2)
+
+		assert.Equal(t, map[string][]string{"www": {"A 192.0.2.1"}}, recordsByName(dnsAPI))
+	})
+
+	t.Run("private network", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.PublicIPv6Disabled = true
+		config.PrivateNetworks = []string{"fleeting"}
+
+		dnsAPI := testutils.NewFakeDNS(t, "token", "example.com")
+		config.DNS = &DNSConfig{Endpoint: dnsAPI.URL(), Token: "token", Zone: "example.com"}
+
+		api := testutils.NewFakeAPI(t)
+		api.AddNetwork("fleeting", "10.0.0.0/16")
+
+		group := &instanceGroup{name: "fleeting", config: config, log: hclog.New(hclog.DefaultOptions), client: api.Client()}
+		group.randomNameFn = makeRandomNameFn(group.name)
+		require.NoError(t, group.Init(ctx))
+
+		created, err := group.Increase(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, created, 1)
+
+		records := recordsByName(dnsAPI)
+		require.Len(t, records["fleeting-a"], 2)
+		assert.Regexp(t, `^A 10\.0\.`, records["fleeting-a"][0])
+	})
+
+	t.Run("failure does not fail the instances", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+
+		group, api, dnsAPI := setupInstanceGroupWithFakeDNS(t, config)
+
+		dnsAPI.SetFailStatus(http.StatusInternalServerError)
+
+		created, err := group.Increase(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, created, 1)
+
+		deleted, err := group.Decrease(ctx, created)
+		require.NoError(t, err)
+		require.Len(t, deleted, 1)
+
+		require.Empty(t, api.Servers())
+		require.Empty(t, dnsAPI.Records())
+	})
+
+	t.Run("sanity deletes stale records", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+
+		group, _, dnsAPI := setupInstanceGroupWithFakeDNS(t, config)
+		zoneID := dnsAPI.ZoneID("example.com")
+
+		created, err := group.Increase(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, created, 1)
+
+		// Records of a deleted server
+		dnsAPI.AddRecord(testutils.FakeDNSRecord{ZoneID: zoneID, Type: "TXT", Name: "fleeting-z.runners", Value: `"heritage=fleeting,instance-group=fleeting,owner=,server=999"`})
+		dnsAPI.AddRecord(testutils.FakeDNSRecord{ZoneID: zoneID, Type: "AAAA", Name: "fleeting-z.runners", Value: "2001:db8::1"})
+		// Records of another instance group
+		dnsAPI.AddRecord(testutils.FakeDNSRecord{ZoneID: zoneID, Type: "TXT", Name: "other-a", Value: "heritage=fleeting,instance-group=other,owner=,server=999"})
+		dnsAPI.AddRecord(testutils.FakeDNSRecord{ZoneID: zoneID, Type: "AAAA", Name: "other-a", Value: "2001:db8::2"})
+
+		require.NoError(t, group.Sanity(ctx))
+
+		records := recordsByName(dnsAPI)
+		assert.Len(t, records, 2)
+		assert.Contains(t, records, "fleeting-a.runners")
+		assert.Contains(t, records, "other-a")
+	})
+
+	t.Run("rebuild renames the records", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.RecycleMode = RecycleModeRebuild
+
+		group, api, dnsAPI := setupInstanceGroupWithFakeDNS(t, config)
+
+		created, err := group.Increase(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, created, 1)
+
+		deleted, err := group.Decrease(ctx, created)
+		require.NoError(t, err)
+		require.Len(t, deleted, 1)
+
+		created, err = group.Increase(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, created, 1)
+
+		servers := api.Servers()
+		require.Len(t, servers, 1)
+		require.Equal(t, "fleeting-b", servers[0].Name)
+
+		records := recordsByName(dnsAPI)
+		assert.Len(t, records, 1)
+		assert.Len(t, records["fleeting-b.runners"], 2)
+	})
+
+	t.Run("zone not found", func(t *testing.T) {
+		ctx := context.Background()
+
+		dnsAPI := testutils.NewFakeDNS(t, "token", "example.com")
+
+		config := DefaultTestConfig
+		config.DNS = &DNSConfig{Endpoint: dnsAPI.URL(), Token: "token", Zone: "example.org"}
+
+		api := testutils.NewFakeAPI(t)
+		group := &instanceGroup{name: "fleeting", config: config, log: hclog.New(hclog.DefaultOptions), client: api.Client()}
+
+		require.EqualError(t, group.Init(ctx), "dns zone not found: example.org")
+	})
+}
+
+func TestDNSRecordName(t *testing.T) {
+	testCases := []struct {
+		template string
+		want     string
+		err      string
+	}{
+		{template: DefaultDNSRecordTemplate, want: "fleeting-a"},
+		{template: "{{ .Name }}.Runners_CI", want: "fleeting-a.runners-ci"},
+		{template: "{{ .InstanceGroup }}-{{ .ID }}", want: "fleeting-1"},
+		{template: "{{ .Name }}..ci", err: "dns record template produced an invalid name: fleeting-a..ci"},
+		{template: "{{ .Missing }}", err: "could not execute dns record template"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.template, func(t *testing.T) {
+			group := &instanceGroup{name: "fleeting"}
+
+			var err error
+			group.dnsRecordTemplate, err = template.New("record").Option("missingkey=error").Parse(testCase.template)
+			require.NoError(t, err)
+
+			name, err := group.dnsRecordName("fleeting-a", 1)
+			if testCase.err != "" {
+				require.ErrorContains(t, err, testCase.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, testCase.want, name)
+		})
+	}
+}
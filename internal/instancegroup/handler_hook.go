@@ -0,0 +1,212 @@
+package instancegroup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	hookPreCreate  = "pre_create"
+	hookPostCreate = "post_create"
+	hookPreDelete  = "pre_delete"
+	hookPostDelete = "post_delete"
+)
+
+// hookWaitDelay is the delay to wait for the output of a hook to be closed, after the
+// hook exited or was killed.
+const hookWaitDelay = time.Second
+
+// HookPayload is the instance JSON written on the stdin of the hooks.
+type HookPayload struct {
+	// Hook is the name of the hook, e.g. "pre_create".
+	Hook          string `json:"hook"`
+	InstanceGroup string `json:"instance_group"`
+
+	Name       string `json:"name"`
+	ID         int64  `json:"id,omitempty"`
+	IID        string `json:"iid,omitempty"`
+	ServerType string `json:"server_type,omitempty"`
+
+	PublicIPv4 string   `json:"public_ipv4,omitempty"`
+	PublicIPv6 string   `json:"public_ipv6,omitempty"`
+	PrivateIPs []string `json:"private_ips,omitempty"`
+
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// CreateHookHandler runs the pre create or post create hook on the instances.
+type CreateHookHandler struct {
+	phase string
+}
+
+var _ CreateHandler = (*CreateHookHandler)(nil)
+
+func (h *CreateHookHandler) Create(ctx context.Context, group *instanceGroup, instance *Instance) error {
+	hook := group.hook(h.phase)
+	if hook == nil {
+		return nil
+	}
+
+	// The private IPs are assigned while the server is created, refresh the server so
+	// the hook gets them.
+	if h.phase == hookPostCreate && instance.ID != 0 {
+		group.refreshServer(ctx, instance)
+	}
+
+	return group.startHook(ctx, h.phase, hook, instance)
+}
+
+// DeleteHookHandler runs the pre delete or post delete hook on the instances.
+//
+// The pre delete handler also populates the instances with their servers, so the
+// delete hooks get the instance IPs and labels.
+type DeleteHookHandler struct {
+	phase string
+}
+
+var _ CleanupHandler = (*DeleteHookHandler)(nil)
+
+func (h *DeleteHookHandler) Cleanup(ctx context.Context, group *instanceGroup, instance *Instance) error {
+	if h.phase == hookPreDelete && instance.Server == nil && instance.ID != 0 {
+		group.refreshServer(ctx, instance)
+	}
+
+	hook := group.hook(h.phase)
+	if hook == nil {
+		return nil
+	}
+
+	return group.startHook(ctx, h.phase, hook, instance)
+}
+
+// hook returns the hook of a phase, or nil if the hook is not configured.
+func (g *instanceGroup) hook(phase string) *Hook {
+	switch phase {
+	case hookPreCreate:
+		return g.config.Hooks.PreCreate
+	case hookPostCreate:
+		return g.config.Hooks.PostCreate
+	case hookPreDelete:
+		return g.config.Hooks.PreDelete
+	case hookPostDelete:
+		return g.config.Hooks.PostDelete
+	default:
+		return nil
+	}
+}
+
+// refreshServer populates the instance with its current server. A failure is only
+// logged, the hooks are then run with the known instance details.
+func (g *instanceGroup) refreshServer(ctx context.Context, instance *Instance) {
+	server, _, err := g.client.Server.GetByID(ctx, instance.ID)
+	if err != nil {
+		g.log.Warn("could not get instance server", "name", instance.Name, "id", instance.ID, "error", err)
+		return
+	}
+	if server != nil {
+		instance.Server = server
+	}
+}
+
+// startHook starts the command of a hook with the instance JSON on stdin, and defers
+// the wait for the command to exit to the instance wait function.
+func (g *instanceGroup) startHook(ctx context.Context, phase string, hook *Hook, instance *Instance) error {
+	payload, err := json.Marshal(g.hookPayload(phase, instance))
+	if err != nil {
+		return g.hookFailed(phase, hook, instance, fmt.Errorf("could not encode %s hook payload: %w", phase, err))
+	}
+
+	timeout := hook.Timeout
+	if timeout == 0 {
+		timeout = DefaultHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+
+	output := &bytes.Buffer{}
+
+	cmd := exec.CommandContext(ctx, hook.Command[0], hook.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	cmd.WaitDelay = hookWaitDelay
+
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return g.hookFailed(phase, hook, instance, fmt.Errorf("could not start %s hook: %w", phase, err))
+	}
+
+	instance.waitFn = func() error {
+		defer cancel()
+
+		err := cmd.Wait()
+		result := strings.TrimSpace(output.String())
+
+		if err != nil {
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				err = fmt.Errorf("%s hook timed out after %s", phase, timeout)
+			} else {
+				err = fmt.Errorf("%s hook failed: %w", phase, err)
+			}
+			if result != "" {
+				err = fmt.Errorf("%w: %s", err, result)
+			}
+			return g.hookFailed(phase, hook, instance, err)
+		}
+
+		if result != "" {
+			g.log.Debug("hook output", "hook", phase, "name", instance.Name, "id", instance.ID, "output", result)
+		}
+		return nil
+	}
+
+	return nil
+}
+
+// hookFailed returns the error of a hook, or only logs it when the hook failures must
+// not fail the instance.
+func (g *instanceGroup) hookFailed(phase string, hook *Hook, instance *Instance, err error) error {
+	if hook.OnFailure == HookOnFailureLog {
+		g.log.Warn("hook failed", "hook", phase, "name", instance.Name, "id", instance.ID, "error", err)
+		return nil
+	}
+	return err
+}
+
+func (g *instanceGroup) hookPayload(phase string, instance *Instance) HookPayload {
+	payload := HookPayload{
+		Hook:          phase,
+		InstanceGroup: g.name,
+		Name:          instance.Name,
+		ID:            instance.ID,
+		ServerType:    instance.serverType(),
+		Labels:        g.labels,
+	}
+	if instance.ID != 0 {
+		payload.IID = instance.IID()
+	}
+
+	if server := instance.Server; server != nil {
+		payload.Labels = server.Labels
+
+		if !server.PublicNet.IPv4.IsUnspecified() {
+			payload.PublicIPv4 = server.PublicNet.IPv4.IP.String()
+		}
+		if !server.PublicNet.IPv6.IsUnspecified() {
+			if network, ok := netip.AddrFromSlice(server.PublicNet.IPv6.IP); ok {
+				payload.PublicIPv6 = network.Next().String()
+			}
+		}
+		for _, privateNet := range server.PrivateNet {
+			payload.PrivateIPs = append(payload.PrivateIPs, privateNet.IP.String())
+		}
+	}
+
+	return payload
+}
@@ -0,0 +1,256 @@
+package instancegroup
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/exp/mockutil"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/schema"
+)
+
+// writePayloadHook returns a hook writing its payload to a file.
+func writePayloadHook(path string) *Hook {
+	return &Hook{Command: []string{"sh", "-c", `cat > "$0"`, path}}
+}
+
+func readPayload(t *testing.T, path string) HookPayload {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	var payload HookPayload
+	require.NoError(t, json.Unmarshal(data, &payload))
+	return payload
+}
+
+var hookServerGetRequest = mockutil.Request{
+	Method: "GET", Path: "/servers/1",
+	Status: 200,
+	JSON: schema.ServerGetResponse{
+		Server: schema.Server{
+			ID:         1,
+			Name:       "fleeting-a",
+			ServerType: schema.ServerType{Name: "cpx11"},
+			Labels:     map[string]string{"instance-group": "fleeting"},
+			PublicNet: schema.ServerPublicNet{
+				IPv4: schema.ServerPublicNetIPv4{IP: "201.0.113.1"},
+				IPv6: schema.ServerPublicNetIPv6{IP: "2001:db8::/64"},
+			},
+			PrivateNet: []schema.ServerPrivateNet{{Network: 1, IP: "10.0.0.2"}},
+		},
+	},
+}
+
+func TestCreateHookHandlerCreate(t *testing.T) {
+	t.Run("pre create", func(t *testing.T) {
+		ctx := context.Background()
+		path := filepath.Join(t.TempDir(), "payload.json")
+
+		config := DefaultTestConfig
+		config.Hooks.PreCreate = writePayloadHook(path)
+
+		group := setupInstanceGroup(t, config, []mockutil.Request{})
+
+		instance := NewInstance("fleeting-a")
+
+		handler := &CreateHookHandler{phase: hookPreCreate}
+		require.NoError(t, handler.Create(ctx, group, instance))
+		require.NoError(t, instance.wait())
+
+		payload := readPayload(t, path)
+		assert.Equal(t, HookPayload{
+			Hook:          "pre_create",
+			InstanceGroup: "fleeting",
+			Name:          "fleeting-a",
+			Labels:        map[string]string{"instance-group": "fleeting"},
+		}, payload)
+	})
+
+	t.Run("post create", func(t *testing.T) {
+		ctx := context.Background()
+		path := filepath.Join(t.TempDir(), "payload.json")
+
+		config := DefaultTestConfig
+		config.Hooks.PostCreate = writePayloadHook(path)
+
+		group := setupInstanceGroup(t, config, []mockutil.Request{hookServerGetRequest})
+
+		instance := &Instance{Name: "fleeting-a", ID: 1}
+
+		handler := &CreateHookHandler{phase: hookPostCreate}
+		require.NoError(t, handler.Create(ctx, group, instance))
+		require.NoError(t, instance.wait())
+
+		payload := readPayload(t, path)
+		assert.Equal(t, HookPayload{
+			Hook:          "post_create",
+			InstanceGroup: "fleeting",
+			Name:          "fleeting-a",
+			ID:            1,
+			IID:           "fleeting-a:1",
+			ServerType:    "cpx11",
+			PublicIPv4:    "201.0.113.1",
+			PublicIPv6:    "2001:db8::1",
+			PrivateIPs:    []string{"10.0.0.2"},
+			Labels:        map[string]string{"instance-group": "fleeting"},
+		}, payload)
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+
+		group := setupInstanceGroup(t, config, []mockutil.Request{})
+
+		instance := NewInstance("fleeting-a")
+
+		handler := &CreateHookHandler{phase: hookPreCreate}
+		require.NoError(t, handler.Create(ctx, group, instance))
+		assert.Nil(t, instance.waitFn)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.Hooks.PreCreate = &Hook{Command: []string{"sh", "-c", "echo 'inventory unavailable' >&2; exit 2"}}
+
+		group := setupInstanceGroup(t, config, []mockutil.Request{})
+
+		instance := NewInstance("fleeting-a")
+
+		handler := &CreateHookHandler{phase: hookPreCreate}
+		require.NoError(t, handler.Create(ctx, group, instance))
+		require.EqualError(t, instance.wait(), "pre_create hook failed: exit status 2: inventory unavailable")
+	})
+
+	t.Run("failure logged", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.Hooks.PreCreate = &Hook{Command: []string{"sh", "-c", "exit 2"}, OnFailure: HookOnFailureLog}
+
+		group := setupInstanceGroup(t, config, []mockutil.Request{})
+
+		instance := NewInstance("fleeting-a")
+
+		handler := &CreateHookHandler{phase: hookPreCreate}
+		require.NoError(t, handler.Create(ctx, group, instance))
+		require.NoError(t, instance.wait())
+	})
+
+	t.Run("failure start", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.Hooks.PreCreate = &Hook{Command: []string{filepath.Join(t.TempDir(), "missing")}}
+
+		group := setupInstanceGroup(t, config, []mockutil.Request{})
+
+		instance := NewInstance("fleeting-a")
+
+		handler := &CreateHookHandler{phase: hookPreCreate}
+		err := handler.Create(ctx, group, instance)
+		require.ErrorContains(t, err, "could not start pre_create hook")
+		assert.Nil(t, instance.waitFn)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.Hooks.PreCreate = &Hook{Command: []string{"sleep", "10"}, Timeout: 50 * time.Millisecond}
+
+		group := setupInstanceGroup(t, config, []mockutil.Request{})
+
+		instance := NewInstance("fleeting-a")
+
+		handler := &CreateHookHandler{phase: hookPreCreate}
+		require.NoError(t, handler.Create(ctx, group, instance))
+		require.EqualError(t, instance.wait(), "pre_create hook timed out after 50ms")
+	})
+}
+
+func TestDeleteHookHandlerCleanup(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		ctx := context.Background()
+		dir := t.TempDir()
+
+		config := DefaultTestConfig
+		config.Hooks.PreDelete = writePayloadHook(filepath.Join(dir, "pre_delete.json"))
+		config.Hooks.PostDelete = writePayloadHook(filepath.Join(dir, "post_delete.json"))
+
+		group := setupInstanceGroup(t, config, []mockutil.Request{hookServerGetRequest})
+
+		instance := &Instance{Name: "fleeting-a", ID: 1}
+
+		for _, handler := range []*DeleteHookHandler{{phase: hookPreDelete}, {phase: hookPostDelete}} {
+			require.NoError(t, handler.Cleanup(ctx, group, instance))
+			require.NoError(t, instance.wait())
+		}
+
+		for _, phase := range []string{hookPreDelete, hookPostDelete} {
+			payload := readPayload(t, filepath.Join(dir, phase+".json"))
+			assert.Equal(t, phase, payload.Hook)
+			assert.Equal(t, "fleeting-a:1", payload.IID)
+			assert.Equal(t, "201.0.113.1", payload.PublicIPv4)
+		}
+	})
+
+	t.Run("success post delete only", func(t *testing.T) {
+		ctx := context.Background()
+		path := filepath.Join(t.TempDir(), "payload.json")
+
+		config := DefaultTestConfig
+		config.Hooks.PostDelete = writePayloadHook(path)
+
+		group := setupInstanceGroup(t, config, []mockutil.Request{hookServerGetRequest})
+
+		instance := &Instance{Name: "fleeting-a", ID: 1}
+
+		// The pre delete handler only populates the instance server
+		handler := &DeleteHookHandler{phase: hookPreDelete}
+		require.NoError(t, handler.Cleanup(ctx, group, instance))
+		assert.Nil(t, instance.waitFn)
+		assert.NotNil(t, instance.Server)
+
+		handler = &DeleteHookHandler{phase: hookPostDelete}
+		require.NoError(t, handler.Cleanup(ctx, group, instance))
+		require.NoError(t, instance.wait())
+
+		payload := readPayload(t, path)
+		assert.Equal(t, []string{"10.0.0.2"}, payload.PrivateIPs)
+	})
+
+	t.Run("server not found", func(t *testing.T) {
+		ctx := context.Background()
+		path := filepath.Join(t.TempDir(), "payload.json")
+
+		config := DefaultTestConfig
+		config.Hooks.PreDelete = writePayloadHook(path)
+
+		group := setupInstanceGroup(t, config, []mockutil.Request{
+			{
+				Method: "GET", Path: "/servers/1",
+				Status: 404,
+				JSON: schema.ErrorResponse{
+					Error: schema.Error{Code: "not_found"},
+				},
+			},
+		})
+
+		instance := &Instance{Name: "fleeting-a", ID: 1}
+
+		handler := &DeleteHookHandler{phase: hookPreDelete}
+		require.NoError(t, handler.Cleanup(ctx, group, instance))
+		require.NoError(t, instance.wait())
+
+		payload := readPayload(t, path)
+		assert.Equal(t, "fleeting-a:1", payload.IID)
+		assert.Empty(t, payload.PublicIPv4)
+	})
+}
@@ -0,0 +1,311 @@
+package instancegroup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// loadBalancerLockedInterval is the interval between the requests on a load balancer
+// locked by a running action.
+const loadBalancerLockedInterval = time.Second
+
+// LoadBalancerHandler adds the servers of the instances as targets of the load
+// balancers once they are created, and removes them before their deletion.
+//
+// A load balancer is locked while one of its actions is running, the requests on a
+// locked load balancer are retried until the running action completes.
+type LoadBalancerHandler struct {
+	// targets holds the servers targeted by each load balancer, fetched once per
+	// handler and indexed by load balancer ID.
+	targets map[int64]map[int64]bool
+}
+
+var _ CreateHandler = (*LoadBalancerHandler)(nil)
+var _ CleanupHandler = (*LoadBalancerHandler)(nil)
+var _ SanityHandler = (*LoadBalancerHandler)(nil)
+
+func (h *LoadBalancerHandler) Create(ctx context.Context, group *instanceGroup, instance *Instance) error {
+	if len(group.loadBalancers) == 0 || instance.ID == 0 {
+		return nil
+	}
+
+	actions := make([]*hcloud.Action, 0, len(group.loadBalancers))
+	for _, loadBalancer := range group.loadBalancers {
+		action, err := group.addTarget(ctx, loadBalancer, instance.ID)
+		if err != nil {
+			return err
+		}
+		if action != nil {
+			actions = append(actions, action)
+		}
+	}
+
+	instance.waitFn = func() error {
+		if err := group.client.Action.WaitFor(ctx, actions...); err != nil {
+			return fmt.Errorf("could not add load balancer target: %w", err)
+		}
+		return nil
+	}
+
+	return nil
+}
+
+// Cleanup removes the instance server from the load balancers targets, so the server
+// stops receiving traffic before it is shut down. A failure is only logged, the
+// targets are removed with the server.
+func (h *LoadBalancerHandler) Cleanup(ctx context.Context, group *instanceGroup, instance *Instance) error {
+	if len(group.loadBalancers) == 0 || instance.ID == 0 {
+		return nil
+	}
+
+	if h.targets == nil {
+		targets, err := group.loadBalancerTargets(ctx)
+		if err != nil {
+			group.log.Warn("could not remove instance from load balancers", "name", instance.Name, "id", instance.ID, "error", err)
+			return nil
+		}
+		h.targets = targets
+	}
+
+	actions := make([]*hcloud.Action, 0, len(group.loadBalancers))
+	for _, loadBalancer := range group.loadBalancers {
+		if !h.targets[loadBalancer.ID][instance.ID] {
+			continue
+		}
+		action, err := group.removeTarget(ctx, loadBalancer, instance.ID)
+		if err != nil {
+			group.log.Warn("could not remove instance from load balancer", "name", instance.Name, "id", instance.ID, "load_balancer", loadBalancer.Name, "error", err)
+			continue
+		}
+		if action != nil {
+			actions = append(actions, action)
+		}
+	}
+
+	instance.waitFn = func() error {
+		if err := group.client.Action.WaitFor(ctx, actions...); err != nil {
+			group.log.Warn("could not remove instance from load balancers", "name", instance.Name, "id", instance.ID, "error", err)
+		}
+		return nil
+	}
+
+	return nil
+}
+
+// Sanity adds the missing targets of the instances, and removes the targets of the
+// parked and deleting instances, and of the instances whose deletion started.
+func (h *LoadBalancerHandler) Sanity(ctx context.Context, group *instanceGroup) error {
+	if len(group.loadBalancers) == 0 {
+		return nil
+	}
+
+	instances, err := group.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	active := make([]int64, 0, len(instances))
+	inactive := make(map[int64]bool)
+	for _, instance := range instances {
+		if instance.Deleting || group.cleaningStarted(instance) {
+			inactive[instance.ID] = true
+		} else {
+			active = append(active, instance.ID)
+		}
+	}
+
+	if group.parking() {
+		servers, err := group.listParked(ctx)
+		if err != nil {
+			return err
+		}
+		for _, server := range servers {
+			inactive[server.ID] = true
+		}
+	}
+
+	targets, err := group.loadBalancerTargets(ctx)
+	if err != nil {
+		return err
+	}
+
+	errs := make([]error, 0)
+	actions := make([]*hcloud.Action, 0)
+	for _, loadBalancer := range group.loadBalancers {
+		for _, serverID := range active {
+			if targets[loadBalancer.ID][serverID] {
+				continue
+			}
+			group.log.Info("adding missing load balancer target", "load_balancer", loadBalancer.Name, "id", serverID)
+			action, err := group.addTarget(ctx, loadBalancer, serverID)
+			if err != nil {
+				errs = append(errs, err)
+			} else if action != nil {
+				actions = append(actions, action)
+			}
+		}
+
+		for _, serverID := range slices.Sorted(maps.Keys(targets[loadBalancer.ID])) {
+			if !inactive[serverID] {
+				continue
+			}
+			group.log.Info("removing stale load balancer target", "load_balancer", loadBalancer.Name, "id", serverID)
+			action, err := group.removeTarget(ctx, loadBalancer, serverID)
+			if err != nil {
+				errs = append(errs, err)
+			} else if action != nil {
+				actions = append(actions, action)
+			}
+		}
+	}
+
+	if err := group.client.Action.WaitFor(ctx, actions...); err != nil {
+		errs = append(errs, fmt.Errorf("could not reconcile load balancer targets: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// cleaningStarted returns whether the deletion of the instance started, or its server
+// is shutting down.
+func (g *instanceGroup) cleaningStarted(instance *Instance) bool {
+	if _, ok := g.cleaning.Load(instance.ID); ok {
+		return true
+	}
+	if instance.Server == nil {
+		return false
+	}
+	switch instance.Server.Status {
+	case hcloud.ServerStatusOff, hcloud.ServerStatusStopping:
+		return true
+	}
+	return false
+}
+
+// attachedToPrivateNetworks returns whether the load balancer is attached to one of the
+// private networks of the instances.
+func (g *instanceGroup) attachedToPrivateNetworks(loadBalancer *hcloud.LoadBalancer) bool {
+	for _, privateNet := range loadBalancer.PrivateNet {
+		for _, network := range g.privateNetworks {
+			if privateNet.Network != nil && privateNet.Network.ID == network.ID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// loadBalancerTargets returns the servers targeted by each load balancer, indexed by
+// load balancer ID.
+func (g *instanceGroup) loadBalancerTargets(ctx context.Context) (map[int64]map[int64]bool, error) {
+	targets := make(map[int64]map[int64]bool, len(g.loadBalancers))
+	for _, loadBalancer := range g.loadBalancers {
+		current, _, err := g.client.LoadBalancer.GetByID(ctx, loadBalancer.ID)
+		if err != nil {
+			return nil, fmt.Errorf("could not get load balancer: %w", err)
+		}
+		if current == nil {
+			return nil, fmt.Errorf("load balancer not found: %s", loadBalancer.Name)
+		}
+
+		servers := make(map[int64]bool, len(current.Targets))
+		for _, target := range current.Targets {
+			if target.Type == hcloud.LoadBalancerTargetTypeServer && target.Server != nil && target.Server.Server != nil {
+				servers[target.Server.Server.ID] = true
+			}
+		}
+		targets[loadBalancer.ID] = servers
+	}
+	return targets, nil
+}
+
+// addTarget requests to add a server to the load balancer targets. It returns a nil
+// action when the server is already a target.
+func (g *instanceGroup) addTarget(ctx context.Context, loadBalancer *hcloud.LoadBalancer, serverID int64) (*hcloud.Action, error) {
+	for {
+		action, _, err := g.client.LoadBalancer.AddServerTarget(ctx, loadBalancer, hcloud.LoadBalancerAddServerTargetOpts{
+			Server:       &hcloud.Server{ID: serverID},
+			UsePrivateIP: hcloud.Ptr(g.config.LoadBalancerUsePrivateIP),
+		})
+		switch {
+		case err == nil:
+			return action, nil
+		case hcloud.IsError(err, hcloud.ErrorCodeTargetAlreadyDefined):
+			return nil, nil
+		case hcloud.IsError(err, hcloud.ErrorCodeLocked):
+			if err := waitLoadBalancerUnlocked(ctx); err != nil {
+				return nil, fmt.Errorf("could not add load balancer target: %w", err)
+			}
+		default:
+			return nil, fmt.Errorf("could not add load balancer target: %w", err)
+		}
+	}
+}
+
+// removeTarget requests to remove a server from the load balancer targets. It returns a
+// nil action when the server is not a target.
+func (g *instanceGroup) removeTarget(ctx context.Context, loadBalancer *hcloud.LoadBalancer, serverID int64) (*hcloud.Action, error) {
+	for {
+		action, _, err := g.client.LoadBalancer.RemoveServerTarget(ctx, loadBalancer, &hcloud.Server{ID: serverID})
+		switch {
+		case err == nil:
+			return action, nil
+		case hcloud.IsError(err, hcloud.ErrorCodeNotFound):
+			return nil, nil
+		case hcloud.IsError(err, hcloud.ErrorCodeLocked):
+			if err := waitLoadBalancerUnlocked(ctx); err != nil {
+				return nil, fmt.Errorf("could not remove load balancer target: %w", err)
+			}
+		default:
+			return nil, fmt.Errorf("could not remove load balancer target: %w", err)
+		}
+	}
+}
+
+// waitLoadBalancerUnlocked waits before retrying a request on a load balancer, which is
+// locked while one of its actions is running.
+func waitLoadBalancerUnlocked(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(loadBalancerLockedInterval):
+		return nil
+	}
+}
+
+// detachParked removes the parked instances from the load balancers targets, so they
+// stop receiving traffic.
+func (g *instanceGroup) detachParked(ctx context.Context, instances []*Instance) {
+	handler := &LoadBalancerHandler{}
+	for _, instance := range instances {
+		_ = handler.Cleanup(ctx, g, instance)
+	}
+	for _, instance := range instances {
+		_ = instance.wait()
+	}
+}
+
+// attachUnparked adds the reused parked instances to the load balancers targets.
+func (g *instanceGroup) attachUnparked(ctx context.Context, instances []*Instance) {
+	handler := &LoadBalancerHandler{}
+
+	requested := make([]*Instance, 0, len(instances))
+	for _, instance := range instances {
+		if err := handler.Create(ctx, g, instance); err != nil {
+			g.log.Warn("could not add reused instance to load balancers", "name", instance.Name, "id", instance.ID, "error", err)
+			continue
+		}
+		requested = append(requested, instance)
+	}
+	for _, instance := range requested {
+		if err := instance.wait(); err != nil {
+			g.log.Warn("could not add reused instance to load balancers", "name", instance.Name, "id", instance.ID, "error", err)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package instancegroup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/testutils"
+)
+
+func setupInstanceGroupWithLoadBalancer(t *testing.T, config Config, opts ...testutils.FakeAPIOption) (*instanceGroup, *testutils.FakeAPI) {
+	t.Helper()
+
+	api := testutils.NewFakeAPI(t, opts...)
+	network := api.AddNetwork("fleeting", "10.0.0.0/16")
+	api.AddLoadBalancer("buildkit", network.ID)
+
+	config.PrivateNetworks = []string{"fleeting"}
+	config.LoadBalancers = []string{"buildkit"}
+
+	group := &instanceGroup{name: "fleeting", config: config, log: hclog.New(hclog.DefaultOptions), client: api.Client()}
+	group.randomNameFn = makeRandomNameFn(group.name)
+	require.NoError(t, group.Init(context.Background()))
+
+	return group, api
+}
+
+// targetServers returns the servers targeted by the first load balancer.
+func targetServers(api *testutils.FakeAPI) []int64 {
+	servers := make([]int64, 0)
+	for _, target := range api.LoadBalancers()[0].Targets {
+		servers = append(servers, target.Server.ID)
+	}
+	return servers
+}
+
+func TestLoadBalancerHandler(t *testing.T) {
+	t.Run("increase and decrease", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.LoadBalancerUsePrivateIP = true
+
+		group, api := setupInstanceGroupWithLoadBalancer(t, config)
+
+		created, err := group.Increase(ctx, 2)
+		require.NoError(t, err)
+		require.Len(t, created, 2)
+
+		servers := api.Servers()
+		require.Len(t, servers, 2)
+		assert.Equal(t, []int64{servers[0].ID, servers[1].ID}, targetServers(api))
+		for _, target := range api.LoadBalancers()[0].Targets {
+			assert.True(t, target.UsePrivateIP)
+		}
+
+		deleted, err := group.Decrease(ctx, created)
+		require.NoError(t, err)
+		require.Len(t, deleted, 2)
+
+		require.Empty(t, api.Servers())
+		require.Empty(t, targetServers(api))
+	})
+
+	t.Run("locked load balancer", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+
+		// The load balancer is locked while adding the first target
+		group, api := setupInstanceGroupWithLoadBalancer(t, config, testutils.WithActionDuration(100*time.Millisecond))
+
+		created, err := group.Increase(ctx, 2)
+		require.NoError(t, err)
+		require.Len(t, created, 2)
+
+		servers := api.Servers()
+		require.Len(t, servers, 2)
+		assert.Equal(t, []int64{servers[0].ID, servers[1].ID}, targetServers(api))
+
+		deleted, err := group.Decrease(ctx, created)
+		require.NoError(t, err)
+		require.Len(t, deleted, 2)
+
+		require.Empty(t, api.Servers())
+		require.Empty(t, targetServers(api))
+	})
+
+	t.Run("parking removes the targets", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.ParkingEnabled = true
+
+		group, api := setupInstanceGroupWithLoadBalancer(t, config)
+
+		created, err := group.Increase(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, created, 1)
+		require.Len(t, targetServers(api), 1)
+
+		deleted, err := group.Decrease(ctx, created)
+		require.NoError(t, err)
+		require.Len(t, deleted, 1)
+
+		require.Len(t, api.Servers(), 1)
+		require.Empty(t, targetServers(api))
+
+		// The parked server is reused
+		created, err = group.Increase(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, created, 1)
+
+		servers := api.Servers()
+		require.Len(t, servers, 1)
+		assert.Equal(t, []int64{servers[0].ID}, targetServers(api))
+	})
+
+	t.Run("sanity reconciles the targets", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.ParkingEnabled = true
+
+		group, api := setupInstanceGroupWithLoadBalancer(t, config)
+		client := api.Client()
+
+		created, err := group.Increase(ctx, 2)
+		require.NoError(t, err)
+		require.Len(t, created, 2)
+
+		deleted, err := group.Decrease(ctx, created[1:])
+		require.NoError(t, err)
+		require.Len(t, deleted, 1)
+
+		servers := api.Servers()
+		require.Len(t, servers, 2)
+		active, parked := servers[0], servers[1]
+
+		foreign := api.AddServer("foreign", nil)
+
+		loadBalancer := &hcloud.LoadBalancer{ID: api.LoadBalancers()[0].ID}
+		for _, server := range []schema.Server{parked, foreign} {
+			_, _, err = client.LoadBalancer.AddServerTarget(ctx, loadBalancer, hcloud.LoadBalancerAddServerTargetOpts{Server: &hcloud.Server{ID: server.ID}})
+			require.NoError(t, err)
+		}
+		_, _, err = client.LoadBalancer.RemoveServerTarget(ctx, loadBalancer, &hcloud.Server{ID: active.ID})
+		require.NoError(t, err)
+
+		require.NoError(t, group.Sanity(ctx))
+
+		assert.ElementsMatch(t, []int64{active.ID, foreign.ID}, targetServers(api))
+	})
+
+	t.Run("sanity does not add back the targets of failed deletions", func(t *testing.T) {
+		ctx := context.Background()
+
+		group, api := setupInstanceGroupWithLoadBalancer(t, DefaultTestConfig)
+
+		created, err := group.Increase(ctx, 2)
+		require.NoError(t, err)
+		require.Len(t, created, 2)
+
+		servers := api.Servers()
+		require.Len(t, servers, 2)
+
+		injector := testutils.NewFaultInjector(0, testutils.Fault{Method: "DELETE", Path: "/servers/*", Status: 500, Code: "server_error", Times: 1})
+		group.client = injector.Client(api.URL())
+
+		_, err = group.Decrease(ctx, created[:1])
+		require.Error(t, err)
+		require.Len(t, injector.Injected(), 1)
+		require.Len(t, api.Servers(), 2)
+
+		require.NoError(t, group.Sanity(ctx))
+		assert.ElementsMatch(t, []int64{servers[1].ID}, targetServers(api))
+
+		// The deletion completes once retried
+		deleted, err := group.Decrease(ctx, created[:1])
+		require.NoError(t, err)
+		require.Len(t, deleted, 1)
+		require.Len(t, api.Servers(), 1)
+	})
+
+	t.Run("load balancer not attached to the private networks", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.PrivateNetworks = []string{"fleeting"}
+		config.LoadBalancers = []string{"buildkit"}
+		config.LoadBalancerUsePrivateIP = true
+
+		api := testutils.NewFakeAPI(t)
+		api.AddNetwork("fleeting", "10.0.0.0/16")
+		api.AddLoadBalancer("buildkit")
+
+		group := &instanceGroup{name: "fleeting", config: config, log: hclog.New(hclog.DefaultOptions), client: api.Client()}
+
+		require.EqualError(t, group.Init(ctx), "load balancer is not attached to any of the private networks: buildkit")
+	})
+
+	t.Run("load balancer not found", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.LoadBalancers = []string{"buildkit"}
+
+		api := testutils.NewFakeAPI(t)
+
+		group := &instanceGroup{name: "fleeting", config: config, log: hclog.New(hclog.DefaultOptions), client: api.Client()}
+
+		require.EqualError(t, group.Init(ctx), "load balancer not found: buildkit")
+	})
+}
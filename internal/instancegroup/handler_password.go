@@ -0,0 +1,212 @@
+package instancegroup
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+)
+
+// errPasswordUnknown is returned when the generated password of an instance is not
+// known, because the instance was created before a restart of the plugin.
+var errPasswordUnknown = errors.New("instance password unknown, the instance was created before a restart")
+
+// PasswordHandler configures the administrator password of Windows instances, using a
+// Cloudbase-Init user data script.
+//
+// The generated passwords are only kept in memory. The sanity check deletes the
+// instances created before a restart of the plugin, which cannot be reached anymore.
+type PasswordHandler struct{}
+
+var _ CreateHandler = (*PasswordHandler)(nil)
+var _ CleanupHandler = (*PasswordHandler)(nil)
+var _ SanityHandler = (*PasswordHandler)(nil)
+
+func (h *PasswordHandler) Create(_ context.Context, group *instanceGroup, instance *Instance) error {
+	if !group.config.WindowsEnabled {
+		return nil
+	}
+
+	password := group.config.WindowsPassword
+	if password == "" {
+		var err error
+		password, err = generatePassword(24)
+		if err != nil {
+			return fmt.Errorf("could not generate instance password: %w", err)
+		}
+	}
+
+	userData, err := windowsUserData(group.config.WindowsUsername, password, instance.opts.UserData)
+	if err != nil {
+		return fmt.Errorf("could not generate instance user data: %w", err)
+	}
+
+	instance.opts.UserData = userData
+	instance.Password = password
+
+	group.passwords.Store(instance.Name, password)
+
+	return nil
+}
+
+func (h *PasswordHandler) Cleanup(_ context.Context, group *instanceGroup, instance *Instance) error {
+	group.passwords.Delete(instance.Name)
+
+	return nil
+}
+
+func (h *PasswordHandler) Sanity(ctx context.Context, group *instanceGroup) error {
+	if !group.config.WindowsEnabled || group.config.WindowsPassword != "" {
+		return nil
+	}
+
+	instances, err := group.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	unknown := make([]*Instance, 0)
+	for _, instance := range instances {
+		if instance.Deleting || !group.passwordUnknown(instance) {
+			continue
+		}
+		group.log.Warn("deleting instance with unknown password", "name", instance.Name, "id", instance.ID)
+		group.deleting.Store(instance.Name, struct{}{})
+		unknown = append(unknown, instance)
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	_, err = group.delete(ctx, unknown)
+
+	for _, instance := range unknown {
+		group.deleting.Delete(instance.Name)
+	}
+	group.cache.invalidate()
+
+	if err != nil {
+		return fmt.Errorf("could not delete instances with unknown password: %w", err)
+	}
+
+	return nil
+}
+
+// passwordUnknown returns whether the generated password of a Windows instance is
+// unknown, because the instance was created before a restart of the plugin.
+func (g *instanceGroup) passwordUnknown(instance *Instance) bool {
+	if !g.config.WindowsEnabled || g.config.WindowsPassword != "" {
+		return false
+	}
+	_, ok := g.passwords.Load(instance.Name)
+	return !ok
+}
+
+// windowsUserData returns a Cloudbase-Init user data that sets the password of the
+// administrator account. When user data are provided, both are combined in a
+// multipart message.
+func windowsUserData(username, password, userData string) (string, error) {
+	script := fmt.Sprintf(windowsUserDataScript, powershellQuote(username), powershellQuote(password))
+
+	if userData == "" {
+		return script, nil
+	}
+
+	buf := &bytes.Buffer{}
+	writer := multipart.NewWriter(buf)
+
+	fmt.Fprintf(buf, "Content-Type: multipart/mixed; boundary=\"%s\"\r\nMIME-Version: 1.0\r\n\r\n", writer.Boundary())
+
+	for _, part := range []string{script, userData} {
+		contentType := "text/x-shellscript"
+		if strings.HasPrefix(part, "#cloud-config") {
+			contentType = "text/cloud-config"
+		}
+
+		w, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type": {contentType},
+			"MIME-Version": {"1.0"},
+		})
+		if err != nil {
+			return "", err
+		}
+		if _, err := w.Write([]byte(part)); err != nil {
+			return "", err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+const windowsUserDataScript = `#ps1_sysnative
+$username = %s
+$password = ConvertTo-SecureString %s -AsPlainText -Force
+if (Get-LocalUser -Name $username -ErrorAction SilentlyContinue) {
+  Set-LocalUser -Name $username -Password $password
+} else {
+  New-LocalUser -Name $username -Password $password -PasswordNeverExpires
+  Add-LocalGroupMember -Group "Administrators" -Member $username
+}
+`
+
+// powershellQuote returns the value as a PowerShell single quoted string.
+func powershellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+const (
+	passwordLower  = "abcdefghijkmnopqrstuvwxyz"
+	passwordUpper  = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+	passwordDigits = "23456789"
+)
+
+// generatePassword returns a random password that satisfies the Windows password
+// complexity requirements. The alphabet omits characters that are easily confused.
+func generatePassword(length int) (string, error) {
+	charsets := []string{passwordLower, passwordUpper, passwordDigits}
+	alphabet := strings.Join(charsets, "")
+
+	pick := func(set string) (byte, error) {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(set))))
+		if err != nil {
+			return 0, err
+		}
+		return set[n.Int64()], nil
+	}
+
+	result := make([]byte, length)
+	for i := range result {
+		// Ensure each charset is used at least once
+		set := alphabet
+		if i < len(charsets) {
+			set = charsets[i]
+		}
+
+		c, err := pick(set)
+		if err != nil {
+			return "", err
+		}
+		result[i] = c
+	}
+
+	// Shuffle the result, so the guaranteed characters are not always at the start
+	for i := len(result) - 1; i > 0; i-- {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		j := n.Int64()
+		result[i], result[j] = result[j], result[i]
+	}
+
+	return string(result), nil
+}
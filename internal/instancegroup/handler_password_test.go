@@ -0,0 +1,170 @@
+package instancegroup
+
+import (
+	"context"
+	"mime"
+	"mime/multipart"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/exp/mockutil"
+)
+
+func TestPasswordHandlerCreate(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.WindowsEnabled = true
+		config.WindowsUsername = "Administrator"
+
+		group := setupInstanceGroup(t, config, []mockutil.Request{})
+
+		instance := NewInstance("fleeting-a")
+		{
+			handler := &BaseHandler{}
+			require.NoError(t, handler.Create(ctx, group, instance))
+		}
+
+		handler := &PasswordHandler{}
+		require.NoError(t, handler.Create(ctx, group, instance))
+
+		assert.Len(t, instance.Password, 24)
+		assert.True(t, strings.HasPrefix(instance.opts.UserData, "#ps1_sysnative\n"))
+		assert.Contains(t, instance.opts.UserData, "'"+instance.Password+"'")
+
+		password, ok := group.passwords.Load("fleeting-a")
+		assert.True(t, ok)
+		assert.Equal(t, instance.Password, password)
+
+		require.NoError(t, handler.Cleanup(ctx, group, instance))
+
+		_, ok = group.passwords.Load("fleeting-a")
+		assert.False(t, ok)
+	})
+
+	t.Run("success static password with user data", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.UserData = "#cloud-config\nhostname: dummy\n"
+		config.WindowsEnabled = true
+		config.WindowsUsername = "Administrator"
+		config.WindowsPassword = "it's-secret"
+
+		group := setupInstanceGroup(t, config, []mockutil.Request{})
+
+		instance := NewInstance("fleeting-a")
+		{
+			handler := &BaseHandler{}
+			require.NoError(t, handler.Create(ctx, group, instance))
+		}
+
+		handler := &PasswordHandler{}
+		require.NoError(t, handler.Create(ctx, group, instance))
+
+		assert.Equal(t, "it's-secret", instance.Password)
+
+		header, body, ok := strings.Cut(instance.opts.UserData, "\r\n\r\n")
+		require.True(t, ok)
+
+		mediaType, params, err := mime.ParseMediaType(strings.TrimPrefix(strings.Split(header, "\r\n")[0], "Content-Type: "))
+		require.NoError(t, err)
+		require.Equal(t, "multipart/mixed", mediaType)
+
+		reader := multipart.NewReader(strings.NewReader(body), params["boundary"])
+
+		part, err := reader.NextPart()
+		require.NoError(t, err)
+		assert.Equal(t, "text/x-shellscript", part.Header.Get("Content-Type"))
+
+		part, err = reader.NextPart()
+		require.NoError(t, err)
+		assert.Equal(t, "text/cloud-config", part.Header.Get("Content-Type"))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.UserData = "dummy"
+
+		group := setupInstanceGroup(t, config, []mockutil.Request{})
+
+		instance := NewInstance("fleeting-a")
+		{
+			handler := &BaseHandler{}
+			require.NoError(t, handler.Create(ctx, group, instance))
+		}
+
+		handler := &PasswordHandler{}
+		require.NoError(t, handler.Create(ctx, group, instance))
+
+		assert.Equal(t, "", instance.Password)
+		assert.Equal(t, "dummy", instance.opts.UserData)
+	})
+}
+
+func TestPasswordHandlerSanity(t *testing.T) {
+	t.Run("deletes instances with unknown password", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.WindowsEnabled = true
+		config.WindowsUsername = "Administrator"
+
+		group, api := setupInstanceGroupWithFakeAPI(t, config)
+
+		created, err := group.Increase(ctx, 2)
+		require.NoError(t, err)
+		require.Len(t, created, 2)
+
+		// The first instance was created before a restart
+		servers := api.Servers()
+		require.Len(t, servers, 2)
+		group.passwords.Delete(servers[0].Name)
+
+		require.NoError(t, (&PasswordHandler{}).Sanity(ctx, group))
+
+		remaining := api.Servers()
+		require.Len(t, remaining, 1)
+		assert.Equal(t, servers[1].ID, remaining[0].ID)
+		require.Len(t, api.Volumes(), 1)
+	})
+
+	t.Run("static password", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.WindowsEnabled = true
+		config.WindowsUsername = "Administrator"
+		config.WindowsPassword = "static"
+
+		group, api := setupInstanceGroupWithFakeAPI(t, config)
+
+		created, err := group.Increase(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, created, 1)
+
+		group.passwords.Clear()
+
+		require.NoError(t, (&PasswordHandler{}).Sanity(ctx, group))
+		require.Len(t, api.Servers(), 1)
+	})
+}
+
+func TestPowershellQuote(t *testing.T) {
+	assert.Equal(t, "'value'", powershellQuote("value"))
+	assert.Equal(t, "'it''s'", powershellQuote("it's"))
+}
+
+func TestGeneratePassword(t *testing.T) {
+	for range 100 {
+		password, err := generatePassword(24)
+		require.NoError(t, err)
+		require.Len(t, password, 24)
+
+		require.True(t, strings.ContainsFunc(password, unicode.IsLower))
+		require.True(t, strings.ContainsFunc(password, unicode.IsUpper))
+		require.True(t, strings.ContainsFunc(password, unicode.IsDigit))
+	}
+}
@@ -0,0 +1,214 @@
+package instancegroup
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// ProtectionHandler enables the delete protection of the instance server and volumes
+// once they are created, and disables it right before their deletion by the
+// [ServerHandler] and the [VolumeHandler].
+type ProtectionHandler struct{}
+
+var _ CreateHandler = (*ProtectionHandler)(nil)
+var _ CleanupHandler = (*ProtectionHandler)(nil)
+var _ SanityHandler = (*ProtectionHandler)(nil)
+
+func (h *ProtectionHandler) Create(ctx context.Context, group *instanceGroup, instance *Instance) error {
+	if !group.config.ProtectInstances || instance.ID == 0 {
+		return nil
+	}
+
+	if instance.Server == nil {
+		group.refreshServer(ctx, instance)
+	}
+	if instance.Server == nil {
+		return fmt.Errorf("instance server not found")
+	}
+
+	actions := make([]*hcloud.Action, 0, 1+len(instance.Server.Volumes))
+
+	action, _, err := group.client.Server.ChangeProtection(ctx, instance.Server, serverProtection(true))
+	if err != nil {
+		return fmt.Errorf("could not request instance protection: %w", err)
+	}
+	actions = append(actions, action)
+
+	for _, volume := range instance.Server.Volumes {
+		action, _, err := group.client.Volume.ChangeProtection(ctx, volume, volumeProtection(true))
+		if err != nil {
+			return fmt.Errorf("could not request volume protection: %w", err)
+		}
+		actions = append(actions, action)
+	}
+
+	instance.waitFn = func() error {
+		if err := group.client.Action.WaitFor(ctx, actions...); err != nil {
+			return fmt.Errorf("could not protect instance: %w", err)
+		}
+		return nil
+	}
+
+	return nil
+}
+
+func (h *ProtectionHandler) Cleanup(ctx context.Context, group *instanceGroup, instance *Instance) error {
+	if instance.ID == 0 {
+		return nil
+	}
+	if !group.config.ProtectInstances && (instance.Server == nil || !instance.Server.Protection.Delete) {
+		return nil
+	}
+
+	if instance.Server == nil {
+		group.refreshServer(ctx, instance)
+	}
+
+	actions := make([]*hcloud.Action, 0)
+
+	action, _, err := group.client.Server.ChangeProtection(ctx, &hcloud.Server{ID: instance.ID}, serverProtection(false))
+	if err != nil {
+		if !hcloud.IsError(err, hcloud.ErrorCodeNotFound) {
+			return fmt.Errorf("could not request instance unprotection: %w", err)
+		}
+	} else {
+		actions = append(actions, action)
+	}
+
+	if instance.Server != nil {
+		for _, volume := range instance.Server.Volumes {
+			action, _, err := group.client.Volume.ChangeProtection(ctx, volume, volumeProtection(false))
+			if err != nil {
+				if !hcloud.IsError(err, hcloud.ErrorCodeNotFound) {
+					return fmt.Errorf("could not request volume unprotection: %w", err)
+				}
+				continue
+			}
+			actions = append(actions, action)
+		}
+	}
+
+	instance.waitFn = func() error {
+		if err := group.client.Action.WaitFor(ctx, actions...); err != nil {
+			return fmt.Errorf("could not unprotect instance: %w", err)
+		}
+		return nil
+	}
+
+	return nil
+}
+
+// Sanity warns about the running instances without delete protection, for example
+// after the protection of their server or volumes was disabled out of band.
+func (h *ProtectionHandler) Sanity(ctx context.Context, group *instanceGroup) error {
+	if !group.config.ProtectInstances {
+		return nil
+	}
+
+	servers, err := group.client.Server.AllWithOpts(ctx,
+		hcloud.ServerListOpts{
+			ListOpts: hcloud.ListOpts{
+				LabelSelector: fmt.Sprintf("instance-group=%s", group.name),
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("could not list servers: %w", err)
+	}
+
+	// The running servers that must be protected, with their volumes.
+	running := make(map[int64]bool, len(servers))
+
+	for _, server := range servers {
+		if !group.owns("server", server.ID, server.Name, server.Labels) {
+			continue
+		}
+		// The servers are protected once created or rebuilt.
+		if server.Status != hcloud.ServerStatusRunning {
+			continue
+		}
+		if _, ok := group.deleting.Load(server.Name); ok {
+			continue
+		}
+		running[server.ID] = true
+
+		if !server.Protection.Delete {
+			group.log.Warn("instance is not protected against deletion", "name", server.Name, "id", server.ID)
+		}
+	}
+
+	volumes, err := group.client.Volume.AllWithOpts(ctx,
+		hcloud.VolumeListOpts{
+			ListOpts: hcloud.ListOpts{
+				LabelSelector: fmt.Sprintf("instance-group=%s", group.name),
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("could not list volumes: %w", err)
+	}
+
+	for _, volume := range volumes {
+		if volume.Server == nil || !running[volume.Server.ID] || volume.Protection.Delete {
+			continue
+		}
+
+		group.log.Warn("volume is not protected against deletion", "name", volume.Name, "id", volume.ID)
+	}
+
+	return nil
+}
+
+// protectServer enables the delete protection of a server, and waits for the action.
+func (g *instanceGroup) protectServer(ctx context.Context, id int64) error {
+	action, _, err := g.client.Server.ChangeProtection(ctx, &hcloud.Server{ID: id}, serverProtection(true))
+	if err != nil {
+		return fmt.Errorf("could not request instance protection: %w", err)
+	}
+	if err := g.client.Action.WaitFor(ctx, action); err != nil {
+		return fmt.Errorf("could not protect instance: %w", err)
+	}
+	return nil
+}
+
+// unprotectServer disables the delete protection of a server, and waits for the action.
+func (g *instanceGroup) unprotectServer(ctx context.Context, id int64) error {
+	action, _, err := g.client.Server.ChangeProtection(ctx, &hcloud.Server{ID: id}, serverProtection(false))
+	if err != nil {
+		if hcloud.IsError(err, hcloud.ErrorCodeNotFound) {
+			return nil
+		}
+		return fmt.Errorf("could not request instance unprotection: %w", err)
+	}
+	if err := g.client.Action.WaitFor(ctx, action); err != nil {
+		return fmt.Errorf("could not unprotect instance: %w", err)
+	}
+	return nil
+}
+
+// unprotectVolume disables the delete protection of a volume, and waits for the action.
+func (g *instanceGroup) unprotectVolume(ctx context.Context, volume *hcloud.Volume) error {
+	action, _, err := g.client.Volume.ChangeProtection(ctx, volume, volumeProtection(false))
+	if err != nil {
+		if hcloud.IsError(err, hcloud.ErrorCodeNotFound) {
+			return nil
+		}
+		return fmt.Errorf("could not request volume unprotection: %w", err)
+	}
+	if err := g.client.Action.WaitFor(ctx, action); err != nil {
+		return fmt.Errorf("could not unprotect volume: %w", err)
+	}
+	return nil
+}
+
+// serverProtection returns the options changing the server protection, the API
+// requires the delete and rebuild protections to have the same value.
+func serverProtection(enabled bool) hcloud.ServerChangeProtectionOpts {
+	return hcloud.ServerChangeProtectionOpts{Delete: hcloud.Ptr(enabled), Rebuild: hcloud.Ptr(enabled)}
+}
+
+func volumeProtection(enabled bool) hcloud.VolumeChangeProtectionOpts {
+	return hcloud.VolumeChangeProtectionOpts{Delete: hcloud.Ptr(enabled)}
+}
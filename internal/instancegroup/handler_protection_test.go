@@ -0,0 +1,130 @@
+package instancegroup
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProtectionHandler(t *testing.T) {
+	t.Run("increase and decrease", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.ProtectInstances = true
+
+		group, api := setupInstanceGroupWithFakeAPI(t, config)
+		client := api.Client()
+
+		created, err := group.Increase(ctx, 2)
+		require.NoError(t, err)
+		require.Len(t, created, 2)
+
+		servers := api.Servers()
+		require.Len(t, servers, 2)
+		for _, server := range servers {
+			assert.True(t, server.Protection.Delete)
+			assert.True(t, server.Protection.Rebuild)
+		}
+		volumes := api.Volumes()
+		require.Len(t, volumes, 2)
+		for _, volume := range volumes {
+			assert.True(t, volume.Protection.Delete)
+		}
+
+		// The servers cannot be deleted out of band
+		_, _, err = client.Server.DeleteWithResult(ctx, &hcloud.Server{ID: servers[0].ID})
+		require.True(t, hcloud.IsError(err, hcloud.ErrorCodeProtected), err)
+
+		deleted, err := group.Decrease(ctx, created)
+		require.NoError(t, err)
+		require.Len(t, deleted, 2)
+
+		require.Empty(t, api.Servers())
+		require.Empty(t, api.Volumes())
+	})
+
+	t.Run("failed creation is cleaned up", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.ProtectInstances = true
+		config.Hooks.PostCreate = &Hook{Command: []string{"false"}}
+
+		group, api := setupInstanceGroupWithFakeAPI(t, config)
+
+		created, err := group.Increase(ctx, 2)
+		require.Error(t, err)
+		require.Empty(t, created)
+
+		// The protected server and volume of the failed instances were deleted
+		require.Empty(t, api.Servers())
+		require.Empty(t, api.Volumes())
+	})
+
+	t.Run("rebuild", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.ProtectInstances = true
+		config.RecycleMode = RecycleModeRebuild
+
+		group, api := setupInstanceGroupWithFakeAPI(t, config)
+
+		created, err := group.Increase(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, created, 1)
+
+		deleted, err := group.Decrease(ctx, created)
+		require.NoError(t, err)
+		require.Len(t, deleted, 1)
+
+		created, err = group.Increase(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, created, 1)
+
+		servers := api.Servers()
+		require.Len(t, servers, 1)
+		assert.Equal(t, "fleeting-b", servers[0].Name)
+		assert.True(t, servers[0].Protection.Delete)
+	})
+
+	t.Run("sanity warns about unprotected instances", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.ProtectInstances = true
+
+		group, api := setupInstanceGroupWithFakeAPI(t, config)
+		client := api.Client()
+
+		var output bytes.Buffer
+		group.log = hclog.New(&hclog.LoggerOptions{Output: &output})
+
+		created, err := group.Increase(ctx, 2)
+		require.NoError(t, err)
+		require.Len(t, created, 2)
+
+		servers := api.Servers()
+		require.Len(t, servers, 2)
+
+		action, _, err := client.Server.ChangeProtection(ctx, &hcloud.Server{ID: servers[1].ID}, serverProtection(false))
+		require.NoError(t, err)
+		require.NoError(t, client.Action.WaitFor(ctx, action))
+
+		volumes := api.Volumes()
+		require.Len(t, volumes, 2)
+
+		action, _, err = client.Volume.ChangeProtection(ctx, &hcloud.Volume{ID: volumes[0].ID}, volumeProtection(false))
+		require.NoError(t, err)
+		require.NoError(t, client.Action.WaitFor(ctx, action))
+
+		require.NoError(t, group.Sanity(ctx))
+
+		assert.NotContains(t, output.String(), "instance is not protected against deletion: name="+servers[0].Name)
+		assert.Contains(t, output.String(), "instance is not protected against deletion: name="+servers[1].Name)
+		assert.Contains(t, output.String(), "volume is not protected against deletion: name="+volumes[0].Name)
+		assert.NotContains(t, output.String(), "volume is not protected against deletion: name="+volumes[1].Name)
+	})
+}
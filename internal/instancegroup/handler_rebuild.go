@@ -0,0 +1,120 @@
+package instancegroup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// sshKeysLabel is the server label holding a hash of the SSH keys the server was
+// created with.
+const sshKeysLabel = "ssh-keys"
+
+// errSSHKeysChanged is returned when a server cannot be rebuilt because it was created
+// with different SSH keys.
+var errSSHKeysChanged = errors.New("ssh keys changed since the server creation")
+
+// RebuildHandler rebuilds the server of the instance from the instance group image,
+// and renames the instance so the rebuilt server is seen as a new instance.
+//
+// The rebuild API only accepts an image, and re-injects the SSH keys the server was
+// created with, the SSH keys of an existing server cannot be changed. To rotate the
+// SSH keys, servers created with different SSH keys are not rebuilt, but deleted and
+// replaced by new servers.
+type RebuildHandler struct{}
+
+func (h *RebuildHandler) Rebuild(ctx context.Context, group *instanceGroup, instance *Instance) error {
+	server, _, err := group.client.Server.GetByID(ctx, instance.ID)
+	if err != nil {
+		return fmt.Errorf("could not get instance: %w", err)
+	}
+	if server == nil {
+		return fmt.Errorf("instance not found: %s", instance.IID())
+	}
+
+	if server.Labels[sshKeysLabel] != group.sshKeysHash {
+		return errSSHKeysChanged
+	}
+	if group.passwordUnknown(instance) {
+		return errPasswordUnknown
+	}
+
+	if server.Protection.Rebuild {
+		if err := group.unprotectServer(ctx, server.ID); err != nil {
+			return err
+		}
+	}
+
+	result, _, err := group.client.Server.RebuildWithResult(ctx, server, hcloud.ServerRebuildOpts{Image: group.image})
+	if err != nil {
+		return fmt.Errorf("could not request instance rebuild: %w", err)
+	}
+
+	// Rename and unpark the server, and rename the volumes named after the instance.
+	labels := maps.Clone(server.Labels)
+	delete(labels, parkedLabel)
+
+	name := group.randomNameFn()
+	for attempt := 1; ; attempt++ {
+		_, _, err = group.client.Server.Update(ctx, server, hcloud.ServerUpdateOpts{Name: name, Labels: labels})
+		if err != nil && hcloud.IsError(err, hcloud.ErrorCodeUniquenessError) && attempt < maxNameAttempts {
+			name = group.randomNameFn()
+			continue
+		}
+		break
+	}
+	if err != nil {
+		return fmt.Errorf("could not rename instance: %w", err)
+	}
+
+	for _, volume := range server.Volumes {
+		_, _, err = group.client.Volume.Update(ctx, volume, hcloud.VolumeUpdateOpts{Name: name})
+		if err != nil {
+			// The volume is deleted with the server, or by the sanity checks.
+			group.log.Warn("could not rename volume", "id", volume.ID, "name", name, "error", err)
+		}
+	}
+
+	if password, ok := group.passwords.LoadAndDelete(instance.Name); ok {
+		group.passwords.Store(name, password)
+	}
+
+	group.log.Info("rebuilding instance", "name", instance.Name, "new_name", name, "id", instance.ID)
+
+	instance.Name = name
+	instance.Server = nil
+
+	instance.waitFn = func() error {
+		if err := group.client.Action.WaitFor(ctx, result.Action); err != nil {
+			return fmt.Errorf("could not rebuild instance: %w", err)
+		}
+		if group.config.ProtectInstances {
+			return group.protectServer(ctx, instance.ID)
+		}
+		return nil
+	}
+
+	return nil
+}
+
+// sshKeysHash returns a label value identifying a list of SSH keys.
+func sshKeysHash(sshKeys []*hcloud.SSHKey) string {
+	fingerprints := make([]string, 0, len(sshKeys))
+	for _, sshKey := range sshKeys {
+		fingerprints = append(fingerprints, sshKey.Fingerprint)
+	}
+	slices.Sort(fingerprints)
+
+	sum := sha256.New()
+	for _, fingerprint := range fingerprints {
+		sum.Write([]byte(fingerprint))
+		sum.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(sum.Sum(nil))[:32]
+}
@@ -0,0 +1,18 @@
+package instancegroup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+func TestSSHKeysHash(t *testing.T) {
+	a := &hcloud.SSHKey{Fingerprint: "76:66:c2:20:ff:c7:7a:d6:8e:9b:4d:0b:ea:3e:57:c3"}
+	b := &hcloud.SSHKey{Fingerprint: "b7:2f:30:a0:2f:6c:58:6c:21:04:58:61:ba:06:3b:2f"}
+
+	assert.Len(t, sshKeysHash([]*hcloud.SSHKey{a, b}), 32)
+	assert.Equal(t, sshKeysHash([]*hcloud.SSHKey{a, b}), sshKeysHash([]*hcloud.SSHKey{b, a}))
+	assert.NotEqual(t, sshKeysHash([]*hcloud.SSHKey{a}), sshKeysHash([]*hcloud.SSHKey{a, b}))
+}
@@ -3,24 +3,70 @@ package instancegroup
 import (
 	"context"
 	"fmt"
+	"maps"
+	"time"
 
 	"github.com/hetznercloud/hcloud-go/v2/hcloud"
 	"github.com/hetznercloud/hcloud-go/v2/hcloud/exp/actionutil"
+
+	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/eventlog"
 )
 
 // ServerHandler creates a server from the instance server create options.
-type ServerHandler struct{}
+type ServerHandler struct {
+	// serverTypes are the server types to try, in order.
+	serverTypes []*hcloud.ServerType
+}
 
+var _ PreIncreaseHandler = (*ServerHandler)(nil)
 var _ CreateHandler = (*ServerHandler)(nil)
 var _ CleanupHandler = (*ServerHandler)(nil)
 
+func (h *ServerHandler) PreIncrease(ctx context.Context, group *instanceGroup) error {
+	h.serverTypes = group.serverTypes
+
+	availability, err := group.cachedServerTypesAvailability(ctx)
+	if err != nil {
+		// Do not prevent the instances creation, the API will report the unavailable
+		// server types.
+		group.log.Warn("could not check server types availability", "error", err)
+		return nil
+	}
+
+	serverTypes := make([]*hcloud.ServerType, 0, len(group.serverTypes))
+	for _, serverType := range group.serverTypes {
+		switch {
+		case !availability.supported[serverType.ID]:
+			group.log.Warn("skipping server type not supported in location", "server_type", serverType.Name)
+		case !availability.available[serverType.ID]:
+			group.log.Info("skipping server type currently unavailable in location", "server_type", serverType.Name)
+		default:
+			serverTypes = append(serverTypes, serverType)
+		}
+	}
+
+	// The availability might be outdated, let the API decide when no server type is
+	// available.
+	if len(serverTypes) == 0 {
+		group.log.Warn("no server type available in location, trying all server types")
+		return nil
+	}
+
+	h.serverTypes = serverTypes
+
+	return nil
+}
+
 func (h *ServerHandler) Create(ctx context.Context, group *instanceGroup, instance *Instance) error {
 	instance.opts.Name = instance.Name
 	instance.opts.Labels = group.labels
+	if group.config.RecycleMode == RecycleModeRebuild || group.config.ParkingEnabled {
+		instance.opts.Labels = maps.Clone(group.labels)
+		instance.opts.Labels[sshKeysLabel] = group.sshKeysHash
+	}
 	instance.opts.Location = group.location
 	instance.opts.Image = group.image
 	instance.opts.SSHKeys = group.sshKeys
-	instance.opts.UserData = group.config.UserData
 	instance.opts.PublicNet.EnableIPv4 = !group.config.PublicIPv4Disabled
 	instance.opts.PublicNet.EnableIPv6 = !group.config.PublicIPv6Disabled
 	instance.opts.Networks = group.privateNetworks
@@ -28,12 +74,30 @@ func (h *ServerHandler) Create(ctx context.Context, group *instanceGroup, instan
 	var result hcloud.ServerCreateResult
 	var err error
 
-	for _, serverType := range group.serverTypes {
-		instance.opts.ServerType = serverType
+	serverTypes := h.serverTypes
+	if serverTypes == nil {
+		serverTypes = group.serverTypes
+	}
+
+	for attempt := 1; ; attempt++ {
+		for _, serverType := range serverTypes {
+			instance.opts.ServerType = serverType
+
+			result, _, err = group.client.Server.Create(ctx, *instance.opts)
+			if err != nil && hcloud.IsError(err, hcloud.ErrorCodeResourceUnavailable) {
+				group.log.Warn("resource unavailable", "server_type", serverType.Name, "err", err)
+				group.record(eventlog.ServerTypeFallback, instance, time.Time{}, err)
+				continue
+			}
+			break
+		}
 
-		result, _, err = group.client.Server.Create(ctx, *instance.opts)
-		if err != nil && hcloud.IsError(err, hcloud.ErrorCodeResourceUnavailable) {
-			group.log.Warn("resource unavailable", "server_type", serverType.Name, "err", err)
+		// Retry with a new name when the name is already used. Resources created by
+		// previous handlers are named after the instance, so we only rename the
+		// instance when the server is the first created resource.
+		if err != nil && hcloud.IsError(err, hcloud.ErrorCodeUniquenessError) &&
+			len(instance.opts.Volumes) == 0 && attempt < maxNameAttempts {
+			group.renameInstance(instance)
 			continue
 		}
 		break
@@ -42,10 +106,16 @@ func (h *ServerHandler) Create(ctx context.Context, group *instanceGroup, instan
 		return fmt.Errorf("could not request instance creation: %w", err)
 	}
 
+	requested := time.Now()
+
+	password := instance.Password
 	*instance = *InstanceFromServer(result.Server)
+	instance.Password = password
 
 	instance.waitFn = func() error {
-		if err := group.client.Action.WaitFor(ctx, actionutil.AppendNext(result.Action, result.NextActions)...); err != nil {
+		err := group.waitForActions(ctx, "server creation", group.config.ServerCreateTimeout, requested,
+			actionutil.AppendNext(result.Action, result.NextActions)...)
+		if err != nil {
 			return fmt.Errorf("could not create instance: %w", err)
 		}
 
@@ -69,8 +139,10 @@ func (h *ServerHandler) Cleanup(ctx context.Context, group *instanceGroup, insta
 		return fmt.Errorf("could not request instance deletion: %w", err)
 	}
 
+	requested := time.Now()
+
 	instance.waitFn = func() error {
-		if err := group.client.Action.WaitFor(ctx, result.Action); err != nil {
+		if err := group.waitForActions(ctx, "server deletion", group.config.ServerDeleteTimeout, requested, result.Action); err != nil {
 			return fmt.Errorf("could not delete instance: %w", err)
 		}
 		return nil
@@ -2,15 +2,101 @@ package instancegroup
 
 import (
 	"context"
+	"net/http"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
 	"github.com/hetznercloud/hcloud-go/v2/hcloud/exp/mockutil"
 	"github.com/hetznercloud/hcloud-go/v2/hcloud/schema"
 )
 
+func TestServerHandlerPreIncrease(t *testing.T) {
+	datacentersRequest := func(supported, available []int64) mockutil.Request {
+		return mockutil.Request{
+			Method: "GET", Path: "/datacenters?page=1&per_page=50",
+			Status: 200,
+			JSON: schema.DatacenterListResponse{
+				Datacenters: []schema.Datacenter{
+					{ID: 3, Name: "hel1-dc2",
+						Location:    schema.Location{ID: 3, Name: "hel1"},
+						ServerTypes: schema.DatacenterServerTypes{Supported: supported, Available: available},
+					},
+					{ID: 4, Name: "fsn1-dc14",
+						Location:    schema.Location{ID: 1, Name: "fsn1"},
+						ServerTypes: schema.DatacenterServerTypes{Supported: []int64{1, 2}, Available: []int64{1, 2}},
+					},
+				},
+			},
+		}
+	}
+
+	serverTypeNames := func(serverTypes []*hcloud.ServerType) []string {
+		names := make([]string, 0, len(serverTypes))
+		for _, serverType := range serverTypes {
+			names = append(names, serverType.Name)
+		}
+		return names
+	}
+
+	t.Run("all available", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+
+		group := setupInstanceGroup(t, config, []mockutil.Request{
+			datacentersRequest([]int64{1, 2}, []int64{1, 2}),
+		})
+
+		handler := &ServerHandler{}
+		require.NoError(t, handler.PreIncrease(ctx, group))
+		assert.Equal(t, []string{"cpx11", "cx22"}, serverTypeNames(handler.serverTypes))
+
+		// The availability is cached
+		handler = &ServerHandler{}
+		require.NoError(t, handler.PreIncrease(ctx, group))
+		assert.Equal(t, []string{"cpx11", "cx22"}, serverTypeNames(handler.serverTypes))
+	})
+
+	t.Run("skip unavailable and unsupported", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.ServerTypes = []string{"cpx11", "cx22"}
+
+		group := setupInstanceGroup(t, config, []mockutil.Request{
+			datacentersRequest([]int64{2}, []int64{2}),
+		})
+
+		handler := &ServerHandler{}
+		require.NoError(t, handler.PreIncrease(ctx, group))
+		assert.Equal(t, []string{"cx22"}, serverTypeNames(handler.serverTypes))
+
+		group.availability.value.available = map[int64]bool{}
+		require.NoError(t, handler.PreIncrease(ctx, group))
+		assert.Equal(t, []string{"cpx11", "cx22"}, serverTypeNames(handler.serverTypes))
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+
+		group := setupInstanceGroup(t, config, []mockutil.Request{
+			{
+				Method: "GET", Path: "/datacenters?page=1&per_page=50",
+				Status: 403,
+				JSON: schema.ErrorResponse{
+					Error: schema.Error{Code: "forbidden"},
+				},
+			},
+		})
+
+		handler := &ServerHandler{}
+		require.NoError(t, handler.PreIncrease(ctx, group))
+		assert.Equal(t, []string{"cpx11", "cx22"}, serverTypeNames(handler.serverTypes))
+	})
+}
+
 func TestServerHandlerCreate(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		ctx := context.Background()
@@ -122,6 +208,85 @@ func TestServerHandlerCreate(t *testing.T) {
 	})
 }
 
+func TestServerHandlerCreateUniqueness(t *testing.T) {
+	t.Run("success with new name", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+
+		group := setupInstanceGroup(t, config, []mockutil.Request{
+			{
+				Method: "POST", Path: "/servers",
+				Status: 409,
+				JSON: schema.ErrorResponse{
+					Error: schema.Error{
+						Message: "server name is already used",
+						Code:    "uniqueness_error",
+					},
+				},
+			},
+			{
+				Method: "POST", Path: "/servers",
+				Want: func(t *testing.T, r *http.Request) {
+					var payload schema.ServerCreateRequest
+					mustUnmarshal(t, r.Body, &payload)
+					require.Equal(t, "fleeting-a", payload.Name)
+				},
+				Status: 201,
+				JSON: schema.ServerCreateResponse{
+					Server: schema.Server{ID: 1, Name: "fleeting-a"},
+					Action: schema.Action{ID: 101, Status: "running"},
+				},
+			},
+		})
+
+		instance := NewInstance("fleeting-taken")
+		{
+			handler := &BaseHandler{}
+			require.NoError(t, handler.Create(ctx, group, instance))
+		}
+
+		handler := &ServerHandler{}
+
+		require.NoError(t, handler.Create(ctx, group, instance))
+
+		assert.Equal(t, "fleeting-a", instance.Name)
+		assert.Equal(t, int64(1), instance.ID)
+	})
+
+	t.Run("failure with volume", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+
+		group := setupInstanceGroup(t, config, []mockutil.Request{
+			{
+				Method: "POST", Path: "/servers",
+				Status: 409,
+				JSON: schema.ErrorResponse{
+					Error: schema.Error{
+						Message: "server name is already used",
+						Code:    "uniqueness_error",
+					},
+				},
+			},
+		})
+
+		instance := NewInstance("fleeting-taken")
+		{
+			handler := &BaseHandler{}
+			require.NoError(t, handler.Create(ctx, group, instance))
+		}
+		instance.opts.Volumes = []*hcloud.Volume{{ID: 1}}
+
+		handler := &ServerHandler{}
+
+		require.EqualError(t,
+			handler.Create(ctx, group, instance),
+			"could not request instance creation: server name is already used (uniqueness_error)",
+		)
+		assert.Equal(t, "fleeting-taken", instance.Name)
+	})
+}
+
 func TestServerHandlerCleanup(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		ctx := context.Background()
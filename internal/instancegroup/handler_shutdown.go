@@ -0,0 +1,72 @@
+package instancegroup
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// shutdownPollInterval is the interval between the checks of the server status, while
+// waiting for the server to power off.
+const shutdownPollInterval = time.Second
+
+// ShutdownHandler gracefully shuts down the server of the instance before its deletion.
+//
+// A failed or timed out shutdown never prevents the deletion of the server.
+type ShutdownHandler struct{}
+
+var _ CleanupHandler = (*ShutdownHandler)(nil)
+
+func (h *ShutdownHandler) Cleanup(ctx context.Context, group *instanceGroup, instance *Instance) error {
+	if group.config.GracefulShutdownTimeout == 0 || instance.ID == 0 {
+		return nil
+	}
+
+	_, _, err := group.client.Server.Shutdown(ctx, &hcloud.Server{ID: instance.ID})
+	if err != nil {
+		if !hcloud.IsError(err, hcloud.ErrorCodeNotFound) {
+			group.log.Warn("could not request instance shutdown", "name", instance.Name, "id", instance.ID, "error", err)
+		}
+		return nil
+	}
+
+	// The deadline is computed when the shutdown is requested, so the waits of the
+	// instances overlap, and the decrease is delayed at most once by the timeout.
+	deadline := time.Now().Add(group.config.GracefulShutdownTimeout)
+
+	instance.waitFn = func() error {
+		h.waitForPowerOff(ctx, group, instance, deadline)
+		return nil
+	}
+
+	return nil
+}
+
+// waitForPowerOff waits until the server is powered off, or until the deadline is
+// reached.
+func (h *ShutdownHandler) waitForPowerOff(ctx context.Context, group *instanceGroup, instance *Instance, deadline time.Time) {
+	waitCtx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	for {
+		server, _, err := group.client.Server.GetByID(waitCtx, instance.ID)
+		switch {
+		case err == nil && (server == nil || server.Status == hcloud.ServerStatusOff):
+			return
+		case err != nil && !errors.Is(err, context.DeadlineExceeded):
+			group.log.Warn("could not check instance shutdown", "name", instance.Name, "id", instance.ID, "error", err)
+			return
+		}
+
+		select {
+		case <-waitCtx.Done():
+			if ctx.Err() == nil {
+				group.log.Warn("instance did not shutdown in time, deleting anyway", "name", instance.Name, "id", instance.ID)
+			}
+			return
+		case <-time.After(shutdownPollInterval):
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package instancegroup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/exp/mockutil"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/schema"
+)
+
+func TestShutdownHandlerCleanup(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.GracefulShutdownTimeout = time.Minute
+
+		group := setupInstanceGroup(t, config, []mockutil.Request{
+			{
+				Method: "POST", Path: "/servers/1/actions/shutdown",
+				Status: 201,
+				JSON: schema.ServerActionShutdownResponse{
+					Action: schema.Action{ID: 101, Status: "running"},
+				},
+			},
+			{
+				Method: "GET", Path: "/servers/1",
+				Status: 200,
+				JSON: schema.ServerGetResponse{
+					Server: schema.Server{ID: 1, Name: "fleeting-a", Status: "off"},
+				},
+			},
+		})
+
+		instance := &Instance{Name: "fleeting-a", ID: 1}
+
+		handler := &ShutdownHandler{}
+
+		require.NoError(t, handler.Cleanup(ctx, group, instance))
+		require.NotNil(t, instance.waitFn)
+		require.NoError(t, instance.wait())
+	})
+
+	t.Run("success not found", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.GracefulShutdownTimeout = time.Minute
+
+		group := setupInstanceGroup(t, config, []mockutil.Request{
+			{
+				Method: "POST", Path: "/servers/1/actions/shutdown",
+				Status: 404,
+				JSON: schema.ErrorResponse{
+					Error: schema.Error{Code: "not_found"},
+				},
+			},
+		})
+
+		instance := &Instance{Name: "fleeting-a", ID: 1}
+
+		handler := &ShutdownHandler{}
+
+		require.NoError(t, handler.Cleanup(ctx, group, instance))
+		assert.Nil(t, instance.waitFn)
+	})
+
+	t.Run("failure does not prevent deletion", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.GracefulShutdownTimeout = time.Minute
+
+		group := setupInstanceGroup(t, config, []mockutil.Request{
+			{
+				Method: "POST", Path: "/servers/1/actions/shutdown",
+				Status: 403,
+				JSON: schema.ErrorResponse{
+					Error: schema.Error{Code: "forbidden"},
+				},
+			},
+		})
+
+		instance := &Instance{Name: "fleeting-a", ID: 1}
+
+		handler := &ShutdownHandler{}
+
+		require.NoError(t, handler.Cleanup(ctx, group, instance))
+		assert.Nil(t, instance.waitFn)
+	})
+
+	t.Run("passthrough", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+
+		group := setupInstanceGroup(t, config, []mockutil.Request{})
+
+		instance := &Instance{Name: "fleeting-a", ID: 1}
+
+		handler := &ShutdownHandler{}
+
+		require.NoError(t, handler.Cleanup(ctx, group, instance))
+		assert.Nil(t, instance.waitFn)
+	})
+}
@@ -0,0 +1,120 @@
+package instancegroup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// SSHKeyUserLabelPrefix is the prefix of the SSH key labels referencing the instance
+// groups using a SSH key managed by the plugin.
+const SSHKeyUserLabelPrefix = "used-by-"
+
+// managedByLabel is the label of the resources managed by the plugin.
+const managedByLabel = "managed-by"
+
+// ReleaseSSHKey removes the user label of an instance group from a SSH key managed by
+// the plugin, and deletes the SSH key once no instance group uses it. SSH keys not
+// managed by the plugin are left untouched.
+//
+// Returns whether the SSH key was deleted.
+func ReleaseSSHKey(ctx context.Context, client *hcloud.Client, sshKey *hcloud.SSHKey, userLabel string) (bool, error) {
+	// Refresh the labels, other instance groups might have updated them.
+	sshKey, _, err := client.SSHKey.GetByID(ctx, sshKey.ID)
+	if err != nil {
+		return false, fmt.Errorf("could not get ssh key: %w", err)
+	}
+	if sshKey == nil {
+		return false, nil
+	}
+	if _, ok := sshKey.Labels[managedByLabel]; !ok {
+		return false, nil
+	}
+
+	labels := maps.Clone(sshKey.Labels)
+	delete(labels, userLabel)
+
+	if SSHKeyUsed(labels) {
+		if len(labels) == len(sshKey.Labels) {
+			return false, nil
+		}
+
+		_, _, err = client.SSHKey.Update(ctx, sshKey, hcloud.SSHKeyUpdateOpts{Labels: labels})
+		if err != nil {
+			return false, fmt.Errorf("could not update ssh key: %w", err)
+		}
+		return false, nil
+	}
+
+	_, err = client.SSHKey.Delete(ctx, sshKey)
+	if err != nil {
+		return false, fmt.Errorf("could not delete ssh key: %w", err)
+	}
+	return true, nil
+}
+
+// SSHKeyUsed returns whether the labels of a SSH key reference an instance group.
+func SSHKeyUsed(labels map[string]string) bool {
+	for key := range labels {
+		if strings.HasPrefix(key, SSHKeyUserLabelPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// SSHKeyHandler releases the SSH keys left over by previous runs of the instance group,
+// for example after a crash.
+type SSHKeyHandler struct{}
+
+var _ SanityHandler = (*SSHKeyHandler)(nil)
+
+func (h *SSHKeyHandler) Sanity(ctx context.Context, group *instanceGroup) error {
+	if group.config.SSHKeyUserLabel == "" {
+		return nil
+	}
+
+	sshKeys, err := group.client.SSHKey.AllWithOpts(ctx,
+		hcloud.SSHKeyListOpts{
+			ListOpts: hcloud.ListOpts{
+				LabelSelector: group.config.SSHKeyUserLabel,
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("could not list ssh keys: %w", err)
+	}
+
+	errs := make([]error, 0)
+	for _, sshKey := range sshKeys {
+		if group.usesSSHKey(sshKey) {
+			continue
+		}
+
+		deleted, err := ReleaseSSHKey(ctx, group.client, sshKey, group.config.SSHKeyUserLabel)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if deleted {
+			group.log.Info("deleted leaked ssh key", "name", sshKey.Name, "id", sshKey.ID)
+		} else {
+			group.log.Info("released leaked ssh key", "name", sshKey.Name, "id", sshKey.ID)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (g *instanceGroup) usesSSHKey(sshKey *hcloud.SSHKey) bool {
+	for _, used := range g.sshKeys {
+		if used.ID == sshKey.ID {
+			return true
+		}
+	}
+	return false
+}
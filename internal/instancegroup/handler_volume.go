@@ -3,9 +3,12 @@ package instancegroup
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/hetznercloud/hcloud-go/v2/hcloud"
 	"github.com/hetznercloud/hcloud-go/v2/hcloud/exp/actionutil"
+
+	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/eventlog"
 )
 
 // VolumeHandler creates a volume and updates the instance server create options with
@@ -31,16 +34,30 @@ func (h *VolumeHandler) Create(ctx context.Context, group *instanceGroup, instan
 	}
 
 	// Create a volume
-	result, _, err := group.client.Volume.Create(ctx, hcloud.VolumeCreateOpts{
-		Name:     instance.Name,
-		Size:     group.config.VolumeSize,
-		Location: group.location,
-		Labels:   group.labels,
-	})
+	var result hcloud.VolumeCreateResult
+	var err error
+
+	for attempt := 1; ; attempt++ {
+		result, _, err = group.client.Volume.Create(ctx, hcloud.VolumeCreateOpts{
+			Name:     instance.Name,
+			Size:     group.config.VolumeSize,
+			Location: group.location,
+			Labels:   group.labels,
+		})
+
+		// Retry with a new name when the name is already used.
+		if err != nil && hcloud.IsError(err, hcloud.ErrorCodeUniquenessError) && attempt < maxNameAttempts {
+			group.renameInstance(instance)
+			continue
+		}
+		break
+	}
 	if err != nil {
 		return fmt.Errorf("could not request volume creation: %w", err)
 	}
 
+	requested := time.Now()
+
 	// Add volume to server creation opts
 	instance.opts.Volumes = append(instance.opts.Volumes, result.Volume)
 
@@ -49,7 +66,9 @@ func (h *VolumeHandler) Create(ctx context.Context, group *instanceGroup, instan
 
 	instance.waitFn = func() error {
 		// Wait for the volume to be created
-		if err := group.client.Action.WaitFor(ctx, actionutil.AppendNext(result.Action, result.NextActions)...); err != nil {
+		err := group.waitForActions(ctx, "volume creation", group.config.VolumeCreateTimeout, requested,
+			actionutil.AppendNext(result.Action, result.NextActions)...)
+		if err != nil {
 			return fmt.Errorf("could not create volume: %w", err)
 		}
 
@@ -74,6 +93,9 @@ func (h *VolumeHandler) PreDecrease(ctx context.Context, group *instanceGroup) e
 	}
 
 	for _, volume := range volumes {
+		if !group.owns("volume", volume.ID, volume.Name, volume.Labels) {
+			continue
+		}
 		h.volumes[volume.Name] = volume
 	}
 
@@ -111,15 +133,21 @@ func (h *VolumeHandler) Sanity(ctx context.Context, group *instanceGroup) error
 	}
 
 	for _, volume := range volumes {
-		if volume.Server != nil {
+		if volume.Server != nil || !group.owns("volume", volume.ID, volume.Name, volume.Labels) {
 			continue
 		}
 
 		group.log.Warn("deleting dangling volume", "name", volume.Name, "id", volume.ID)
+		if volume.Protection.Delete {
+			if err := group.unprotectVolume(ctx, volume); err != nil {
+				return err
+			}
+		}
 		_, err := group.client.Volume.Delete(ctx, volume)
 		if err != nil {
 			return fmt.Errorf("could not request volume deletion: %w", err)
 		}
+		group.config.EventLog.Record(eventlog.Event{Type: eventlog.DanglingVolumeDeleted, Name: volume.Name, VolumeID: volume.ID})
 	}
 
 	return nil
@@ -57,6 +57,52 @@ func TestVolumeHandlerCreate(t *testing.T) {
 		assert.Equal(t, int64(1), instance.opts.Volumes[0].ID)
 	})
 
+	t.Run("success with new name", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.VolumeSize = 10
+
+		group := setupInstanceGroup(t, config, []mockutil.Request{
+			{
+				Method: "POST", Path: "/volumes",
+				Status: 409,
+				JSON: schema.ErrorResponse{
+					Error: schema.Error{
+						Message: "volume name is already used",
+						Code:    "uniqueness_error",
+					},
+				},
+			},
+			{
+				Method: "POST", Path: "/volumes",
+				Want: func(t *testing.T, r *http.Request) {
+					var payload schema.VolumeCreateRequest
+					mustUnmarshal(t, r.Body, &payload)
+					require.Equal(t, "fleeting-a", payload.Name)
+				},
+				Status: 201,
+				JSON: schema.VolumeCreateResponse{
+					Volume: schema.Volume{ID: 1, Name: "fleeting-a"},
+					Action: &schema.Action{ID: 101, Status: "running"},
+				},
+			},
+		})
+
+		instance := NewInstance("fleeting-taken")
+		{
+			handler := &BaseHandler{}
+			require.NoError(t, handler.Create(ctx, group, instance))
+		}
+
+		handler := &VolumeHandler{}
+
+		require.NoError(t, handler.PreIncrease(ctx, group))
+		require.NoError(t, handler.Create(ctx, group, instance))
+
+		assert.Equal(t, "fleeting-a", instance.Name)
+		assert.Equal(t, "fleeting-a", handler.volumes[instance.Name].Name)
+	})
+
 	t.Run("disabled", func(t *testing.T) {
 		ctx := context.Background()
 		config := DefaultTestConfig
@@ -50,6 +50,24 @@ func setupInstanceGroup(t *testing.T, config Config, requests []mockutil.Request
 	return group
 }
 
+// setupInstanceGroupWithFakeAPI creates an instance group backed by a stateful fake
+// of the API.
+func setupInstanceGroupWithFakeAPI(t *testing.T, config Config, opts ...testutils.FakeAPIOption) (*instanceGroup, *testutils.FakeAPI) {
+	t.Helper()
+
+	api := testutils.NewFakeAPI(t, opts...)
+
+	log := hclog.New(hclog.DefaultOptions)
+
+	group := &instanceGroup{name: "fleeting", config: config, log: log, client: api.Client()}
+	group.randomNameFn = makeRandomNameFn(group.name)
+
+	err := group.Init(context.Background())
+	require.NoError(t, err)
+
+	return group, api
+}
+
 func makeRandomNameFn(prefix string) func() string {
 	offset := 96
 	index := 0
@@ -17,6 +17,14 @@ type Instance struct {
 	// Server is the instance's underlying server, and must never be partially populated.
 	Server *hcloud.Server
 
+	// Password of the instance administrator account, only populated for Windows
+	// instances.
+	Password string
+
+	// Deleting is set when the instance failed to be created in the background, and is
+	// being deleted.
+	Deleting bool
+
 	// waitFn is used to postpone long background/remote tasks in between each handlers.
 	//
 	// This allows to trigger the creation of 3 servers in parallel, and only wait once
@@ -60,6 +68,28 @@ func (i *Instance) IID() string {
 	return fmt.Sprintf("%s:%d", i.Name, i.ID)
 }
 
+// Owner returns the owner token of the instance server, empty when the server has no
+// owner.
+func (i *Instance) Owner() string {
+	if i.Server == nil {
+		return ""
+	}
+	return i.Server.Labels[ownerLabel]
+}
+
+// serverType returns the name of the server type of the instance, or of the server
+// type being tried while the server is created.
+func (i *Instance) serverType() string {
+	switch {
+	case i.Server != nil && i.Server.ServerType != nil:
+		return i.Server.ServerType.Name
+	case i.opts != nil && i.opts.ServerType != nil:
+		return i.opts.ServerType.Name
+	default:
+		return ""
+	}
+}
+
 func (i *Instance) wait() error {
 	if i.waitFn == nil {
 		return nil
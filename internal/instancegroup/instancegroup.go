@@ -7,13 +7,18 @@ import (
 	"maps"
 	"reflect"
 	"slices"
+	"sync"
+	"text/template"
+	"time"
 
 	"github.com/hashicorp/go-hclog"
 
 	"github.com/hetznercloud/hcloud-go/v2/hcloud"
-	"github.com/hetznercloud/hcloud-go/v2/hcloud/exp/kit/randutil"
 
+	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/dns"
+	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/eventlog"
 	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/ippool"
+	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/tracing"
 )
 
 type InstanceGroup interface {
@@ -21,11 +26,18 @@ type InstanceGroup interface {
 
 	Increase(ctx context.Context, delta int) ([]string, error)
 	Decrease(ctx context.Context, iids []string) ([]string, error)
+	Delete(ctx context.Context, iids []string) ([]string, error)
 
 	List(ctx context.Context) ([]*Instance, error)
 	Get(ctx context.Context, iid string) (*Instance, error)
 
 	Sanity(ctx context.Context) error
+	ExpireParked(ctx context.Context) error
+	CheckOwners(ctx context.Context) error
+
+	Report(ctx context.Context) (*Report, error)
+
+	Wait(ctx context.Context) error
 }
 
 var _ InstanceGroup = (*instanceGroup)(nil)
@@ -49,24 +61,49 @@ type instanceGroup struct {
 	client *hcloud.Client
 	ipPool *ippool.IPPool
 
+	dns               *dns.Client
+	dnsZone           *dns.Zone
+	dnsRecordTemplate *template.Template
+
 	location                *hcloud.Location
 	serverTypes             []*hcloud.ServerType
 	serverTypesArchitecture hcloud.Architecture
 	image                   *hcloud.Image
 	privateNetworks         []*hcloud.Network
+	loadBalancers           []*hcloud.LoadBalancer
 	sshKeys                 []*hcloud.SSHKey
+	sshKeysHash             string
 	labels                  map[string]string
 
+	// passwords holds the administrator password of the Windows instances, indexed by
+	// instance name.
+	passwords sync.Map
+
 	randomNameFn func() string
+
+	availability availabilityCache
+
+	cache instanceCache
+
+	// foreign holds the resources of another owner that were already warned about.
+	foreign sync.Map
+
+	// tracked counts the increases tracked in the background.
+	tracked sync.WaitGroup
+	// deleting holds the names of the instances that failed to be created in the
+	// background, and are being deleted.
+	deleting sync.Map
+	// cleaning holds the server IDs of the instances whose deletion started, and is
+	// not complete.
+	cleaning sync.Map
+
+	// parkingMu guards the reserved parked servers.
+	parkingMu sync.Mutex
+	// parkingReserved holds the IDs of the parked servers being reused or deleted.
+	parkingReserved map[int64]struct{}
 }
 
 func (g *instanceGroup) Init(ctx context.Context) (err error) {
-	if g.randomNameFn == nil {
-		g.randomNameFn = func() string {
-			return g.name + "-" + randutil.GenerateID()
-		}
-	}
-
 	// Location
 	g.location, _, err = g.client.Location.Get(ctx, g.config.Location)
 	if err != nil {
@@ -118,6 +155,23 @@ func (g *instanceGroup) Init(ctx context.Context) (err error) {
 		g.privateNetworks = append(g.privateNetworks, network)
 	}
 
+	// Load Balancers
+	g.loadBalancers = make([]*hcloud.LoadBalancer, 0, len(g.config.LoadBalancers))
+	for _, loadBalancerID := range g.config.LoadBalancers {
+		loadBalancer, _, err := g.client.LoadBalancer.Get(ctx, loadBalancerID)
+		if err != nil {
+			return fmt.Errorf("could not get load balancer: %w", err)
+		}
+		if loadBalancer == nil {
+			return fmt.Errorf("load balancer not found: %s", loadBalancerID)
+		}
+		if g.config.LoadBalancerUsePrivateIP && !g.attachedToPrivateNetworks(loadBalancer) {
+			return fmt.Errorf("load balancer is not attached to any of the private networks: %s", loadBalancerID)
+		}
+
+		g.loadBalancers = append(g.loadBalancers, loadBalancer)
+	}
+
 	// SSH Keys
 	g.sshKeys = make([]*hcloud.SSHKey, 0, len(g.config.SSHKeys))
 	for _, sshKeyID := range g.config.SSHKeys {
@@ -131,26 +185,85 @@ func (g *instanceGroup) Init(ctx context.Context) (err error) {
 
 		g.sshKeys = append(g.sshKeys, sshKey)
 	}
+	g.sshKeysHash = sshKeysHash(g.sshKeys)
 
 	g.labels = make(map[string]string, len(g.config.Labels)+1)
 	if g.config.Labels != nil {
 		maps.Copy(g.labels, g.config.Labels)
 	}
 	g.labels["instance-group"] = g.name
+	if g.config.OwnerToken != "" {
+		g.labels[ownerLabel] = g.config.OwnerToken
+	}
+
+	// Instance names
+	if g.randomNameFn == nil {
+		nameTemplate := g.config.NameTemplate
+		if nameTemplate == "" {
+			nameTemplate = DefaultNameTemplate
+		}
+
+		g.randomNameFn, err = newNameFn(nameTemplate, NameTemplateData{
+			Name:     g.name,
+			Location: g.location.Name,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	g.cache.ttl = g.config.InstanceCacheTTL
 
 	if g.config.PublicIPPoolEnabled {
 		g.ipPool = ippool.New(g.config.Location, g.config.PublicIPPoolSelector)
 	}
 
+	// DNS
+	if g.config.DNS != nil {
+		if err := g.initDNS(ctx); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 func (g *instanceGroup) Increase(ctx context.Context, delta int) ([]string, error) {
-	handlers := []CreateHandler{
-		&BaseHandler{},   // Configure the instance server create options from the instance group config.
-		&IPPoolHandler{}, // Configure the IPs in the instance server create options.
-		&VolumeHandler{}, // Create and configure a volume in the instance server create options.
-		&ServerHandler{}, // Create a server from the instance server create options.
+	created := make([]string, 0, delta)
+
+	// Reuse the parked instances first
+	if g.parking() {
+		for _, instance := range g.unpark(ctx, delta) {
+			created = append(created, instance.IID())
+		}
+
+		if len(created) > 0 {
+			// The reused instances are renamed, refresh the cache on the next list.
+			g.cache.invalidate()
+		}
+
+		delta -= len(created)
+		if delta == 0 {
+			return created, nil
+		}
+	}
+
+	handlers := make([]CreateHandler, 0)
+	if g.config.Hooks.PreCreate != nil {
+		handlers = append(handlers, &CreateHookHandler{phase: hookPreCreate}) // Run the pre create hook.
+	}
+	handlers = append(handlers,
+		&BaseHandler{},         // Configure the instance server create options from the instance group config.
+		&PasswordHandler{},     // Configure the administrator password in the instance server create options.
+		&IPPoolHandler{},       // Configure the IPs in the instance server create options.
+		&VolumeHandler{},       // Create and configure a volume in the instance server create options.
+		&ServerHandler{},       // Create a server from the instance server create options.
+		&ProtectionHandler{},   // Protect the instance server and volumes against deletion.
+		&LoadBalancerHandler{}, // Add the instance server to the load balancers targets.
+		&DNSHandler{},          // Create the DNS records of the instance server.
+	)
+	if g.config.Hooks.PostCreate != nil {
+		handlers = append(handlers, &CreateHookHandler{phase: hookPostCreate}) // Run the post create hook.
 	}
 
 	// Run all pre increase handlers
@@ -171,17 +284,27 @@ func (g *instanceGroup) Increase(ctx context.Context, delta int) ([]string, erro
 	failed := make([]*Instance, 0, delta)
 
 	// Create a list of new instances
+	start := time.Now()
 	for i := 0; i < delta; i++ {
-		instances = append(instances, NewInstance(g.randomNameFn()))
+		instance := NewInstance(g.randomNameFn())
+		g.record(eventlog.InstanceRequested, instance, time.Time{}, nil)
+		instances = append(instances, instance)
+	}
+
+	if g.config.AsyncIncrease {
+		// The creation of the servers is tracked after the increase returned.
+		ctx = context.WithoutCancel(ctx)
 	}
 
 	// Run all create handlers on each instance
-	for _, handler := range handlers {
+	for index, handler := range handlers {
 		{
 			succeeded := make([]*Instance, 0, len(instances))
 			for _, instance := range instances {
-				if err := handler.Create(ctx, g, instance); err != nil {
-					errs = append(errs, err)
+				if err := g.create(ctx, handler, instance); err != nil {
+					instanceErr := newInstanceError(handler, instance, err)
+					g.record(eventlog.InstanceCreateFailed, instance, start, instanceErr)
+					errs = append(errs, instanceErr)
 					failed = append(failed, instance)
 				} else {
 					succeeded = append(succeeded, instance)
@@ -190,12 +313,21 @@ func (g *instanceGroup) Increase(ctx context.Context, delta int) ([]string, erro
 			instances = succeeded
 		}
 
+		// Track the server handler background tasks and run the next handlers, without
+		// waiting for them
+		if _, ok := handler.(*ServerHandler); ok && g.config.AsyncIncrease {
+			g.track(ctx, handlers, index, instances, start)
+			break
+		}
+
 		// Wait for each instance background tasks to complete
 		{
 			succeeded := make([]*Instance, 0, len(instances))
 			for _, instance := range instances {
-				if err := instance.wait(); err != nil {
-					errs = append(errs, err)
+				if err := g.wait(ctx, handler, instance); err != nil {
+					instanceErr := newInstanceError(handler, instance, err)
+					g.record(eventlog.InstanceCreateFailed, instance, start, instanceErr)
+					errs = append(errs, instanceErr)
 					failed = append(failed, instance)
 				} else {
 					succeeded = append(succeeded, instance)
@@ -207,44 +339,246 @@ func (g *instanceGroup) Increase(ctx context.Context, delta int) ([]string, erro
 
 	// Cleanup failed instances
 	if len(failed) > 0 {
-		// During cleanup, the handlers must be run backwards
-		slices.Reverse(handlers)
-
-		// Run all cleanup handlers on each failed instance
-		for _, handler := range handlers {
-			h, ok := handler.(CleanupHandler)
-			if !ok {
-				continue
+		errs = append(errs, g.cleanup(ctx, handlers, failed)...)
+	}
+
+	// Collect created instances IIDs
+	for _, instance := range instances {
+		if !g.config.AsyncIncrease {
+			g.record(eventlog.InstanceCreated, instance, start, nil)
+		}
+		created = append(created, instance.IID())
+	}
+
+	// The servers of the create responses are still initializing, refresh the cache on
+	// the next list.
+	g.cache.invalidate()
+
+	return created, errors.Join(errs...)
+}
+
+// cleanup runs the cleanup handlers on the instances that failed to be created.
+func (g *instanceGroup) cleanup(ctx context.Context, handlers []CreateHandler, failed []*Instance) []error {
+	errs := make([]error, 0)
+
+	start := time.Now()
+	instanceErrs := make(map[*Instance][]error, len(failed))
+
+	// During cleanup, the handlers must be run backwards
+	handlers = slices.Clone(handlers)
+	slices.Reverse(handlers)
+
+	// Run all cleanup handlers on each failed instance
+	for _, handler := range handlers {
+		h, ok := handler.(CleanupHandler)
+		if !ok {
+			continue
+		}
+
+		for _, instance := range failed {
+			if err := g.cleanupInstance(ctx, h, instance); err != nil {
+				instanceErrs[instance] = append(instanceErrs[instance], newInstanceError(h, instance, err))
+			}
+		}
+
+		// Wait for each instance background tasks to complete
+		for _, instance := range failed {
+			if err := g.wait(ctx, h, instance); err != nil {
+				instanceErrs[instance] = append(instanceErrs[instance], newInstanceError(h, instance, err))
 			}
+		}
+	}
+
+	for _, instance := range failed {
+		g.record(eventlog.InstanceCleanup, instance, start, errors.Join(instanceErrs[instance]...))
+		errs = append(errs, instanceErrs[instance]...)
+	}
+
+	return errs
+}
+
+// track waits in the background for the instances background tasks of the handler at
+// the given index to complete, runs the next handlers, and cleans up the instances that
+// failed to be created. The failed instances are reported as deleting until they are
+// deleted.
+func (g *instanceGroup) track(ctx context.Context, handlers []CreateHandler, index int, instances []*Instance, start time.Time) {
+	if len(instances) == 0 {
+		return
+	}
 
-			for _, instance := range failed {
-				if err := h.Cleanup(ctx, g, instance); err != nil {
-					errs = append(errs, err)
+	g.tracked.Add(1)
+	go func() {
+		defer g.tracked.Done()
+
+		failed := make([]*Instance, 0)
+		fail := func(handler CreateHandler, instance *Instance, err error) {
+			instanceErr := newInstanceError(handler, instance, err)
+			g.record(eventlog.InstanceCreateFailed, instance, start, instanceErr)
+			g.logInstanceError("could not create instance", instanceErr)
+			g.deleting.Store(instance.Name, struct{}{})
+			failed = append(failed, instance)
+		}
+
+		for i, handler := range handlers[index:] {
+			// The create handler of the tracked handler already ran
+			if i > 0 {
+				succeeded := make([]*Instance, 0, len(instances))
+				for _, instance := range instances {
+					if err := g.create(ctx, handler, instance); err != nil {
+						fail(handler, instance, err)
+					} else {
+						succeeded = append(succeeded, instance)
+					}
 				}
+				instances = succeeded
 			}
 
-			// Wait for each instance background tasks to complete
-			for _, instance := range failed {
-				if err := instance.wait(); err != nil {
-					errs = append(errs, err)
+			succeeded := make([]*Instance, 0, len(instances))
+			for _, instance := range instances {
+				if err := g.wait(ctx, handler, instance); err != nil {
+					fail(handler, instance, err)
+				} else {
+					succeeded = append(succeeded, instance)
 				}
 			}
+			instances = succeeded
 		}
+
+		for _, instance := range instances {
+			g.record(eventlog.InstanceCreated, instance, start, nil)
+		}
+
+		// The servers listed while they were created are cached as initializing.
+		g.cache.invalidate()
+
+		if len(failed) == 0 {
+			return
+		}
+
+		for _, err := range g.cleanup(ctx, handlers, failed) {
+			g.logInstanceError("could not cleanup instance", err)
+		}
+
+		for _, instance := range failed {
+			g.deleting.Delete(instance.Name)
+		}
+		g.cache.invalidate()
+	}()
+}
+
+// Wait waits for the increases tracked in the background to complete, or for the
+// context to be done.
+func (g *instanceGroup) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		g.tracked.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("could not wait for the tracked increases: %w", ctx.Err())
+	}
+}
+
+func (g *instanceGroup) logInstanceError(msg string, err error) {
+	var instanceErr *InstanceError
+	if errors.As(err, &instanceErr) {
+		g.log.Error(msg,
+			"name", instanceErr.Name,
+			"id", instanceErr.ID,
+			"handler", instanceErr.Handler,
+			"cause", instanceErr.Cause,
+			"error", instanceErr.Err,
+		)
+		return
 	}
+	g.log.Error(msg, "error", err)
+}
 
-	// Collect created instances IIDs
-	created := make([]string, 0, len(instances))
+func (g *instanceGroup) Decrease(ctx context.Context, iids []string) ([]string, error) {
+	return g.decrease(ctx, iids, true)
+}
+
+// Delete deletes the instances, without recycling them by rebuilding or parking them.
+func (g *instanceGroup) Delete(ctx context.Context, iids []string) ([]string, error) {
+	return g.decrease(ctx, iids, false)
+}
+
+func (g *instanceGroup) decrease(ctx context.Context, iids []string, recycle bool) ([]string, error) {
+	errs := make([]error, 0)
+
+	instances := make([]*Instance, 0, len(iids))
+
+	// IIDs of the instances, which change when the instances are rebuilt.
+	instanceIIDs := make(map[*Instance]string, len(iids))
+
+	// Populate a list of instances from their IIDs
+	for _, iid := range iids {
+		instance, err := InstanceFromIID(iid)
+		if err != nil {
+			errs = append(errs, &InstanceError{Name: iid, Cause: CauseInvalidConfig, Err: err})
+			continue
+		}
+		instances = append(instances, instance)
+		instanceIIDs[instance] = iid
+	}
+
+	deleted := make([]string, 0, len(instances))
+
+	// Park the instances, to rebuild them for the next instances when capacity is
+	// requested again. The instances that could not be parked are deleted.
+	if recycle && g.parking() {
+		var parked []*Instance
+		parked, instances = g.park(ctx, instances)
+
+		for _, instance := range parked {
+			deleted = append(deleted, instanceIIDs[instance])
+		}
+		g.cache.remove(parked...)
+	}
+
+	if len(instances) > 0 {
+		var err error
+		instances, err = g.delete(ctx, instances)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// Collect deleted instances IIDs
 	for _, instance := range instances {
-		created = append(created, instance.IID())
+		deleted = append(deleted, instanceIIDs[instance])
 	}
 
-	return created, errors.Join(errs...)
+	if len(errs) > 0 {
+		g.cache.invalidate()
+	} else {
+		g.cache.remove(instances...)
+	}
+
+	return deleted, errors.Join(errs...)
 }
 
-func (g *instanceGroup) Decrease(ctx context.Context, iids []string) ([]string, error) {
-	handlers := []CleanupHandler{
-		&ServerHandler{}, // Delete the server of the instance.
-		&VolumeHandler{}, // Delete the volume of the instance.
+// delete deletes the instances, and returns the deleted instances.
+func (g *instanceGroup) delete(ctx context.Context, instances []*Instance) ([]*Instance, error) {
+	handlers := make([]CleanupHandler, 0)
+	if g.config.Hooks.PreDelete != nil || g.config.Hooks.PostDelete != nil {
+		handlers = append(handlers, &DeleteHookHandler{phase: hookPreDelete}) // Populate the instance server and run the pre delete hook.
+	}
+	handlers = append(handlers,
+		&LoadBalancerHandler{}, // Remove the instance server from the load balancers targets.
+		&DNSHandler{},          // Delete the DNS records of the instance server.
+		&ShutdownHandler{},     // Gracefully shutdown the server of the instance.
+		&ProtectionHandler{},   // Disable the delete protection of the instance server and volumes.
+		&ServerHandler{},       // Delete the server of the instance.
+		&VolumeHandler{},       // Delete the volume of the instance.
+		&PasswordHandler{},     // Forget the administrator password of the instance.
+	)
+	if g.config.Hooks.PostDelete != nil {
+		handlers = append(handlers, &DeleteHookHandler{phase: hookPostDelete}) // Run the post delete hook.
 	}
 
 	// Run all pre decrease handlers
@@ -261,16 +595,12 @@ func (g *instanceGroup) Decrease(ctx context.Context, iids []string) ([]string,
 
 	errs := make([]error, 0)
 
-	instances := make([]*Instance, 0, len(iids))
+	start := time.Now()
 
-	// Populate a list of instances from their IIDs
-	for _, iid := range iids {
-		instance, err := InstanceFromIID(iid)
-		if err != nil {
-			errs = append(errs, err)
-			continue
-		}
-		instances = append(instances, instance)
+	// The instances that failed to be deleted stay marked, for example so their load
+	// balancer targets are not added back.
+	for _, instance := range instances {
+		g.cleaning.Store(instance.ID, struct{}{})
 	}
 
 	// Run all cleanup handlers on each instance
@@ -278,8 +608,10 @@ func (g *instanceGroup) Decrease(ctx context.Context, iids []string) ([]string,
 		{
 			succeeded := make([]*Instance, 0, len(instances))
 			for _, instance := range instances {
-				if err := handler.Cleanup(ctx, g, instance); err != nil {
-					errs = append(errs, err)
+				if err := g.cleanupInstance(ctx, handler, instance); err != nil {
+					instanceErr := newInstanceError(handler, instance, err)
+					g.record(eventlog.InstanceDeleteFailed, instance, start, instanceErr)
+					errs = append(errs, instanceErr)
 				} else {
 					succeeded = append(succeeded, instance)
 				}
@@ -291,8 +623,10 @@ func (g *instanceGroup) Decrease(ctx context.Context, iids []string) ([]string,
 		{
 			succeeded := make([]*Instance, 0, len(instances))
 			for _, instance := range instances {
-				if err := instance.wait(); err != nil {
-					errs = append(errs, err)
+				if err := g.wait(ctx, handler, instance); err != nil {
+					instanceErr := newInstanceError(handler, instance, err)
+					g.record(eventlog.InstanceDeleteFailed, instance, start, instanceErr)
+					errs = append(errs, instanceErr)
 				} else {
 					succeeded = append(succeeded, instance)
 				}
@@ -301,31 +635,51 @@ func (g *instanceGroup) Decrease(ctx context.Context, iids []string) ([]string,
 		}
 	}
 
-	// Collect deleted instances IIDs
-	deleted := make([]string, 0, len(instances))
 	for _, instance := range instances {
-		deleted = append(deleted, instance.IID())
+		g.cleaning.Delete(instance.ID)
+		g.record(eventlog.InstanceDeleted, instance, start, nil)
 	}
 
-	return deleted, errors.Join(errs...)
+	return instances, errors.Join(errs...)
 }
 
 func (g *instanceGroup) List(ctx context.Context) ([]*Instance, error) {
+	if cached, ok := g.cache.list(); ok {
+		instances := make([]*Instance, 0, len(cached))
+		for _, instance := range cached {
+			instances = append(instances, g.instanceFromServer(instance.Server))
+		}
+		return instances, nil
+	}
+
+	labelSelector := fmt.Sprintf("instance-group=%s", g.name)
+	if g.parking() {
+		// Hide the parked instances
+		labelSelector += ",!" + parkedLabel
+	}
+
 	servers, err := g.client.Server.AllWithOpts(ctx,
 		hcloud.ServerListOpts{
 			ListOpts: hcloud.ListOpts{
-				LabelSelector: fmt.Sprintf("instance-group=%s", g.name),
+				LabelSelector: labelSelector,
 			},
 		},
 	)
 	if err != nil {
+		g.cache.invalidate()
 		return nil, fmt.Errorf("could not list instances: %w", err)
 	}
 
+	cached := make([]*Instance, 0, len(servers))
 	instances := make([]*Instance, 0, len(servers))
 	for _, server := range servers {
-		instances = append(instances, InstanceFromServer(server))
+		if !g.owns("server", server.ID, server.Name, server.Labels) {
+			continue
+		}
+		cached = append(cached, InstanceFromServer(server))
+		instances = append(instances, g.instanceFromServer(server))
 	}
+	g.cache.fill(cached)
 
 	return instances, nil
 }
@@ -336,22 +690,63 @@ func (g *instanceGroup) Get(ctx context.Context, iid string) (*Instance, error)
 		return nil, err
 	}
 
+	if cached, ok := g.cache.get(instance.ID); ok {
+		return g.instanceFromServer(cached.Server), nil
+	}
+
 	server, _, err := g.client.Server.GetByID(ctx, instance.ID)
 	if err != nil {
+		g.cache.invalidate()
 		return nil, fmt.Errorf("could not get instance: %w", err)
 	}
+	if server == nil || !g.owns("server", server.ID, server.Name, server.Labels) {
+		g.cache.remove(instance)
+		return nil, fmt.Errorf("%w: %s", ErrInstanceNotFound, iid)
+	}
 
-	return InstanceFromServer(server), nil
+	if _, ok := server.Labels[parkedLabel]; !ok {
+		g.cache.add(InstanceFromServer(server))
+	}
+
+	return g.instanceFromServer(server), nil
 }
 
-func (g *instanceGroup) Sanity(ctx context.Context) error {
+// instanceFromServer creates an instance from a server, and populates the instance
+// details only known by the instance group.
+func (g *instanceGroup) instanceFromServer(server *hcloud.Server) *Instance {
+	instance := InstanceFromServer(server)
+
+	if _, ok := g.deleting.Load(instance.Name); ok {
+		instance.Deleting = true
+	}
+
+	if password, ok := g.passwords.Load(instance.Name); ok {
+		instance.Password = password.(string)
+	}
+
+	return instance
+}
+
+func (g *instanceGroup) Sanity(ctx context.Context) (err error) {
+	ctx, span := tracer().Start(ctx, "Sanity")
+	defer func() { tracing.End(span, err) }()
+
 	handlers := []SanityHandler{
-		&VolumeHandler{}, // Delete dangling volumes.
+		&ParkingHandler{},      // Delete the parked instances at the end of their billed hour.
+		&VolumeHandler{},       // Delete dangling volumes.
+		&SSHKeyHandler{},       // Release leaked ssh keys.
+		&DNSHandler{},          // Delete stale DNS records.
+		&LoadBalancerHandler{}, // Reconcile the load balancers targets.
+		&ProtectionHandler{},   // Warn about the unprotected instances.
+		&PasswordHandler{},     // Delete the Windows instances with an unknown password.
 	}
 
 	// Run all sanity handlers
 	for _, h := range handlers {
-		if err := h.Sanity(ctx, g); err != nil {
+		handlerCtx, handlerSpan := tracer().Start(ctx, handlerName(h)+".Sanity")
+		err := h.Sanity(handlerCtx, g)
+		tracing.End(handlerSpan, err)
+		if err != nil {
 			g.log.With("handler", reflect.TypeOf(h).String()).Error(err.Error())
 		}
 	}
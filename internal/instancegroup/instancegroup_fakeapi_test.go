@@ -0,0 +1,855 @@
+package instancegroup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/exp/kit/sshutil"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/schema"
+
+	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/eventlog"
+	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/testutils"
+)
+
+func TestLifecycleWithFakeAPI(t *testing.T) {
+	t.Run("increase and decrease", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+
+		group, api := setupInstanceGroupWithFakeAPI(t, config, testutils.WithActionDuration(10*time.Millisecond))
+
+		created, err := group.Increase(ctx, 3)
+		require.NoError(t, err)
+		require.Len(t, created, 3)
+
+		servers := api.Servers()
+		require.Len(t, servers, 3)
+		for _, server := range servers {
+			assert.Equal(t, "running", server.Status)
+			assert.Equal(t, "fleeting", server.Labels["instance-group"])
+			assert.Len(t, server.Volumes, 1)
+			assert.Empty(t, server.PublicNet.IPv4.IP)
+			assert.NotEmpty(t, server.PublicNet.IPv6.IP)
+		}
+		require.Len(t, api.Volumes(), 3)
+		require.Len(t, api.PrimaryIPs(), 3)
+
+		instances, err := group.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, instances, 3)
+
+		deleted, err := group.Decrease(ctx, created[:2])
+		require.NoError(t, err)
+		require.Equal(t, created[:2], deleted)
+
+		require.Len(t, api.Servers(), 1)
+		require.Len(t, api.Volumes(), 1)
+		require.Len(t, api.PrimaryIPs(), 1)
+		require.Equal(t, 0, api.RunningActions())
+	})
+
+	t.Run("decrease with graceful shutdown", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.GracefulShutdownTimeout = 200 * time.Millisecond
+
+		group, api := setupInstanceGroupWithFakeAPI(t, config)
+
+		created, err := group.Increase(ctx, 3)
+		require.NoError(t, err)
+		require.Len(t, created, 3)
+
+		// The servers ignoring the shutdown are deleted once the timeout is reached,
+		// the timeout is shared by all the instances.
+		servers := api.Servers()
+		api.SetShutdownIgnored(servers[0].ID)
+		api.SetShutdownIgnored(servers[1].ID)
+
+		start := time.Now()
+		deleted, err := group.Decrease(ctx, created)
+		require.NoError(t, err)
+		require.Equal(t, created, deleted)
+
+		elapsed := time.Since(start)
+		assert.GreaterOrEqual(t, elapsed, config.GracefulShutdownTimeout)
+		assert.Less(t, elapsed, 2*config.GracefulShutdownTimeout)
+
+		require.Empty(t, api.Servers())
+		require.Empty(t, api.Volumes())
+		require.Equal(t, 0, api.RunningActions())
+	})
+
+	t.Run("decrease with rebuild", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.RecycleMode = RecycleModeRebuild
+
+		group, api := setupInstanceGroupWithFakeAPI(t, config)
+		client := api.Client()
+
+		created, err := group.Increase(ctx, 2)
+		require.NoError(t, err)
+		require.Len(t, created, 2)
+
+		before := api.Servers()
+
+		// The removed instance is kept to replace the next instance
+		deleted, err := group.Decrease(ctx, created[:1])
+		require.NoError(t, err)
+		require.Equal(t, created[:1], deleted)
+
+		require.Len(t, api.Servers(), 2)
+
+		instances, err := group.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, instances, 1)
+
+		// The server, its volume and its IPs are kept, under a new name
+		reused, err := group.Increase(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, reused, 1)
+
+		servers := api.Servers()
+		require.Len(t, servers, 2)
+		assert.Equal(t, before[0].ID, servers[0].ID)
+		assert.Equal(t, "fleeting-c", servers[0].Name)
+		assert.Equal(t, "running", servers[0].Status)
+		assert.Equal(t, before[0].PublicNet.IPv6.IP, servers[0].PublicNet.IPv6.IP)
+		assert.Equal(t, before[0].Volumes, servers[0].Volumes)
+
+		volumes := api.Volumes()
+		require.Len(t, volumes, 2)
+		assert.Equal(t, "fleeting-c", volumes[0].Name)
+
+		instances, err = group.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, instances, 2)
+		assert.Equal(t, fmt.Sprintf("fleeting-c:%d", before[0].ID), instances[0].IID())
+
+		// Without a following increase, the removed instances are deleted
+		deleted, err = group.Decrease(ctx, []string{instances[0].IID(), instances[1].IID()})
+		require.NoError(t, err)
+		require.Len(t, deleted, 2)
+
+		instances, err = group.List(ctx)
+		require.NoError(t, err)
+		require.Empty(t, instances)
+
+		for _, server := range api.Servers() {
+			labels := maps.Clone(server.Labels)
+			labels[parkedLabel] = strconv.FormatInt(time.Now().Add(-rebuildWindow).Unix(), 10)
+
+			_, _, err = client.Server.Update(ctx, &hcloud.Server{ID: server.ID}, hcloud.ServerUpdateOpts{Labels: labels})
+			require.NoError(t, err)
+		}
+
+		require.NoError(t, group.Sanity(ctx))
+		require.Empty(t, api.Servers())
+		require.Empty(t, api.Volumes())
+	})
+
+	t.Run("decrease with rebuild and changed ssh keys", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.RecycleMode = RecycleModeRebuild
+
+		group, api := setupInstanceGroupWithFakeAPI(t, config)
+
+		created, err := group.Increase(ctx, 3)
+		require.NoError(t, err)
+		require.Len(t, created, 3)
+
+		deleted, err := group.Decrease(ctx, created[:1])
+		require.NoError(t, err)
+		require.Equal(t, created[:1], deleted)
+		require.Len(t, api.Servers(), 3)
+
+		// Servers created with other ssh keys are deleted
+		group.sshKeysHash = "other"
+
+		deleted, err = group.Decrease(ctx, created[1:])
+		require.NoError(t, err)
+		require.Equal(t, created[1:], deleted)
+		require.Len(t, api.Servers(), 1)
+
+		// The parked server is replaced by a new server
+		reused, err := group.Increase(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, reused, 1)
+
+		servers := api.Servers()
+		require.Len(t, servers, 1)
+		assert.NotEqual(t, created[0], reused[0])
+		assert.Equal(t, "other", servers[0].Labels[sshKeysLabel])
+		require.Len(t, api.Volumes(), 1)
+	})
+
+	t.Run("decrease and increase with parking", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.ParkingEnabled = true
+
+		group, api := setupInstanceGroupWithFakeAPI(t, config)
+
+		created, err := group.Increase(ctx, 2)
+		require.NoError(t, err)
+		require.Len(t, created, 2)
+
+		// The parked server is hidden from the instances
+		deleted, err := group.Decrease(ctx, created[:1])
+		require.NoError(t, err)
+		require.Equal(t, created[:1], deleted)
+
+		require.Len(t, api.Servers(), 2)
+		assert.Contains(t, api.Servers()[0].Labels, "parked")
+
+		instances, err := group.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, instances, 1)
+		assert.Equal(t, created[1], instances[0].IID())
+
+		// The parked server is reused first
+		reused, err := group.Increase(ctx, 2)
+		require.NoError(t, err)
+		require.Len(t, reused, 2)
+
+		servers := api.Servers()
+		require.Len(t, servers, 3)
+		assert.Equal(t, "fleeting-c", servers[0].Name)
+		assert.NotContains(t, servers[0].Labels, "parked")
+		assert.Equal(t, fmt.Sprintf("fleeting-c:%d", servers[0].ID), reused[0])
+
+		instances, err = group.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, instances, 3)
+	})
+
+	t.Run("delete without rebuild or parking", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.RecycleMode = RecycleModeRebuild
+		config.ParkingEnabled = true
+
+		group, api := setupInstanceGroupWithFakeAPI(t, config)
+
+		created, err := group.Increase(ctx, 2)
+		require.NoError(t, err)
+		require.Len(t, created, 2)
+
+		deleted, err := group.Delete(ctx, created)
+		require.NoError(t, err)
+		require.Equal(t, created, deleted)
+
+		require.Empty(t, api.Servers())
+		require.Empty(t, api.Volumes())
+	})
+
+	t.Run("parked instances are deleted at the end of the billed hour", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.ParkingEnabled = true
+
+		group, api := setupInstanceGroupWithFakeAPI(t, config)
+
+		created, err := group.Increase(ctx, 2)
+		require.NoError(t, err)
+		require.Len(t, created, 2)
+
+		deleted, err := group.Decrease(ctx, created[:1])
+		require.NoError(t, err)
+		require.Equal(t, created[:1], deleted)
+		require.Len(t, api.Servers(), 2)
+
+		// Every server is at the end of its billed hour
+		group.config.ParkingMargin = time.Hour
+
+		require.NoError(t, group.Sanity(ctx))
+		require.Len(t, api.Servers(), 1)
+		require.Len(t, api.Volumes(), 1)
+
+		// Instances are not parked at the end of their billed hour
+		deleted, err = group.Decrease(ctx, created[1:])
+		require.NoError(t, err)
+		require.Equal(t, created[1:], deleted)
+
+		require.Empty(t, api.Servers())
+		require.NoError(t, group.Sanity(ctx))
+		require.Empty(t, api.Volumes())
+	})
+
+	t.Run("cache refreshed after increase", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.InstanceCacheTTL = time.Minute
+
+		group, _ := setupInstanceGroupWithFakeAPI(t, config)
+
+		instances, err := group.List(ctx)
+		require.NoError(t, err)
+		require.Empty(t, instances)
+
+		created, err := group.Increase(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, created, 1)
+
+		// The initializing servers of the create responses are not cached
+		instances, err = group.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, instances, 1)
+		assert.Equal(t, hcloud.ServerStatusRunning, instances[0].Server.Status)
+
+		instance, err := group.Get(ctx, created[0])
+		require.NoError(t, err)
+		assert.Equal(t, hcloud.ServerStatusRunning, instance.Server.Status)
+	})
+
+	t.Run("async increase", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.AsyncIncrease = true
+
+		group, api := setupInstanceGroupWithFakeAPI(t, config, testutils.WithActionDuration(50*time.Millisecond))
+
+		created, err := group.Increase(ctx, 2)
+		require.NoError(t, err)
+		require.Len(t, created, 2)
+
+		// The increase returned before the servers are created
+		servers := api.Servers()
+		require.Len(t, servers, 2)
+		for _, server := range servers {
+			assert.Equal(t, "initializing", server.Status)
+		}
+
+		require.NoError(t, group.Wait(ctx))
+
+		servers = api.Servers()
+		require.Len(t, servers, 2)
+		for _, server := range servers {
+			assert.Equal(t, "running", server.Status)
+		}
+	})
+
+	t.Run("async increase with failed creation", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.AsyncIncrease = true
+
+		group, api := setupInstanceGroupWithFakeAPI(t, config)
+
+		injector := testutils.NewFaultInjector(0, testutils.Fault{Method: "POST", Path: "/servers", FailAction: true, Times: 1})
+		group.client = injector.Client(api.URL())
+
+		// Block the cleanup of the failed instance
+		api.SetActionsStuck("delete_server", true)
+
+		created, err := group.Increase(ctx, 2)
+		require.NoError(t, err)
+		require.Len(t, created, 2)
+
+		// The instance being cleaned up is reported as deleting
+		require.Eventually(t, func() bool {
+			return api.Servers()[0].Status == "deleting"
+		}, time.Second, 10*time.Millisecond)
+
+		instances, err := group.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, instances, 2)
+		assert.Equal(t, created[0], instances[0].IID())
+		assert.True(t, instances[0].Deleting)
+		assert.False(t, instances[1].Deleting)
+
+		api.SetActionsStuck("delete_server", false)
+		require.NoError(t, group.Wait(ctx))
+
+		// The failed instance was cleaned up in the background
+		servers := api.Servers()
+		require.Len(t, servers, 1)
+		assert.Equal(t, created[1], fmt.Sprintf("%s:%d", servers[0].Name, servers[0].ID))
+		require.Len(t, api.Volumes(), 1)
+
+		instances, err = group.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, instances, 1)
+		assert.False(t, instances[0].Deleting)
+	})
+
+	t.Run("hooks", func(t *testing.T) {
+		ctx := context.Background()
+		path := filepath.Join(t.TempDir(), "hooks.jsonl")
+
+		hook := &Hook{Command: []string{"sh", "-c", `cat >> "$0" && echo >> "$0"`, path}}
+
+		config := DefaultTestConfig
+		config.Hooks = Hooks{PreCreate: hook, PostCreate: hook, PreDelete: hook, PostDelete: hook}
+
+		group, _ := setupInstanceGroupWithFakeAPI(t, config)
+
+		created, err := group.Increase(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, created, 1)
+
+		deleted, err := group.Decrease(ctx, created)
+		require.NoError(t, err)
+		require.Len(t, deleted, 1)
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+
+		payloads := make([]HookPayload, 0)
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			var payload HookPayload
+			require.NoError(t, json.Unmarshal([]byte(line), &payload))
+			payloads = append(payloads, payload)
+		}
+
+		require.Len(t, payloads, 4)
+		assert.Equal(t, "pre_create", payloads[0].Hook)
+		assert.Empty(t, payloads[0].IID)
+		for i, phase := range []string{"post_create", "pre_delete", "post_delete"} {
+			payload := payloads[i+1]
+			assert.Equal(t, phase, payload.Hook)
+			assert.Equal(t, created[0], payload.IID)
+			assert.NotEmpty(t, payload.PublicIPv6)
+			assert.Equal(t, "fleeting", payload.Labels["instance-group"])
+		}
+	})
+
+	t.Run("hook failure fails the instance", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.Hooks.PostCreate = &Hook{Command: []string{"false"}}
+
+		group, api := setupInstanceGroupWithFakeAPI(t, config)
+
+		created, err := group.Increase(ctx, 1)
+		require.EqualError(t, err, "instance fleeting-a: post_create hook failed: exit status 1")
+		require.Empty(t, created)
+
+		instanceErrs := InstanceErrors(err)
+		require.Len(t, instanceErrs, 1)
+		assert.Equal(t, "CreateHookHandler", instanceErrs[0].Handler)
+
+		// The server of the failed instance was deleted
+		require.Empty(t, api.Servers())
+		require.Empty(t, api.Volumes())
+	})
+
+	t.Run("server creation timeout fails the instance", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.ServerCreateTimeout = 100 * time.Millisecond
+
+		group, api := setupInstanceGroupWithFakeAPI(t, config)
+		api.SetActionsStuck("create_server", true)
+
+		created, err := group.Increase(ctx, 1)
+		require.EqualError(t, err, "instance fleeting-a: could not create instance: server creation timed out after 100ms")
+		require.Empty(t, created)
+
+		instanceErrs := InstanceErrors(err)
+		require.Len(t, instanceErrs, 1)
+		assert.Equal(t, "ServerHandler", instanceErrs[0].Handler)
+		assert.Equal(t, CauseTransient, instanceErrs[0].Cause)
+
+		// The resources of the failed instance were deleted
+		require.Empty(t, api.Servers())
+		require.Empty(t, api.Volumes())
+	})
+
+	t.Run("volume creation timeout fails the instance", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.VolumeCreateTimeout = 100 * time.Millisecond
+
+		group, api := setupInstanceGroupWithFakeAPI(t, config)
+		api.SetActionsStuck("create_volume", true)
+
+		created, err := group.Increase(ctx, 1)
+		require.EqualError(t, err, "instance fleeting-a: could not create volume: volume creation timed out after 100ms")
+		require.Empty(t, created)
+
+		require.Empty(t, api.Servers())
+		require.Empty(t, api.Volumes())
+	})
+
+	t.Run("server deletion timeout fails the instance deletion", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.ServerDeleteTimeout = 100 * time.Millisecond
+
+		group, api := setupInstanceGroupWithFakeAPI(t, config)
+
+		created, err := group.Increase(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, created, 1)
+
+		api.SetActionsStuck("delete_server", true)
+
+		deleted, err := group.Decrease(ctx, created)
+		require.EqualError(t, err, "instance fleeting-a: could not delete instance: server deletion timed out after 100ms")
+		require.Empty(t, deleted)
+	})
+
+	t.Run("async increase with hook", func(t *testing.T) {
+		ctx := context.Background()
+		path := filepath.Join(t.TempDir(), "payload.json")
+
+		config := DefaultTestConfig
+		config.AsyncIncrease = true
+		config.Hooks.PostCreate = &Hook{Command: []string{"sh", "-c", `cat > "$0"`, path}}
+
+		group, _ := setupInstanceGroupWithFakeAPI(t, config, testutils.WithActionDuration(50*time.Millisecond))
+
+		created, err := group.Increase(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, created, 1)
+
+		// The post create hook runs once the server is created, in the background
+		require.NoFileExists(t, path)
+
+		require.NoError(t, group.Wait(ctx))
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+
+		var payload HookPayload
+		require.NoError(t, json.Unmarshal(data, &payload))
+		assert.Equal(t, created[0], payload.IID)
+	})
+
+	t.Run("event log", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+
+		path := filepath.Join(t.TempDir(), "events.jsonl")
+		events, err := eventlog.Open(path, "fleeting", hclog.NewNullLogger())
+		require.NoError(t, err)
+		config.EventLog = events
+
+		group, api := setupInstanceGroupWithFakeAPI(t, config)
+
+		injector := testutils.NewFaultInjector(0,
+			testutils.Fault{Method: "POST", Path: "/volumes", FailAction: true, Times: 1},
+			testutils.Fault{Method: "POST", Path: "/servers", Status: http.StatusPreconditionFailed, Code: hcloud.ErrorCodeResourceUnavailable, Times: 1},
+		)
+		group.client = injector.Client(api.URL())
+
+		created, err := group.Increase(ctx, 2)
+		require.Error(t, err)
+		require.Len(t, created, 1)
+
+		deleted, err := group.Decrease(ctx, created)
+		require.NoError(t, err)
+		require.Equal(t, created, deleted)
+
+		require.NoError(t, events.Close())
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+
+		records := make([]eventlog.Event, 0)
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			var record eventlog.Event
+			require.NoError(t, json.Unmarshal([]byte(line), &record))
+			assert.Equal(t, "fleeting", record.InstanceGroup)
+			records = append(records, record)
+		}
+
+		types := make([]eventlog.Type, 0, len(records))
+		for _, record := range records {
+			types = append(types, record.Type)
+		}
+		require.Equal(t, []eventlog.Type{
+			eventlog.InstanceRequested,
+			eventlog.InstanceRequested,
+			eventlog.InstanceCreateFailed,
+			eventlog.ServerTypeFallback,
+			eventlog.InstanceCleanup,
+			eventlog.InstanceCreated,
+			eventlog.InstanceDeleted,
+		}, types)
+
+		assert.Equal(t, "VolumeHandler", records[2].Handler)
+		assert.Equal(t, "action_failed", records[2].ErrorCode)
+		assert.Equal(t, "cpx11", records[3].ServerType)
+		assert.Equal(t, string(hcloud.ErrorCodeResourceUnavailable), records[3].ErrorCode)
+		assert.Empty(t, records[4].Error)
+		assert.Equal(t, created[0], records[5].IID)
+		assert.Equal(t, "cx22", records[5].ServerType)
+		assert.NotNil(t, records[5].StartedAt)
+		assert.Equal(t, created[0], records[6].IID)
+	})
+
+	t.Run("tracing", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+
+		recorder := tracetest.NewSpanRecorder()
+		previous := otel.GetTracerProvider()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+		t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+		group, api := setupInstanceGroupWithFakeAPI(t, config)
+
+		injector := testutils.NewFaultInjector(0, testutils.Fault{Method: "POST", Path: "/servers", FailAction: true, Times: 1})
+		group.client = injector.Client(api.URL())
+
+		created, err := group.Increase(ctx, 2)
+		require.Error(t, err)
+		require.Len(t, created, 1)
+
+		spans := make(map[string][]sdktrace.ReadOnlySpan)
+		for _, span := range recorder.Ended() {
+			spans[span.Name()] = append(spans[span.Name()], span)
+		}
+
+		for _, name := range []string{"BaseHandler.Create", "VolumeHandler.Create", "VolumeHandler.wait", "ServerHandler.Create"} {
+			require.Len(t, spans[name], 2, name)
+		}
+		// The cleanup of the failed instance waits for the server delete action
+		require.Len(t, spans["ServerHandler.wait"], 3)
+		require.Len(t, spans["ServerHandler.Cleanup"], 1)
+		require.Len(t, spans["VolumeHandler.Cleanup"], 1)
+
+		attributes := func(span sdktrace.ReadOnlySpan) map[string]string {
+			result := make(map[string]string)
+			for _, attr := range span.Attributes() {
+				result[string(attr.Key)] = attr.Value.Emit()
+			}
+			return result
+		}
+
+		failed := 0
+		for _, span := range spans["ServerHandler.wait"] {
+			attrs := attributes(span)
+			assert.Equal(t, "cpx11", attrs["instance.server_type"])
+
+			if span.Status().Code == codes.Error {
+				failed++
+				assert.NotEmpty(t, attrs["error.type"])
+				assert.NotEqual(t, created[0], attrs["instance.name"]+":"+attrs["instance.id"])
+			}
+		}
+		assert.Equal(t, 1, failed)
+	})
+
+	t.Run("increase with unavailable server type", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+
+		group, api := setupInstanceGroupWithFakeAPI(t, config)
+		api.SetAvailableServerTypes("hel1-dc2", "cx22")
+
+		created, err := group.Increase(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, created, 1)
+
+		servers := api.Servers()
+		require.Len(t, servers, 1)
+		require.Equal(t, "cx22", servers[0].ServerType.Name)
+	})
+
+	t.Run("increase with no available server type", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+
+		group, api := setupInstanceGroupWithFakeAPI(t, config)
+		api.SetAvailableServerTypes("hel1-dc2")
+
+		created, err := group.Increase(ctx, 2)
+		require.Error(t, err)
+		require.Empty(t, created)
+
+		// The volumes of the failed instances are cleaned up.
+		require.Empty(t, api.Servers())
+		require.Empty(t, api.Volumes())
+	})
+
+	t.Run("sanity deletes dangling volumes", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+
+		group, api := setupInstanceGroupWithFakeAPI(t, config)
+
+		created, err := group.Increase(ctx, 2)
+		require.NoError(t, err)
+
+		// Servers of other instance groups are ignored.
+		api.AddServer("other", map[string]string{"instance-group": "other"})
+
+		// Simulate a volume left over by a server deleted outside of the plugin.
+		instance, err := InstanceFromIID(created[0])
+		require.NoError(t, err)
+		server, _, err := group.client.Server.GetByID(ctx, instance.ID)
+		require.NoError(t, err)
+		result, _, err := group.client.Server.DeleteWithResult(ctx, server)
+		require.NoError(t, err)
+		require.NoError(t, group.client.Action.WaitFor(ctx, result.Action))
+
+		require.Len(t, api.Volumes(), 2)
+
+		require.NoError(t, group.Sanity(ctx))
+
+		volumes := api.Volumes()
+		require.Len(t, volumes, 1)
+		require.NotNil(t, volumes[0].Server)
+	})
+
+	t.Run("instance groups with the same name and different owners", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.OwnerToken = "runner-1"
+
+		group, api := setupInstanceGroupWithFakeAPI(t, config)
+
+		otherConfig := DefaultTestConfig
+		otherConfig.OwnerToken = "runner-2"
+
+		other := &instanceGroup{name: "fleeting", config: otherConfig, log: group.log, client: api.Client()}
+		other.randomNameFn = makeRandomNameFn("other")
+		require.NoError(t, other.Init(ctx))
+
+		created, err := group.Increase(ctx, 2)
+		require.NoError(t, err)
+		otherCreated, err := other.Increase(ctx, 1)
+		require.NoError(t, err)
+
+		for _, server := range api.Servers() {
+			assert.Contains(t, []string{"runner-1", "runner-2"}, server.Labels["owner"])
+		}
+		for _, volume := range api.Volumes() {
+			assert.Contains(t, []string{"runner-1", "runner-2"}, volume.Labels["owner"])
+		}
+
+		// The instances of the other owner are ignored.
+		instances, err := group.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, instances, 2)
+
+		_, err = group.Get(ctx, otherCreated[0])
+		require.Error(t, err)
+
+		var output bytes.Buffer
+		group.log = hclog.New(&hclog.LoggerOptions{Output: &output})
+
+		require.NoError(t, group.CheckOwners(ctx))
+		assert.Contains(t, output.String(), "[ERROR] found resources of another owner using the same instance group name, they will be ignored: servers=1 volumes=1")
+
+		// Without owner token, the resources of every owner are reported.
+		output.Reset()
+		unowned := &instanceGroup{name: "fleeting", config: DefaultTestConfig, log: group.log, client: api.Client()}
+		require.NoError(t, unowned.CheckOwners(ctx))
+		assert.Contains(t, output.String(), "[ERROR] found resources of an owner using the same instance group name, set the owner_token to ignore them: servers=3 volumes=3")
+
+		// The dangling volumes of the other owner are not deleted.
+		instance, err := InstanceFromIID(otherCreated[0])
+		require.NoError(t, err)
+		server, _, err := other.client.Server.GetByID(ctx, instance.ID)
+		require.NoError(t, err)
+		result, _, err := other.client.Server.DeleteWithResult(ctx, server)
+		require.NoError(t, err)
+		require.NoError(t, other.client.Action.WaitFor(ctx, result.Action))
+
+		require.NoError(t, group.Sanity(ctx))
+		require.Len(t, api.Volumes(), 3)
+
+		require.NoError(t, other.Sanity(ctx))
+		require.Len(t, api.Volumes(), 2)
+
+		deleted, err := group.Decrease(ctx, created)
+		require.NoError(t, err)
+		require.Equal(t, created, deleted)
+		require.Empty(t, api.Servers())
+	})
+
+	t.Run("sanity releases leaked ssh keys", func(t *testing.T) {
+		ctx := context.Background()
+
+		api := testutils.NewFakeAPI(t)
+
+		addSSHKey := func(name string, labels map[string]string) schema.SSHKey {
+			_, publicKey, err := sshutil.GenerateKeyPair()
+			require.NoError(t, err)
+			return api.AddSSHKey(name, string(publicKey), labels)
+		}
+
+		current := addSSHKey("fleeting", map[string]string{"managed-by": "fleeting-plugin-hetzner", "used-by-a": "true"})
+		addSSHKey("fleeting-leaked", map[string]string{"managed-by": "fleeting-plugin-hetzner", "used-by-a": "true"})
+		shared := addSSHKey("shared", map[string]string{"managed-by": "fleeting-plugin-hetzner", "used-by-a": "true", "used-by-b": "true"})
+		admin := addSSHKey("admin", map[string]string{"used-by-a": "true"})
+
+		config := DefaultTestConfig
+		config.SSHKeys = []string{current.Name}
+		config.SSHKeyUserLabel = "used-by-a"
+
+		group := &instanceGroup{name: "fleeting", config: config, log: hclog.New(hclog.DefaultOptions), client: api.Client()}
+		group.randomNameFn = makeRandomNameFn(group.name)
+		require.NoError(t, group.Init(ctx))
+
+		require.NoError(t, group.Sanity(ctx))
+
+		sshKeys := api.SSHKeys()
+		require.Len(t, sshKeys, 3)
+		assert.Equal(t, current.ID, sshKeys[0].ID)
+		assert.Equal(t, shared.ID, sshKeys[1].ID)
+		assert.Equal(t, map[string]string{"managed-by": "fleeting-plugin-hetzner", "used-by-b": "true"}, sshKeys[1].Labels)
+		assert.Equal(t, admin.ID, sshKeys[2].ID)
+	})
+
+	t.Run("concurrent increase", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+
+		group, api := setupInstanceGroupWithFakeAPI(t, config)
+
+		var lock sync.Mutex
+		nameFn := makeRandomNameFn(group.name)
+		group.randomNameFn = func() string {
+			lock.Lock()
+			defer lock.Unlock()
+			return nameFn()
+		}
+
+		var wg sync.WaitGroup
+		errs := make([]error, 5)
+		for i := range errs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				created, err := group.Increase(ctx, 2)
+				if err == nil && len(created) != 2 {
+					err = fmt.Errorf("unexpected created instances: %v", created)
+				}
+				errs[i] = err
+			}()
+		}
+		wg.Wait()
+
+		for _, err := range errs {
+			require.NoError(t, err)
+		}
+		require.Len(t, api.Servers(), 10)
+		require.Len(t, api.Volumes(), 10)
+	})
+}
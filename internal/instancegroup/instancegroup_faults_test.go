@@ -0,0 +1,123 @@
+package instancegroup
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+
+	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/testutils"
+)
+
+// randomFaults returns random faults for the given requests.
+func randomFaults(r *rand.Rand, requests [][2]string) []testutils.Fault {
+	kinds := []testutils.Fault{
+		{Status: http.StatusPreconditionFailed, Code: hcloud.ErrorCodeResourceUnavailable},
+		{Status: http.StatusUnprocessableEntity, Code: hcloud.ErrorCodeInvalidInput},
+		{Status: http.StatusTooManyRequests, Code: hcloud.ErrorCodeRateLimitExceeded},
+		{Status: http.StatusInternalServerError, Code: hcloud.ErrorCodeServiceError},
+		{Status: http.StatusBadGateway, Code: hcloud.ErrorCodeServiceError},
+		{Status: http.StatusServiceUnavailable, Code: hcloud.ErrorCodeServiceError},
+		{FailAction: true},
+		{Delay: time.Millisecond},
+	}
+
+	faults := make([]testutils.Fault, 0, len(requests))
+	for _, request := range requests {
+		fault := kinds[r.IntN(len(kinds))]
+		if fault.FailAction && request[1] == "/actions" {
+			continue
+		}
+		fault.Method = request[0]
+		fault.Path = request[1]
+		fault.Probability = 0.1 + r.Float64()*0.5
+		faults = append(faults, fault)
+	}
+	return faults
+}
+
+func TestFaultInjection(t *testing.T) {
+	createRequests := [][2]string{
+		{"POST", "/servers"},
+		{"POST", "/volumes"},
+		{"GET", "/actions"},
+	}
+	deleteRequests := [][2]string{
+		{"GET", "/volumes"},
+		{"DELETE", "/servers/*"},
+		{"DELETE", "/volumes/*"},
+		{"GET", "/actions"},
+	}
+
+	for seed := range uint64(30) {
+		t.Run(fmt.Sprintf("seed %d", seed), func(t *testing.T) {
+			ctx := context.Background()
+			config := DefaultTestConfig
+
+			group, api := setupInstanceGroupWithFakeAPI(t, config)
+
+			r := rand.New(rand.NewPCG(seed, seed))
+			injector := testutils.NewFaultInjector(seed)
+			group.client = injector.Client(api.URL())
+
+			t.Cleanup(func() {
+				if t.Failed() {
+					t.Logf("injected faults:\n%v", injector.Injected())
+				}
+			})
+
+			// Increase
+			injector.SetFaults(randomFaults(r, createRequests)...)
+
+			created, _ := group.Increase(ctx, 4)
+
+			injector.SetFaults()
+			require.NoError(t, group.Sanity(ctx))
+			require.Equal(t, 0, api.RunningActions())
+
+			// Only the created instances must remain, with their volumes
+			serverIDs := make([]int64, 0, len(created))
+			for _, iid := range created {
+				instance, err := InstanceFromIID(iid)
+				require.NoError(t, err)
+				serverIDs = append(serverIDs, instance.ID)
+			}
+			for _, server := range api.Servers() {
+				require.Contains(t, serverIDs, server.ID, "leaked server %s", server.Name)
+			}
+			require.Len(t, api.Servers(), len(created))
+			for _, volume := range api.Volumes() {
+				require.NotNil(t, volume.Server, "leaked volume %s", volume.Name)
+				require.True(t, slices.Contains(serverIDs, *volume.Server), "leaked volume %s", volume.Name)
+			}
+
+			// Decrease
+			injector.SetFaults(randomFaults(r, deleteRequests)...)
+
+			_, _ = group.Decrease(ctx, created)
+
+			// Recover from the failures, like the autoscaler would
+			injector.SetFaults()
+			instances, err := group.List(ctx)
+			require.NoError(t, err)
+			remaining := make([]string, 0, len(instances))
+			for _, instance := range instances {
+				remaining = append(remaining, instance.IID())
+			}
+			_, err = group.Decrease(ctx, remaining)
+			require.NoError(t, err)
+			require.NoError(t, group.Sanity(ctx))
+
+			require.Empty(t, api.Servers())
+			require.Empty(t, api.Volumes())
+			require.Empty(t, api.PrimaryIPs())
+		})
+	}
+}
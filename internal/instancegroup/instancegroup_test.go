@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/go-hclog"
 	"github.com/stretchr/testify/require"
@@ -136,6 +137,25 @@ func TestInit(t *testing.T) {
 				require.EqualError(t, err, "image not found: debian-12")
 			},
 		},
+		{
+			name: "invalid name template",
+			config: Config{
+				Location:     "hel1",
+				ServerTypes:  []string{"cpx11"},
+				Image:        "debian-12",
+				NameTemplate: "{{ .Name",
+			},
+			run: func(t *testing.T, group *instanceGroup, server *mockutil.Server) {
+				server.Expect([]mockutil.Request{
+					testutils.GetLocationHel1Request,
+					testutils.GetServerTypeCPX11Request,
+					testutils.GetImageDebian12Request,
+				})
+
+				err := group.Init(context.Background())
+				require.ErrorContains(t, err, "could not parse name template")
+			},
+		},
 	}
 	for _, testCase := range testCases {
 		t.Run(testCase.name, func(t *testing.T) {
@@ -158,6 +178,7 @@ func TestIncrease(t *testing.T) {
 
 		group := setupInstanceGroup(t, config,
 			[]mockutil.Request{
+				testutils.GetDatacentersRequest,
 				{
 					Method: "POST", Path: "/volumes",
 					Want: func(t *testing.T, r *http.Request) {
@@ -276,6 +297,7 @@ func TestIncrease(t *testing.T) {
 
 		group := setupInstanceGroup(t, config,
 			[]mockutil.Request{
+				testutils.GetDatacentersRequest,
 				{
 					Method: "POST", Path: "/volumes",
 					Status: 201,
@@ -473,6 +495,98 @@ func TestList(t *testing.T) {
 	})
 }
 
+func TestListAndGetWithCache(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.InstanceCacheTTL = time.Minute
+
+		group := setupInstanceGroup(t, config,
+			[]mockutil.Request{
+				{
+					Method: "GET", Path: "/servers?label_selector=instance-group%3Dfleeting&page=1",
+					Status: 200,
+					JSON: schema.ServerListResponse{
+						Servers: []schema.Server{
+							{ID: 1, Name: "fleeting-a"},
+							{ID: 2, Name: "fleeting-b"},
+						},
+					},
+				},
+				{
+					Method: "GET", Path: "/servers/3",
+					Status: 200,
+					JSON: schema.ServerGetResponse{
+						Server: schema.Server{ID: 3, Name: "fleeting-c"},
+					},
+				},
+			},
+		)
+
+		result, err := group.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, result, 2)
+
+		// Served from the cache
+		result, err = group.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, result, 2)
+
+		instance, err := group.Get(ctx, "fleeting-b:2")
+		require.NoError(t, err)
+		require.Equal(t, "fleeting-b", instance.Name)
+
+		// Not cached yet
+		instance, err = group.Get(ctx, "fleeting-c:3")
+		require.NoError(t, err)
+		require.Equal(t, "fleeting-c", instance.Name)
+
+		result, err = group.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, result, 3)
+	})
+
+	t.Run("invalidated on error", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.InstanceCacheTTL = time.Minute
+
+		listRequest := mockutil.Request{
+			Method: "GET", Path: "/servers?label_selector=instance-group%3Dfleeting&page=1",
+			Status: 200,
+			JSON: schema.ServerListResponse{
+				Servers: []schema.Server{
+					{ID: 1, Name: "fleeting-a"},
+				},
+			},
+		}
+
+		group := setupInstanceGroup(t, config,
+			[]mockutil.Request{
+				listRequest,
+				{
+					Method: "GET", Path: "/servers/2",
+					Status: 500,
+					JSON: schema.ErrorResponse{
+						Error: schema.Error{Code: "unknown_error"},
+					},
+				},
+				listRequest,
+			},
+		)
+
+		_, err := group.List(ctx)
+		require.NoError(t, err)
+
+		_, err = group.Get(ctx, "fleeting-b:2")
+		require.Error(t, err)
+
+		result, err := group.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, result, 1)
+	})
+}
+
 func TestGet(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		ctx := context.Background()
@@ -495,6 +609,26 @@ func TestGet(t *testing.T) {
 		require.Equal(t, int64(1), result.ID)
 		require.Equal(t, "fleeting-a", result.Name)
 	})
+
+	t.Run("not found", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+
+		group := setupInstanceGroup(t, config,
+			[]mockutil.Request{
+				{
+					Method: "GET", Path: "/servers/1",
+					Status: 404,
+					JSON: schema.ErrorResponse{
+						Error: schema.Error{Code: "not_found"},
+					},
+				},
+			},
+		)
+
+		_, err := group.Get(ctx, "fleeting-a:1")
+		require.EqualError(t, err, "instance not found: fleeting-a:1")
+	})
 }
 
 func TestSanity(t *testing.T) {
@@ -0,0 +1,128 @@
+package instancegroup
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"text/template"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/exp/kit/randutil"
+)
+
+// DefaultNameTemplate is the template used to name the instances when no template is
+// configured.
+const DefaultNameTemplate = "{{ .Name }}-{{ .ID }}"
+
+// maxNameAttempts is the maximum number of names tried when creating resources named
+// after an instance, before giving up on name collisions.
+const maxNameAttempts = 3
+
+// maxNameLength is the maximum length of a hostname label (RFC 1123).
+const maxNameLength = 63
+
+// NameTemplateData holds the values available in the instance name template.
+type NameTemplateData struct {
+	// Name of the instance group.
+	Name string
+	// Location name of the instance group.
+	Location string
+	// Sequence is incremented for every generated name, starting at 1.
+	Sequence int64
+	// ID is a random hex encoded string of 8 chars.
+	ID string
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9-]+`)
+
+// sanitizeName turns a string into a valid hostname label, by replacing invalid
+// characters with dashes and truncating it to the maximum hostname label length.
+func sanitizeName(value string) string {
+	value = invalidNameChars.ReplaceAllString(value, "-")
+	if len(value) > maxNameLength {
+		value = value[:maxNameLength]
+	}
+	return strings.Trim(value, "-")
+}
+
+// newNameFn returns a function generating instance names using the given template.
+// The template is executed once to detect errors early. The template must use the
+// random ID, as the sequence restarts at 1 with the plugin and would produce names
+// already used by the existing instances. The names are never truncated, as the
+// truncation could cut the random ID.
+func newNameFn(text string, data NameTemplateData) (func() string, error) {
+	tmpl, err := template.New("name").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse name template: %w", err)
+	}
+
+	execute := func(data NameTemplateData) (string, error) {
+		var builder strings.Builder
+		if err := tmpl.Execute(&builder, data); err != nil {
+			return "", fmt.Errorf("could not execute name template: %w", err)
+		}
+
+		name := strings.Trim(invalidNameChars.ReplaceAllString(builder.String(), "-"), "-")
+		if name == "" {
+			return "", fmt.Errorf("name template produced an empty name")
+		}
+		if len(name) > maxNameLength {
+			return "", fmt.Errorf("name template produced a name longer than %d characters: %s", maxNameLength, name)
+		}
+
+		return name, nil
+	}
+
+	// Use the largest sequence, so the names never exceed the maximum length.
+	data.Sequence = math.MaxInt64
+	data.ID = "00000000"
+	first, err := execute(data)
+	if err != nil {
+		return nil, err
+	}
+	data.ID = "ffffffff"
+	second, err := execute(data)
+	if err != nil {
+		return nil, err
+	}
+	if first == second {
+		return nil, fmt.Errorf("name template must use the .ID value to produce unique names")
+	}
+
+	var sequence atomic.Int64
+
+	return func() string {
+		data := data
+		data.Sequence = sequence.Add(1)
+		data.ID = randutil.GenerateID()
+
+		name, err := execute(data)
+		if err != nil {
+			// Should never happen, the template was successfully executed with the same
+			// data types.
+			prefix := sanitizeName(data.Name)
+			if len(prefix) > maxNameLength-len(data.ID)-1 {
+				prefix = prefix[:maxNameLength-len(data.ID)-1]
+			}
+			return prefix + "-" + data.ID
+		}
+		return name
+	}, nil
+}
+
+// renameInstance gives a new name to an instance, for example after a name collision.
+func (g *instanceGroup) renameInstance(instance *Instance) {
+	name := g.randomNameFn()
+
+	g.log.Warn("instance name already used, retrying with a new name", "name", instance.Name, "new_name", name)
+
+	if password, ok := g.passwords.LoadAndDelete(instance.Name); ok {
+		g.passwords.Store(name, password)
+	}
+
+	instance.Name = name
+	if instance.opts != nil {
+		instance.opts.Name = name
+	}
+}
@@ -0,0 +1,75 @@
+package instancegroup
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitizeName(t *testing.T) {
+	testCases := []struct {
+		value string
+		want  string
+	}{
+		{value: "fleeting-a", want: "fleeting-a"},
+		{value: "ci_fsn1.cpx31", want: "ci-fsn1-cpx31"},
+		{value: "-ci:a-", want: "ci-a"},
+		{value: strings.Repeat("a", 70), want: strings.Repeat("a", 63)},
+		{value: "", want: ""},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.value, func(t *testing.T) {
+			assert.Equal(t, testCase.want, sanitizeName(testCase.value))
+		})
+	}
+}
+
+func TestNewNameFn(t *testing.T) {
+	data := NameTemplateData{Name: "ci", Location: "fsn1"}
+
+	t.Run("default", func(t *testing.T) {
+		fn, err := newNameFn(DefaultNameTemplate, data)
+		require.NoError(t, err)
+
+		assert.Regexp(t, `^ci-[0-9a-f]{8}$`, fn())
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		fn, err := newNameFn("{{ .Name }}-{{ .Location }}-{{ .Sequence }}-{{ slice .ID 0 4 }}", data)
+		require.NoError(t, err)
+
+		assert.Regexp(t, `^ci-fsn1-1-[0-9a-f]{4}$`, fn())
+		assert.Regexp(t, `^ci-fsn1-2-[0-9a-f]{4}$`, fn())
+	})
+
+	t.Run("invalid syntax", func(t *testing.T) {
+		_, err := newNameFn("{{ .Name", data)
+		require.ErrorContains(t, err, "could not parse name template")
+	})
+
+	t.Run("invalid field", func(t *testing.T) {
+		_, err := newNameFn("{{ .Unknown }}", data)
+		require.ErrorContains(t, err, "could not execute name template")
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		_, err := newNameFn("{{ if false }}{{ end }}", data)
+		require.EqualError(t, err, "name template produced an empty name")
+	})
+
+	t.Run("without id", func(t *testing.T) {
+		_, err := newNameFn("{{ .Name }}-{{ .Sequence }}", data)
+		require.EqualError(t, err, "name template must use the .ID value to produce unique names")
+	})
+
+	t.Run("too long", func(t *testing.T) {
+		_, err := newNameFn(strings.Repeat("a", maxNameLength-9)+"-{{ .ID }}", data)
+		require.NoError(t, err)
+
+		// The sequence may grow up to 19 digits
+		_, err = newNameFn(strings.Repeat("a", maxNameLength-9)+"-{{ .Sequence }}-{{ .ID }}", data)
+		require.ErrorContains(t, err, "name template produced a name longer than 63 characters")
+	})
+}
@@ -0,0 +1,67 @@
+package instancegroup
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// ownerLabel is the label holding the owner token of the resources.
+const ownerLabel = "owner"
+
+// owns returns whether a resource of the instance group belongs to the owner of the
+// instance group. Resources created without owner token are owned by every owner.
+//
+// A warning is logged once for each resource that belongs to another owner.
+func (g *instanceGroup) owns(resource string, id int64, name string, labels map[string]string) bool {
+	if g.config.OwnerToken == "" {
+		return true
+	}
+
+	owner, ok := labels[ownerLabel]
+	if !ok || owner == g.config.OwnerToken {
+		return true
+	}
+
+	if _, warned := g.foreign.LoadOrStore(fmt.Sprintf("%s/%d", resource, id), struct{}{}); !warned {
+		g.log.Warn(
+			fmt.Sprintf("ignoring %s of another owner, is another instance group using the same name?", resource),
+			"name", name, "id", id, "owner", owner,
+		)
+	}
+	return false
+}
+
+// CheckOwners reports the resources of the instance group that belong to another
+// owner. Without owner token, it reports the resources that have an owner, which are
+// managed by the instance group.
+func (g *instanceGroup) CheckOwners(ctx context.Context) error {
+	labelSelector := fmt.Sprintf("instance-group=%s,%s", g.name, ownerLabel)
+	if g.config.OwnerToken != "" {
+		labelSelector += fmt.Sprintf(",%s!=%s", ownerLabel, g.config.OwnerToken)
+	}
+
+	servers, err := g.client.Server.AllWithOpts(ctx, hcloud.ServerListOpts{ListOpts: hcloud.ListOpts{LabelSelector: labelSelector}})
+	if err != nil {
+		return fmt.Errorf("could not list servers: %w", err)
+	}
+	volumes, err := g.client.Volume.AllWithOpts(ctx, hcloud.VolumeListOpts{ListOpts: hcloud.ListOpts{LabelSelector: labelSelector}})
+	if err != nil {
+		return fmt.Errorf("could not list volumes: %w", err)
+	}
+
+	if len(servers) == 0 && len(volumes) == 0 {
+		return nil
+	}
+
+	if g.config.OwnerToken == "" {
+		g.log.Error("found resources of an owner using the same instance group name, set the owner_token to ignore them",
+			"servers", len(servers), "volumes", len(volumes))
+	} else {
+		g.log.Error("found resources of another owner using the same instance group name, they will be ignored",
+			"servers", len(servers), "volumes", len(volumes))
+	}
+
+	return nil
+}
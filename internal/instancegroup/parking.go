@@ -0,0 +1,273 @@
+package instancegroup
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// DefaultParkingMargin is the default billed time left under which a parked server is
+// deleted.
+const DefaultParkingMargin = 5 * time.Minute
+
+// parkedLabel is the label of the parked servers, which are hidden from the instances.
+// Its value is the unix time at which the server was parked.
+const parkedLabel = "parked"
+
+// rebuildWindow is the duration during which the servers removed in the rebuild
+// recycle mode are kept to replace the next instances, before being deleted.
+const rebuildWindow = 2 * time.Minute
+
+// billingPeriod is the period for which the servers are billed.
+const billingPeriod = time.Hour
+
+// billedTimeLeft returns the time left in the current billed hour of a server.
+func billedTimeLeft(created, now time.Time) time.Duration {
+	return billingPeriod - now.Sub(created)%billingPeriod
+}
+
+// parking returns whether the servers of the removed instances are parked, to be
+// reused by the next increase.
+func (g *instanceGroup) parking() bool {
+	return g.config.ParkingEnabled || g.config.RecycleMode == RecycleModeRebuild
+}
+
+// parkingExpired returns whether a parked server must be deleted. With parking enabled,
+// the servers are kept until the end of their billed hour, otherwise they are only
+// kept during the rebuild window.
+func (g *instanceGroup) parkingExpired(server *hcloud.Server, now time.Time) bool {
+	if g.config.ParkingEnabled {
+		return billedTimeLeft(server.Created, now) <= g.parkingMargin()
+	}
+
+	parked, err := strconv.ParseInt(server.Labels[parkedLabel], 10, 64)
+	if err != nil {
+		return true
+	}
+	return now.Sub(time.Unix(parked, 0)) >= rebuildWindow
+}
+
+func (g *instanceGroup) parkingMargin() time.Duration {
+	if g.config.ParkingMargin > 0 {
+		return g.config.ParkingMargin
+	}
+	return DefaultParkingMargin
+}
+
+// park parks the servers of the instances, and returns the parked instances and the
+// instances that must be deleted.
+func (g *instanceGroup) park(ctx context.Context, instances []*Instance) ([]*Instance, []*Instance) {
+	parked := make([]*Instance, 0, len(instances))
+	remaining := make([]*Instance, 0)
+
+	now := time.Now()
+	for _, instance := range instances {
+		server, _, err := g.client.Server.GetByID(ctx, instance.ID)
+		if err != nil || server == nil {
+			remaining = append(remaining, instance)
+			continue
+		}
+
+		if server.Labels[sshKeysLabel] != g.sshKeysHash || g.passwordUnknown(instance) {
+			// The server cannot be rebuilt with the new SSH keys, or reached without
+			// its password.
+			remaining = append(remaining, instance)
+			continue
+		}
+
+		timeLeft := billedTimeLeft(server.Created, now)
+		if g.config.ParkingEnabled && timeLeft <= g.parkingMargin() {
+			remaining = append(remaining, instance)
+			continue
+		}
+
+		labels := maps.Clone(server.Labels)
+		labels[parkedLabel] = strconv.FormatInt(now.Unix(), 10)
+
+		_, _, err = g.client.Server.Update(ctx, server, hcloud.ServerUpdateOpts{Labels: labels})
+		if err != nil {
+			g.log.Warn("could not park instance, deleting it", "name", instance.Name, "id", instance.ID, "error", err)
+			remaining = append(remaining, instance)
+			continue
+		}
+
+		g.log.Info("parking instance", "name", instance.Name, "id", instance.ID, "billed_time_left", timeLeft.Truncate(time.Second))
+		parked = append(parked, instance)
+	}
+
+	g.detachParked(ctx, parked)
+
+	return parked, remaining
+}
+
+// listParked returns the parked servers.
+func (g *instanceGroup) listParked(ctx context.Context) ([]*hcloud.Server, error) {
+	servers, err := g.client.Server.AllWithOpts(ctx,
+		hcloud.ServerListOpts{
+			ListOpts: hcloud.ListOpts{
+				LabelSelector: fmt.Sprintf("instance-group=%s,%s", g.name, parkedLabel),
+			},
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not list parked instances: %w", err)
+	}
+
+	servers = slices.DeleteFunc(servers, func(server *hcloud.Server) bool {
+		return !g.owns("server", server.ID, server.Name, server.Labels)
+	})
+
+	return servers, nil
+}
+
+// reserveParked selects the parked servers matching the filter, up to limit servers if
+// positive, and reserves them so they are neither reused nor deleted concurrently. The
+// servers with the most billed time left are selected first. The reserved servers must
+// be released using [instanceGroup.releaseParked].
+func (g *instanceGroup) reserveParked(ctx context.Context, limit int, filter func(*hcloud.Server) bool) ([]*hcloud.Server, error) {
+	g.parkingMu.Lock()
+	defer g.parkingMu.Unlock()
+
+	servers, err := g.listParked(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	slices.SortFunc(servers, func(a, b *hcloud.Server) int {
+		return cmp.Compare(billedTimeLeft(b.Created, now), billedTimeLeft(a.Created, now))
+	})
+
+	if g.parkingReserved == nil {
+		g.parkingReserved = make(map[int64]struct{})
+	}
+
+	reserved := make([]*hcloud.Server, 0)
+	for _, server := range servers {
+		if limit > 0 && len(reserved) == limit {
+			break
+		}
+		if _, ok := g.parkingReserved[server.ID]; ok || !filter(server) {
+			continue
+		}
+		g.parkingReserved[server.ID] = struct{}{}
+		reserved = append(reserved, server)
+	}
+
+	return reserved, nil
+}
+
+// releaseParked releases the parked servers reserved using [instanceGroup.reserveParked].
+func (g *instanceGroup) releaseParked(servers []*hcloud.Server) {
+	g.parkingMu.Lock()
+	defer g.parkingMu.Unlock()
+
+	for _, server := range servers {
+		delete(g.parkingReserved, server.ID)
+	}
+}
+
+// unpark rebuilds up to delta parked servers, and returns them as new instances.
+func (g *instanceGroup) unpark(ctx context.Context, delta int) []*Instance {
+	// The parked servers are reserved, so other increases and the sanity checks do
+	// not wait for the rebuilds.
+	now := time.Now()
+	servers, err := g.reserveParked(ctx, delta, func(server *hcloud.Server) bool {
+		return server.Status == hcloud.ServerStatusRunning && !g.parkingExpired(server, now)
+	})
+	if err != nil {
+		g.log.Warn("could not reuse parked instances", "error", err)
+		return nil
+	}
+	defer g.releaseParked(servers)
+
+	handler := &RebuildHandler{}
+
+	instances := make([]*Instance, 0, delta)
+	failed := make([]*Instance, 0)
+	for _, server := range servers {
+		instance := g.instanceFromServer(server)
+		if err := handler.Rebuild(ctx, g, instance); err != nil {
+			g.log.Warn("could not reuse parked instance", "name", instance.Name, "id", instance.ID, "error", err)
+			if errors.Is(err, errSSHKeysChanged) || errors.Is(err, errPasswordUnknown) {
+				// Delete the server, to replace it with a server that can be reached.
+				failed = append(failed, instance)
+			}
+			// Otherwise, the server is deleted once it expires.
+			continue
+		}
+		instances = append(instances, instance)
+	}
+
+	// Wait for each instance background tasks to complete
+	succeeded := make([]*Instance, 0, len(instances))
+	for _, instance := range instances {
+		if err := g.wait(ctx, handler, instance); err != nil {
+			g.log.Warn("could not reuse parked instance, deleting it", "name", instance.Name, "id", instance.ID, "error", err)
+			failed = append(failed, instance)
+		} else {
+			succeeded = append(succeeded, instance)
+		}
+	}
+
+	if len(failed) > 0 {
+		if _, err := g.delete(ctx, failed); err != nil {
+			g.log.Error("could not delete parked instances", "error", err)
+		}
+	}
+
+	// The reused instances are renamed
+	g.renameRecords(ctx, succeeded)
+	g.attachUnparked(ctx, succeeded)
+
+	return succeeded
+}
+
+// ExpireParked deletes the expired parked servers, without running the other sanity
+// checks.
+func (g *instanceGroup) ExpireParked(ctx context.Context) error {
+	return (&ParkingHandler{}).Sanity(ctx, g)
+}
+
+// ParkingHandler deletes the expired parked servers.
+type ParkingHandler struct{}
+
+var _ SanityHandler = (*ParkingHandler)(nil)
+
+func (h *ParkingHandler) Sanity(ctx context.Context, group *instanceGroup) error {
+	if !group.parking() {
+		return nil
+	}
+
+	now := time.Now()
+	servers, err := group.reserveParked(ctx, 0, func(server *hcloud.Server) bool {
+		return group.parkingExpired(server, now)
+	})
+	if err != nil {
+		return err
+	}
+	defer group.releaseParked(servers)
+
+	if len(servers) == 0 {
+		return nil
+	}
+
+	expired := make([]*Instance, 0, len(servers))
+	for _, server := range servers {
+		group.log.Info("deleting parked instance", "name", server.Name, "id", server.ID)
+		expired = append(expired, group.instanceFromServer(server))
+	}
+
+	if _, err := group.delete(ctx, expired); err != nil {
+		return fmt.Errorf("could not delete parked instances: %w", err)
+	}
+
+	return nil
+}
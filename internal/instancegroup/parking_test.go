@@ -0,0 +1,252 @@
+package instancegroup
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+
+	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/testutils"
+)
+
+func TestBilledTimeLeft(t *testing.T) {
+	created := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, time.Hour, billedTimeLeft(created, created))
+	assert.Equal(t, 55*time.Minute, billedTimeLeft(created, created.Add(5*time.Minute)))
+	assert.Equal(t, time.Minute, billedTimeLeft(created, created.Add(59*time.Minute)))
+	assert.Equal(t, 50*time.Minute, billedTimeLeft(created, created.Add(3*time.Hour+10*time.Minute)))
+}
+
+func TestParkingWithFakeAPI(t *testing.T) {
+	t.Run("park", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.ParkingEnabled = true
+
+		group, api := setupInstanceGroupWithFakeAPI(t, config)
+
+		created, err := group.Increase(ctx, 3)
+		require.NoError(t, err)
+		require.Len(t, created, 3)
+
+		// The last server is at the end of its billed hour
+		servers := api.Servers()
+		api.SetServerCreated(servers[2].ID, time.Now().Add(-58*time.Minute))
+
+		deleted, err := group.Decrease(ctx, created[1:])
+		require.NoError(t, err)
+		require.Equal(t, created[1:], deleted)
+
+		// The parked server and its volume are kept, the other server is deleted
+		servers = api.Servers()
+		require.Len(t, servers, 2)
+		assert.NotContains(t, servers[0].Labels, parkedLabel)
+		assert.Contains(t, servers[1].Labels, parkedLabel)
+		assert.Equal(t, fmt.Sprintf("%s:%d", servers[1].Name, servers[1].ID), created[1])
+		require.Len(t, api.Volumes(), 2)
+
+		// The parked server is hidden from the instances
+		instances, err := group.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, instances, 1)
+		assert.Equal(t, created[0], instances[0].IID())
+	})
+
+	t.Run("unpark reuses the servers with the most billed time left", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.ParkingEnabled = true
+
+		group, api := setupInstanceGroupWithFakeAPI(t, config)
+
+		created, err := group.Increase(ctx, 3)
+		require.NoError(t, err)
+		require.Len(t, created, 3)
+
+		deleted, err := group.Decrease(ctx, created)
+		require.NoError(t, err)
+		require.Len(t, deleted, 3)
+
+		servers := api.Servers()
+		require.Len(t, servers, 3)
+		now := time.Now()
+		api.SetServerCreated(servers[0].ID, now.Add(-40*time.Minute))
+		api.SetServerCreated(servers[1].ID, now.Add(-10*time.Minute))
+		api.SetServerCreated(servers[2].ID, now.Add(-58*time.Minute))
+
+		reused, err := group.Increase(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, reused, 1)
+		instance, err := InstanceFromIID(reused[0])
+		require.NoError(t, err)
+		assert.Equal(t, servers[1].ID, instance.ID)
+
+		reused, err = group.Increase(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, reused, 1)
+		instance, err = InstanceFromIID(reused[0])
+		require.NoError(t, err)
+		assert.Equal(t, servers[0].ID, instance.ID)
+
+		// The server at the end of its billed hour is not reused
+		created, err = group.Increase(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, created, 1)
+		instance, err = InstanceFromIID(created[0])
+		require.NoError(t, err)
+		assert.NotContains(t, []int64{servers[0].ID, servers[1].ID, servers[2].ID}, instance.ID)
+
+		require.Len(t, api.Servers(), 4)
+	})
+
+	t.Run("unpark renames the reused servers", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.ParkingEnabled = true
+
+		group, api := setupInstanceGroupWithFakeAPI(t, config)
+
+		created, err := group.Increase(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, created, 1)
+
+		deleted, err := group.Decrease(ctx, created)
+		require.NoError(t, err)
+		require.Len(t, deleted, 1)
+
+		before := api.Servers()
+		require.Len(t, before, 1)
+
+		reused, err := group.Increase(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, reused, 1)
+
+		servers := api.Servers()
+		require.Len(t, servers, 1)
+		assert.Equal(t, before[0].ID, servers[0].ID)
+		assert.Equal(t, "fleeting-b", servers[0].Name)
+		assert.Equal(t, "running", servers[0].Status)
+		assert.NotContains(t, servers[0].Labels, parkedLabel)
+		assert.Equal(t, fmt.Sprintf("fleeting-b:%d", servers[0].ID), reused[0])
+		assert.NotEqual(t, created[0], reused[0])
+
+		volumes := api.Volumes()
+		require.Len(t, volumes, 1)
+		assert.Equal(t, "fleeting-b", volumes[0].Name)
+
+		instances, err := group.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, instances, 1)
+		assert.Equal(t, reused[0], instances[0].IID())
+	})
+
+	t.Run("unpark deletes the servers that failed to be rebuilt", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.ParkingEnabled = true
+
+		group, api := setupInstanceGroupWithFakeAPI(t, config)
+
+		created, err := group.Increase(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, created, 1)
+
+		deleted, err := group.Decrease(ctx, created)
+		require.NoError(t, err)
+		require.Len(t, deleted, 1)
+
+		parked := api.Servers()
+		require.Len(t, parked, 1)
+
+		injector := testutils.NewFaultInjector(0, testutils.Fault{Method: "POST", Path: "/servers/*/actions/rebuild", FailAction: true, Times: 1})
+		group.client = injector.Client(api.URL())
+
+		// A new server is created instead
+		created, err = group.Increase(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, created, 1)
+		require.Len(t, injector.Injected(), 1)
+
+		servers := api.Servers()
+		require.Len(t, servers, 1)
+		assert.NotEqual(t, parked[0].ID, servers[0].ID)
+		assert.Equal(t, fmt.Sprintf("%s:%d", servers[0].Name, servers[0].ID), created[0])
+		require.Len(t, api.Volumes(), 1)
+	})
+
+	t.Run("sanity deletes the expired parked servers", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.ParkingEnabled = true
+
+		group, api := setupInstanceGroupWithFakeAPI(t, config)
+
+		created, err := group.Increase(ctx, 3)
+		require.NoError(t, err)
+		require.Len(t, created, 3)
+
+		deleted, err := group.Decrease(ctx, created[1:])
+		require.NoError(t, err)
+		require.Len(t, deleted, 2)
+
+		// The first parked server reached the end of its billed hour
+		servers := api.Servers()
+		require.Len(t, servers, 3)
+		api.SetServerCreated(servers[0].ID, time.Now().Add(-58*time.Minute))
+		api.SetServerCreated(servers[1].ID, time.Now().Add(-58*time.Minute))
+
+		require.NoError(t, (&ParkingHandler{}).Sanity(ctx, group))
+
+		// The running instance is kept, even at the end of its billed hour
+		remaining := api.Servers()
+		require.Len(t, remaining, 2)
+		assert.Equal(t, servers[0].ID, remaining[0].ID)
+		assert.Equal(t, servers[2].ID, remaining[1].ID)
+		require.Len(t, api.Volumes(), 2)
+	})
+	t.Run("reserved parked servers are skipped", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.ParkingEnabled = true
+
+		group, api := setupInstanceGroupWithFakeAPI(t, config)
+
+		created, err := group.Increase(ctx, 2)
+		require.NoError(t, err)
+		require.Len(t, created, 2)
+
+		deleted, err := group.Decrease(ctx, created)
+		require.NoError(t, err)
+		require.Len(t, deleted, 2)
+
+		// The first server is being reused
+		reserved, err := group.reserveParked(ctx, 1, func(*hcloud.Server) bool { return true })
+		require.NoError(t, err)
+		require.Len(t, reserved, 1)
+
+		other, err := group.reserveParked(ctx, 0, func(*hcloud.Server) bool { return true })
+		require.NoError(t, err)
+		require.Len(t, other, 1)
+		assert.NotEqual(t, reserved[0].ID, other[0].ID)
+		group.releaseParked(other)
+
+		// Every server is at the end of its billed hour
+		group.config.ParkingMargin = time.Hour
+
+		require.NoError(t, (&ParkingHandler{}).Sanity(ctx, group))
+		servers := api.Servers()
+		require.Len(t, servers, 1)
+		assert.Equal(t, reserved[0].ID, servers[0].ID)
+
+		group.releaseParked(reserved)
+
+		require.NoError(t, (&ParkingHandler{}).Sanity(ctx, group))
+		require.Empty(t, api.Servers())
+	})
+}
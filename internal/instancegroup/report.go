@@ -0,0 +1,110 @@
+package instancegroup
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// Report describes the resources resolved by the instance group during [InstanceGroup.Init],
+// and the potential issues found with the instance group config.
+type Report struct {
+	Location        *hcloud.Location
+	ServerTypes     []*hcloud.ServerType
+	Image           *hcloud.Image
+	PrivateNetworks []*hcloud.Network
+	LoadBalancers   []*hcloud.LoadBalancer
+	SSHKeys         []*hcloud.SSHKey
+	Labels          map[string]string
+
+	// Warnings are issues that will not prevent the instance group from running, but
+	// might prevent instances from being created or reached.
+	Warnings []string
+}
+
+// Report returns a report of the resolved resources, and runs additional checks that
+// only read from the API.
+func (g *instanceGroup) Report(ctx context.Context) (*Report, error) {
+	report := &Report{
+		Location:        g.location,
+		ServerTypes:     g.serverTypes,
+		Image:           g.image,
+		PrivateNetworks: g.privateNetworks,
+		LoadBalancers:   g.loadBalancers,
+		SSHKeys:         g.sshKeys,
+		Labels:          g.labels,
+	}
+
+	warnf := func(format string, a ...any) {
+		report.Warnings = append(report.Warnings, fmt.Sprintf(format, a...))
+	}
+
+	// Deprecations
+	for _, serverType := range g.serverTypes {
+		if serverType.IsDeprecated() {
+			warnf("server type %s is deprecated and will be unavailable after %s",
+				serverType.Name, serverType.UnavailableAfter().Format("2006-01-02"))
+		}
+	}
+	if g.image.IsDeprecated() {
+		warnf("image %s is deprecated since %s", g.imageName(), g.image.Deprecated.Format("2006-01-02"))
+	}
+
+	// Server types availability
+	availability, err := g.serverTypesAvailability(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, serverType := range g.serverTypes {
+		if !availability.available[serverType.ID] {
+			warnf("server type %s is currently not available in location %s", serverType.Name, g.location.Name)
+		}
+	}
+
+	// Private networks zone
+	for _, network := range g.privateNetworks {
+		if !slices.ContainsFunc(network.Subnets, func(subnet hcloud.NetworkSubnet) bool {
+			return subnet.NetworkZone == g.location.NetworkZone
+		}) {
+			warnf("network %s has no subnet in the network zone %s of location %s",
+				network.Name, g.location.NetworkZone, g.location.Name)
+		}
+	}
+
+	// Load balancers zone
+	for _, loadBalancer := range g.loadBalancers {
+		if loadBalancer.Location != nil && loadBalancer.Location.NetworkZone != g.location.NetworkZone {
+			warnf("load balancer %s is in the network zone %s, it cannot target the instances of location %s",
+				loadBalancer.Name, loadBalancer.Location.NetworkZone, g.location.Name)
+		}
+	}
+
+	// Connectivity
+	if g.config.PublicIPv4Disabled && g.config.PublicIPv6Disabled && len(g.privateNetworks) == 0 {
+		warnf("instances have no public ips and no private networks, they will not be reachable")
+	}
+
+	// Public IP pool
+	if g.config.PublicIPPoolEnabled {
+		if err := g.ipPool.Refresh(ctx, g.client); err != nil {
+			return nil, err
+		}
+		if !g.config.PublicIPv4Disabled && g.ipPool.SizeIPv4() == 0 {
+			warnf("public ip pool has no unused ipv4 in location %s", g.location.Name)
+		}
+		if !g.config.PublicIPv6Disabled && g.ipPool.SizeIPv6() == 0 {
+			warnf("public ip pool has no unused ipv6 in location %s", g.location.Name)
+		}
+	}
+
+	return report, nil
+}
+
+func (g *instanceGroup) imageName() string {
+	if g.image.Name != "" {
+		return g.image.Name
+	}
+	return fmt.Sprint(g.image.ID)
+}
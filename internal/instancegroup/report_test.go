@@ -0,0 +1,82 @@
+package instancegroup
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/exp/mockutil"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/schema"
+)
+
+func TestReport(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.PublicIPv4Disabled = false
+
+		group := setupInstanceGroup(t, config, []mockutil.Request{
+			{
+				Method: "GET", Path: "/datacenters?page=1&per_page=50",
+				Status: 200,
+				JSON: schema.DatacenterListResponse{
+					Datacenters: []schema.Datacenter{
+						{
+							ID: 3, Name: "hel1-dc2",
+							Location: schema.Location{ID: 3, Name: "hel1"},
+							ServerTypes: schema.DatacenterServerTypes{
+								Available: []int64{1, 2},
+							},
+						},
+					},
+				},
+			},
+		})
+
+		report, err := group.Report(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "hel1", report.Location.Name)
+		require.Len(t, report.ServerTypes, 2)
+		require.Equal(t, "debian-12", report.Image.Name)
+		require.Empty(t, report.Warnings)
+	})
+
+	t.Run("warnings", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultTestConfig
+		config.PublicIPv6Disabled = true
+
+		group := setupInstanceGroup(t, config, []mockutil.Request{
+			{
+				Method: "GET", Path: "/datacenters?page=1&per_page=50",
+				Status: 200,
+				JSON: schema.DatacenterListResponse{
+					Datacenters: []schema.Datacenter{
+						{
+							ID: 3, Name: "hel1-dc2",
+							Location: schema.Location{ID: 3, Name: "hel1"},
+							ServerTypes: schema.DatacenterServerTypes{
+								Available: []int64{2},
+							},
+						},
+						{
+							ID: 4, Name: "fsn1-dc14",
+							Location: schema.Location{ID: 1, Name: "fsn1"},
+							ServerTypes: schema.DatacenterServerTypes{
+								Available: []int64{1},
+							},
+						},
+					},
+				},
+			},
+		})
+
+		report, err := group.Report(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			"server type cpx11 is currently not available in location hel1",
+			"instances have no public ips and no private networks, they will not be reachable",
+		}, report.Warnings)
+	})
+}
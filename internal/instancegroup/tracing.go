@@ -0,0 +1,73 @@
+package instancegroup
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/tracing"
+)
+
+// tracer returns the tracer of the package from the global tracer provider, which is
+// registered during the init.
+func tracer() trace.Tracer {
+	return otel.Tracer("gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/instancegroup")
+}
+
+// create runs a create handler on an instance in a span.
+func (g *instanceGroup) create(ctx context.Context, handler CreateHandler, instance *Instance) (err error) {
+	ctx, span := startInstanceSpan(ctx, handlerName(handler)+".Create", instance)
+	defer func() { endInstanceSpan(span, instance, err) }()
+
+	return handler.Create(ctx, g, instance)
+}
+
+// cleanupInstance runs a cleanup handler on an instance in a span.
+func (g *instanceGroup) cleanupInstance(ctx context.Context, handler CleanupHandler, instance *Instance) (err error) {
+	ctx, span := startInstanceSpan(ctx, handlerName(handler)+".Cleanup", instance)
+	defer func() { endInstanceSpan(span, instance, err) }()
+
+	return handler.Cleanup(ctx, g, instance)
+}
+
+// wait waits for the instance background tasks started by a handler in a span.
+func (g *instanceGroup) wait(ctx context.Context, handler any, instance *Instance) (err error) {
+	if instance.waitFn == nil {
+		return nil
+	}
+
+	_, span := startInstanceSpan(ctx, handlerName(handler)+".wait", instance)
+	defer func() { endInstanceSpan(span, instance, err) }()
+
+	return instance.wait()
+}
+
+func startInstanceSpan(ctx context.Context, name string, instance *Instance) (context.Context, trace.Span) {
+	return tracer().Start(ctx, name, trace.WithAttributes(instanceAttributes(instance)...))
+}
+
+// endInstanceSpan ends the span of an instance, the instance attributes are updated as
+// the handlers populate the instance.
+func endInstanceSpan(span trace.Span, instance *Instance, err error) {
+	span.SetAttributes(instanceAttributes(instance)...)
+	if err != nil {
+		span.SetAttributes(
+			attribute.String("error.type", string(classifyError(err))),
+			attribute.String("hcloud.error_code", string(errorCode(err))),
+		)
+	}
+	tracing.End(span, err)
+}
+
+func instanceAttributes(instance *Instance) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{attribute.String("instance.name", instance.Name)}
+	if instance.ID != 0 {
+		attrs = append(attrs, attribute.Int64("instance.id", instance.ID))
+	}
+	if serverType := instance.serverType(); serverType != "" {
+		attrs = append(attrs, attribute.String("instance.server_type", serverType))
+	}
+	return attrs
+}
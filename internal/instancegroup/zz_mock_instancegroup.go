@@ -40,6 +40,20 @@ func (m *MockInstanceGroup) EXPECT() *MockInstanceGroupMockRecorder {
 	return m.recorder
 }
 
+// CheckOwners mocks base method.
+func (m *MockInstanceGroup) CheckOwners(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckOwners", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckOwners indicates an expected call of CheckOwners.
+func (mr *MockInstanceGroupMockRecorder) CheckOwners(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckOwners", reflect.TypeOf((*MockInstanceGroup)(nil).CheckOwners), ctx)
+}
+
 // Decrease mocks base method.
 func (m *MockInstanceGroup) Decrease(ctx context.Context, iids []string) ([]string, error) {
 	m.ctrl.T.Helper()
@@ -55,6 +69,35 @@ func (mr *MockInstanceGroupMockRecorder) Decrease(ctx, iids any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Decrease", reflect.TypeOf((*MockInstanceGroup)(nil).Decrease), ctx, iids)
 }
 
+// Delete mocks base method.
+func (m *MockInstanceGroup) Delete(ctx context.Context, iids []string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, iids)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockInstanceGroupMockRecorder) Delete(ctx, iids any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockInstanceGroup)(nil).Delete), ctx, iids)
+}
+
+// ExpireParked mocks base method.
+func (m *MockInstanceGroup) ExpireParked(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpireParked", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExpireParked indicates an expected call of ExpireParked.
+func (mr *MockInstanceGroupMockRecorder) ExpireParked(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireParked", reflect.TypeOf((*MockInstanceGroup)(nil).ExpireParked), ctx)
+}
+
 // Get mocks base method.
 func (m *MockInstanceGroup) Get(ctx context.Context, iid string) (*Instance, error) {
 	m.ctrl.T.Helper()
@@ -114,6 +157,21 @@ func (mr *MockInstanceGroupMockRecorder) List(ctx any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockInstanceGroup)(nil).List), ctx)
 }
 
+// Report mocks base method.
+func (m *MockInstanceGroup) Report(ctx context.Context) (*Report, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Report", ctx)
+	ret0, _ := ret[0].(*Report)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Report indicates an expected call of Report.
+func (mr *MockInstanceGroupMockRecorder) Report(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Report", reflect.TypeOf((*MockInstanceGroup)(nil).Report), ctx)
+}
+
 // Sanity mocks base method.
 func (m *MockInstanceGroup) Sanity(ctx context.Context) error {
 	m.ctrl.T.Helper()
@@ -127,3 +185,17 @@ func (mr *MockInstanceGroupMockRecorder) Sanity(ctx any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Sanity", reflect.TypeOf((*MockInstanceGroup)(nil).Sanity), ctx)
 }
+
+// Wait mocks base method.
+func (m *MockInstanceGroup) Wait(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Wait", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Wait indicates an expected call of Wait.
+func (mr *MockInstanceGroupMockRecorder) Wait(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Wait", reflect.TypeOf((*MockInstanceGroup)(nil).Wait), ctx)
+}
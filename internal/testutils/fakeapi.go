@@ -0,0 +1,1499 @@
+package testutils
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/exp/kit/sshutil"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/schema"
+)
+
+// FakeAPI is a stateful in-memory fake of the Hetzner Cloud API, that models the
+// resources used by the plugin. Unlike [mockutil.Handler], the requests are not
+// scripted, which allows tests to assert the end state of the resources.
+//
+// The fake is seeded with the hel1 and fsn1 locations, the cpx11, cx22 and cax11
+// server types and the debian-12 images. Actions are running when returned by the
+// requests creating them, and finish after the configured action duration.
+type FakeAPI struct {
+	server *httptest.Server
+
+	mu  sync.Mutex
+	now func() time.Time
+
+	actionDuration time.Duration
+
+	lastID int64
+
+	locations     map[int64]*schema.Location
+	datacenters   map[int64]*schema.Datacenter
+	serverTypes   map[int64]*schema.ServerType
+	images        map[int64]*schema.Image
+	networks      map[int64]*schema.Network
+	loadBalancers map[int64]*schema.LoadBalancer
+	sshKeys       map[int64]*schema.SSHKey
+	primaryIPs    map[int64]*schema.PrimaryIP
+	servers       map[int64]*schema.Server
+	volumes       map[int64]*schema.Volume
+	actions       map[int64]*fakeAction
+
+	networkIPs map[int64]netip.Addr
+
+	// shutdownIgnored holds the servers ignoring the ACPI shutdown requests, indexed by
+	// server ID.
+	shutdownIgnored map[int64]bool
+	// stuckCommands holds the commands of the actions that never finish.
+	stuckCommands map[string]bool
+}
+
+type fakeAction struct {
+	action   *schema.Action
+	finishAt time.Time
+	finish   func()
+}
+
+// FakeAPIOption configures a [FakeAPI].
+type FakeAPIOption func(f *FakeAPI)
+
+// WithActionDuration sets the duration after which the actions finish. By default,
+// the actions finish on the next request.
+func WithActionDuration(duration time.Duration) FakeAPIOption {
+	return func(f *FakeAPI) {
+		f.actionDuration = duration
+	}
+}
+
+// NewFakeAPI starts a new [FakeAPI] server, which is closed when the test ends.
+func NewFakeAPI(t testing.TB, opts ...FakeAPIOption) *FakeAPI {
+	t.Helper()
+
+	f := &FakeAPI{
+		now:           time.Now,
+		lastID:        1000,
+		locations:     make(map[int64]*schema.Location),
+		datacenters:   make(map[int64]*schema.Datacenter),
+		serverTypes:   make(map[int64]*schema.ServerType),
+		images:        make(map[int64]*schema.Image),
+		networks:      make(map[int64]*schema.Network),
+		loadBalancers: make(map[int64]*schema.LoadBalancer),
+		sshKeys:       make(map[int64]*schema.SSHKey),
+		primaryIPs:    make(map[int64]*schema.PrimaryIP),
+		servers:       make(map[int64]*schema.Server),
+		volumes:       make(map[int64]*schema.Volume),
+		actions:       make(map[int64]*fakeAction),
+		networkIPs:    make(map[int64]netip.Addr),
+
+		shutdownIgnored: make(map[int64]bool),
+		stuckCommands:   make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	f.seed()
+
+	f.server = httptest.NewServer(f.handler())
+	t.Cleanup(f.server.Close)
+
+	return f
+}
+
+func (f *FakeAPI) seed() {
+	hel1 := &schema.Location{ID: 3, Name: "hel1", Description: "Helsinki DC Park 1", NetworkZone: "eu-central"}
+	fsn1 := &schema.Location{ID: 1, Name: "fsn1", Description: "Falkenstein DC Park 1", NetworkZone: "eu-central"}
+	f.locations[hel1.ID] = hel1
+	f.locations[fsn1.ID] = fsn1
+
+	f.serverTypes[1] = &schema.ServerType{ID: 1, Name: "cpx11", Architecture: "x86", Cores: 2, Memory: 2, Disk: 40}
+	f.serverTypes[2] = &schema.ServerType{ID: 2, Name: "cx22", Architecture: "x86", Cores: 2, Memory: 4, Disk: 40}
+	f.serverTypes[45] = &schema.ServerType{ID: 45, Name: "cax11", Architecture: "arm", Cores: 2, Memory: 4, Disk: 40}
+
+	all := slices.Sorted(maps.Keys(f.serverTypes))
+	f.datacenters[3] = &schema.Datacenter{ID: 3, Name: "hel1-dc2", Location: *hel1,
+		ServerTypes: schema.DatacenterServerTypes{Supported: all, Available: all}}
+	f.datacenters[4] = &schema.Datacenter{ID: 4, Name: "fsn1-dc14", Location: *fsn1,
+		ServerTypes: schema.DatacenterServerTypes{Supported: all, Available: all}}
+
+	f.images[114690387] = &schema.Image{ID: 114690387, Name: hcloud.Ptr("debian-12"), Type: "system", Status: "available",
+		OSFlavor: "debian", OSVersion: hcloud.Ptr("12"), Architecture: "x86"}
+	f.images[114690389] = &schema.Image{ID: 114690389, Name: hcloud.Ptr("debian-12"), Type: "system", Status: "available",
+		OSFlavor: "debian", OSVersion: hcloud.Ptr("12"), Architecture: "arm"}
+}
+
+// URL returns the endpoint of the fake API.
+func (f *FakeAPI) URL() string {
+	return f.server.URL
+}
+
+// Client returns a client configured to use the fake API.
+func (f *FakeAPI) Client() *hcloud.Client {
+	return MakeTestClient(f.URL())
+}
+
+func (f *FakeAPI) nextID() int64 {
+	f.lastID++
+	return f.lastID
+}
+
+// SetAvailableServerTypes sets the server types currently available in a datacenter.
+func (f *FakeAPI) SetAvailableServerTypes(datacenter string, serverTypes ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, dc := range f.datacenters {
+		if dc.Name != datacenter {
+			continue
+		}
+		dc.ServerTypes.Available = []int64{}
+		for _, serverType := range f.serverTypes {
+			if slices.Contains(serverTypes, serverType.Name) {
+				dc.ServerTypes.Available = append(dc.ServerTypes.Available, serverType.ID)
+			}
+		}
+		slices.Sort(dc.ServerTypes.Available)
+	}
+}
+
+// AddNetwork adds a private network with a single subnet in the eu-central network zone.
+func (f *FakeAPI) AddNetwork(name, ipRange string) schema.Network {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	network := &schema.Network{
+		ID: f.nextID(), Name: name, Created: f.now(), IPRange: ipRange,
+		Subnets: []schema.NetworkSubnet{{Type: "cloud", IPRange: ipRange, NetworkZone: "eu-central"}},
+		Servers: []int64{},
+		Labels:  map[string]string{},
+	}
+	f.networks[network.ID] = network
+
+	return *network
+}
+
+// AddLoadBalancer adds a load balancer in the hel1 location, attached to the given
+// networks.
+func (f *FakeAPI) AddLoadBalancer(name string, networks ...int64) schema.LoadBalancer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	loadBalancer := &schema.LoadBalancer{
+		ID: f.nextID(), Name: name, Created: f.now(), Location: *f.locations[3],
+		PrivateNet: []schema.LoadBalancerPrivateNet{},
+		Targets:    []schema.LoadBalancerTarget{},
+		Labels:     map[string]string{},
+	}
+	for _, id := range networks {
+		network := f.networks[id]
+		loadBalancer.PrivateNet = append(loadBalancer.PrivateNet, schema.LoadBalancerPrivateNet{Network: id, IP: f.nextNetworkIP(network)})
+	}
+	f.loadBalancers[loadBalancer.ID] = loadBalancer
+
+	return *loadBalancer
+}
+
+// AddSSHKey adds an SSH key.
+func (f *FakeAPI) AddSSHKey(name, publicKey string, labels map[string]string) schema.SSHKey {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sshKey, err := f.createSSHKey(schema.SSHKeyCreateRequest{Name: name, PublicKey: publicKey, Labels: &labels})
+	if err != nil {
+		panic(err)
+	}
+
+	return *sshKey
+}
+
+// AddPrimaryIP adds an unassigned Primary IP in a datacenter. The ipType is either
+// "ipv4" or "ipv6".
+func (f *FakeAPI) AddPrimaryIP(datacenter, ipType string, labels map[string]string) schema.PrimaryIP {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	primaryIP, err := f.createPrimaryIP(schema.PrimaryIPCreateRequest{
+		Type: ipType, Datacenter: datacenter, AssigneeType: "server", Labels: labels,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return *primaryIP
+}
+
+// AddServer adds a running server, for example to simulate resources not created by
+// the tested code.
+func (f *FakeAPI) AddServer(name string, labels map[string]string) schema.Server {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	server, action, err := f.createServer(schema.ServerCreateRequest{
+		Name:       name,
+		ServerType: schema.IDOrName{Name: "cpx11"},
+		Image:      schema.IDOrName{Name: "debian-12"},
+		Location:   "hel1",
+		Labels:     &labels,
+	})
+	if err != nil {
+		panic(err)
+	}
+	server.Status = "running"
+	action.Status = "success"
+
+	return *server
+}
+
+// SetShutdownIgnored makes the server ignore the ACPI shutdown requests, like a guest
+// without ACPI support.
+func (f *FakeAPI) SetShutdownIgnored(id int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.shutdownIgnored[id] = true
+}
+
+// SetServerCreated sets the creation time of the server, for example to simulate a
+// server at the end of its billed hour.
+func (f *FakeAPI) SetServerCreated(id int64, created time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.servers[id].Created = created
+}
+
+// SetActionsStuck sets whether the actions of the command never finish, like an action
+// stuck in the API, for example "create_server".
+func (f *FakeAPI) SetActionsStuck(command string, stuck bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.stuckCommands[command] = stuck
+}
+
+// Servers returns the servers, sorted by ID.
+func (f *FakeAPI) Servers() []schema.Server {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return values(f.servers)
+}
+
+// Volumes returns the volumes, sorted by ID.
+func (f *FakeAPI) Volumes() []schema.Volume {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return values(f.volumes)
+}
+
+// PrimaryIPs returns the Primary IPs, sorted by ID.
+func (f *FakeAPI) PrimaryIPs() []schema.PrimaryIP {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return values(f.primaryIPs)
+}
+
+// LoadBalancers returns the load balancers, sorted by ID.
+func (f *FakeAPI) LoadBalancers() []schema.LoadBalancer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return values(f.loadBalancers)
+}
+
+// SSHKeys returns the SSH keys, sorted by ID.
+func (f *FakeAPI) SSHKeys() []schema.SSHKey {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return values(f.sshKeys)
+}
+
+// RunningActions returns the number of actions that are not finished.
+func (f *FakeAPI) RunningActions() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.progress()
+
+	count := 0
+	for _, action := range f.actions {
+		if action.action.Status == "running" {
+			count++
+		}
+	}
+	return count
+}
+
+func values[T any](items map[int64]*T) []T {
+	result := make([]T, 0, len(items))
+	for _, id := range slices.Sorted(maps.Keys(items)) {
+		result = append(result, *items[id])
+	}
+	return result
+}
+
+// fakeError is an API error returned by the fake.
+type fakeError struct {
+	status  int
+	code    hcloud.ErrorCode
+	message string
+}
+
+func (e *fakeError) Error() string {
+	return fmt.Sprintf("%s (%s)", e.message, e.code)
+}
+
+func newFakeError(status int, code hcloud.ErrorCode, format string, a ...any) *fakeError {
+	return &fakeError{status: status, code: code, message: fmt.Sprintf(format, a...)}
+}
+
+func notFound(resource string) *fakeError {
+	return newFakeError(http.StatusNotFound, hcloud.ErrorCodeNotFound, "%s not found", resource)
+}
+
+func invalidInput(format string, a ...any) *fakeError {
+	return newFakeError(http.StatusBadRequest, hcloud.ErrorCodeInvalidInput, format, a...)
+}
+
+// newAction registers a running action, the finish function is called once the action
+// finished.
+func (f *FakeAPI) newAction(command string, resources []schema.ActionResourceReference, finish func()) *schema.Action {
+	action := &schema.Action{
+		ID:        f.nextID(),
+		Status:    "running",
+		Command:   command,
+		Started:   f.now(),
+		Resources: resources,
+	}
+	f.actions[action.ID] = &fakeAction{
+		action:   action,
+		finishAt: f.now().Add(f.actionDuration),
+		finish:   finish,
+	}
+	return action
+}
+
+// locked returns whether an action of the resource is running, the resources are
+// locked while their actions are running.
+func (f *FakeAPI) locked(resourceType string, id int64) bool {
+	for _, action := range f.actions {
+		if action.action.Status != "running" {
+			continue
+		}
+		if slices.ContainsFunc(action.action.Resources, func(resource schema.ActionResourceReference) bool {
+			return resource.Type == resourceType && resource.ID == id
+		}) {
+			return true
+		}
+	}
+	return false
+}
+
+// progress finishes the actions that reached their duration.
+func (f *FakeAPI) progress() {
+	now := f.now()
+	for _, id := range slices.Sorted(maps.Keys(f.actions)) {
+		action := f.actions[id]
+		if action.action.Status != "running" || now.Before(action.finishAt) || f.stuckCommands[action.action.Command] {
+			continue
+		}
+
+		action.action.Status = "success"
+		action.action.Progress = 100
+		action.action.Finished = hcloud.Ptr(now)
+		if action.finish != nil {
+			action.finish()
+		}
+	}
+}
+
+func (f *FakeAPI) handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /actions", f.handle(f.listActions))
+	mux.HandleFunc("GET /actions/{id}", f.handle(f.getAction))
+
+	mux.HandleFunc("GET /locations", f.handle(f.listLocations))
+	mux.HandleFunc("GET /locations/{id}", f.handle(f.getLocation))
+	mux.HandleFunc("GET /datacenters", f.handle(f.listDatacenters))
+	mux.HandleFunc("GET /server_types", f.handle(f.listServerTypes))
+	mux.HandleFunc("GET /server_types/{id}", f.handle(f.getServerType))
+	mux.HandleFunc("GET /images", f.handle(f.listImages))
+	mux.HandleFunc("GET /images/{id}", f.handle(f.getImage))
+	mux.HandleFunc("GET /networks", f.handle(f.listNetworks))
+	mux.HandleFunc("GET /networks/{id}", f.handle(f.getNetwork))
+	mux.HandleFunc("GET /load_balancers", f.handle(f.listLoadBalancers))
+	mux.HandleFunc("GET /load_balancers/{id}", f.handle(f.getLoadBalancer))
+	mux.HandleFunc("POST /load_balancers/{id}/actions/add_target", f.handle(f.addLoadBalancerTarget))
+	mux.HandleFunc("POST /load_balancers/{id}/actions/remove_target", f.handle(f.removeLoadBalancerTarget))
+
+	mux.HandleFunc("GET /ssh_keys", f.handle(f.listSSHKeys))
+	mux.HandleFunc("POST /ssh_keys", f.handle(f.postSSHKey))
+	mux.HandleFunc("GET /ssh_keys/{id}", f.handle(f.getSSHKey))
+	mux.HandleFunc("PUT /ssh_keys/{id}", f.handle(f.putSSHKey))
+	mux.HandleFunc("DELETE /ssh_keys/{id}", f.handle(f.deleteSSHKey))
+
+	mux.HandleFunc("GET /primary_ips", f.handle(f.listPrimaryIPs))
+	mux.HandleFunc("POST /primary_ips", f.handle(f.postPrimaryIP))
+	mux.HandleFunc("GET /primary_ips/{id}", f.handle(f.getPrimaryIP))
+	mux.HandleFunc("DELETE /primary_ips/{id}", f.handle(f.deletePrimaryIP))
+
+	mux.HandleFunc("GET /servers", f.handle(f.listServers))
+	mux.HandleFunc("POST /servers", f.handle(f.postServer))
+	mux.HandleFunc("GET /servers/{id}", f.handle(f.getServer))
+	mux.HandleFunc("DELETE /servers/{id}", f.handle(f.deleteServer))
+	mux.HandleFunc("PUT /servers/{id}", f.handle(f.putServer))
+	mux.HandleFunc("POST /servers/{id}/actions/shutdown", f.handle(f.shutdownServer))
+	mux.HandleFunc("POST /servers/{id}/actions/rebuild", f.handle(f.rebuildServer))
+	mux.HandleFunc("POST /servers/{id}/actions/change_protection", f.handle(f.changeServerProtection))
+
+	mux.HandleFunc("GET /volumes", f.handle(f.listVolumes))
+	mux.HandleFunc("POST /volumes", f.handle(f.postVolume))
+	mux.HandleFunc("GET /volumes/{id}", f.handle(f.getVolume))
+	mux.HandleFunc("PUT /volumes/{id}", f.handle(f.putVolume))
+	mux.HandleFunc("DELETE /volumes/{id}", f.handle(f.deleteVolume))
+	mux.HandleFunc("POST /volumes/{id}/actions/change_protection", f.handle(f.changeVolumeProtection))
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, notFound(fmt.Sprintf("route %s %s", r.Method, r.URL.Path)))
+	})
+
+	return mux
+}
+
+// handle serializes the requests, progresses the actions and writes the response of
+// the handler function.
+func (f *FakeAPI) handle(fn func(r *http.Request) (int, any, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		f.progress()
+
+		status, body, err := fn(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if body != nil {
+			_ = json.NewEncoder(w).Encode(body)
+		}
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	apiErr, ok := err.(*fakeError)
+	if !ok {
+		apiErr = newFakeError(http.StatusInternalServerError, hcloud.ErrorCodeUnknownError, "%s", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.status)
+	_ = json.NewEncoder(w).Encode(schema.ErrorResponse{
+		Error: schema.Error{Code: string(apiErr.code), Message: apiErr.message},
+	})
+}
+
+func decodeBody(r *http.Request, dest any) error {
+	if err := json.NewDecoder(r.Body).Decode(dest); err != nil {
+		return newFakeError(http.StatusBadRequest, hcloud.ErrorCodeJSONError, "invalid request body: %s", err)
+	}
+	return nil
+}
+
+func pathID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		return 0, invalidInput("invalid id: %s", r.PathValue("id"))
+	}
+	return id, nil
+}
+
+// get returns the resource matching the path ID.
+func get[T any](r *http.Request, items map[int64]*T, resource string) (*T, error) {
+	id, err := pathID(r)
+	if err != nil {
+		return nil, err
+	}
+	item, ok := items[id]
+	if !ok {
+		return nil, notFound(resource)
+	}
+	return item, nil
+}
+
+// list filters the resources using the name and label_selector query parameters, and
+// returns the requested page of the filtered resources.
+func list[T any](r *http.Request, items map[int64]*T, key string, attrs func(*T) (string, map[string]string)) (int, any, error) {
+	query := r.URL.Query()
+
+	requirements, err := parseLabelSelector(query.Get("label_selector"))
+	if err != nil {
+		return 0, nil, invalidInput("%s", err)
+	}
+
+	filtered := make([]T, 0, len(items))
+	for _, id := range slices.Sorted(maps.Keys(items)) {
+		name, labels := attrs(items[id])
+		if query.Has("name") && query.Get("name") != name {
+			continue
+		}
+		if !matchLabelSelector(requirements, labels) {
+			continue
+		}
+		filtered = append(filtered, *items[id])
+	}
+
+	return paginate(r, key, filtered)
+}
+
+// paginate returns the requested page of the resources.
+func paginate[T any](r *http.Request, key string, items []T) (int, any, error) {
+	query := r.URL.Query()
+
+	page, perPage := 1, 25
+	if value := query.Get("page"); value != "" {
+		page, _ = strconv.Atoi(value)
+	}
+	if value := query.Get("per_page"); value != "" {
+		perPage, _ = strconv.Atoi(value)
+	}
+	if page < 1 || perPage < 1 || perPage > 50 {
+		return 0, nil, invalidInput("invalid pagination: page=%d per_page=%d", page, perPage)
+	}
+
+	lastPage := max(1, (len(items)+perPage-1)/perPage)
+	pagination := &schema.MetaPagination{
+		Page:         page,
+		PerPage:      perPage,
+		LastPage:     lastPage,
+		TotalEntries: len(items),
+	}
+	if page > 1 {
+		pagination.PreviousPage = page - 1
+	}
+	if page < lastPage {
+		pagination.NextPage = page + 1
+	}
+
+	start := min(len(items), (page-1)*perPage)
+	end := min(len(items), start+perPage)
+
+	return http.StatusOK, map[string]any{
+		key:    items[start:end],
+		"meta": schema.Meta{Pagination: pagination},
+	}, nil
+}
+
+func noLabels[T any](name func(*T) string) func(*T) (string, map[string]string) {
+	return func(item *T) (string, map[string]string) { return name(item), nil }
+}
+
+// Actions
+
+func (f *FakeAPI) listActions(r *http.Request) (int, any, error) {
+	query := r.URL.Query()
+
+	actions := make([]schema.Action, 0)
+	for _, id := range slices.Sorted(maps.Keys(f.actions)) {
+		action := f.actions[id].action
+		if query.Has("id") && !slices.Contains(query["id"], strconv.FormatInt(action.ID, 10)) {
+			continue
+		}
+		if query.Has("status") && !slices.Contains(query["status"], action.Status) {
+			continue
+		}
+		actions = append(actions, *action)
+	}
+
+	if slices.Contains(query["sort"], "status") {
+		slices.SortStableFunc(actions, func(a, b schema.Action) int { return cmp.Compare(a.Status, b.Status) })
+	}
+
+	return paginate(r, "actions", actions)
+}
+
+func (f *FakeAPI) getAction(r *http.Request) (int, any, error) {
+	id, err := pathID(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	action, ok := f.actions[id]
+	if !ok {
+		return 0, nil, notFound("action")
+	}
+	return http.StatusOK, schema.ActionGetResponse{Action: *action.action}, nil
+}
+
+// Locations, datacenters, server types, images and networks
+
+func (f *FakeAPI) listLocations(r *http.Request) (int, any, error) {
+	return list(r, f.locations, "locations", noLabels(func(l *schema.Location) string { return l.Name }))
+}
+
+func (f *FakeAPI) getLocation(r *http.Request) (int, any, error) {
+	location, err := get(r, f.locations, "location")
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, schema.LocationGetResponse{Location: *location}, nil
+}
+
+func (f *FakeAPI) listDatacenters(r *http.Request) (int, any, error) {
+	return list(r, f.datacenters, "datacenters", noLabels(func(d *schema.Datacenter) string { return d.Name }))
+}
+
+func (f *FakeAPI) listServerTypes(r *http.Request) (int, any, error) {
+	return list(r, f.serverTypes, "server_types", noLabels(func(s *schema.ServerType) string { return s.Name }))
+}
+
+func (f *FakeAPI) getServerType(r *http.Request) (int, any, error) {
+	serverType, err := get(r, f.serverTypes, "server type")
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, schema.ServerTypeGetResponse{ServerType: *serverType}, nil
+}
+
+func (f *FakeAPI) listImages(r *http.Request) (int, any, error) {
+	query := r.URL.Query()
+
+	images := make(map[int64]*schema.Image, len(f.images))
+	for id, image := range f.images {
+		if query.Has("architecture") && query.Get("architecture") != image.Architecture {
+			continue
+		}
+		if image.Deprecated != nil && query.Get("include_deprecated") != "true" {
+			continue
+		}
+		images[id] = image
+	}
+
+	return list(r, images, "images", func(i *schema.Image) (string, map[string]string) {
+		return valueOf(i.Name), i.Labels
+	})
+}
+
+func (f *FakeAPI) getImage(r *http.Request) (int, any, error) {
+	image, err := get(r, f.images, "image")
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, schema.ImageGetResponse{Image: *image}, nil
+}
+
+func (f *FakeAPI) listNetworks(r *http.Request) (int, any, error) {
+	return list(r, f.networks, "networks", func(n *schema.Network) (string, map[string]string) {
+		return n.Name, n.Labels
+	})
+}
+
+func (f *FakeAPI) getNetwork(r *http.Request) (int, any, error) {
+	network, err := get(r, f.networks, "network")
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, schema.NetworkGetResponse{Network: *network}, nil
+}
+
+// Load balancers
+
+func (f *FakeAPI) listLoadBalancers(r *http.Request) (int, any, error) {
+	return list(r, f.loadBalancers, "load_balancers", func(lb *schema.LoadBalancer) (string, map[string]string) {
+		return lb.Name, lb.Labels
+	})
+}
+
+func (f *FakeAPI) getLoadBalancer(r *http.Request) (int, any, error) {
+	loadBalancer, err := get(r, f.loadBalancers, "load_balancer")
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, schema.LoadBalancerGetResponse{LoadBalancer: *loadBalancer}, nil
+}
+
+func (f *FakeAPI) addLoadBalancerTarget(r *http.Request) (int, any, error) {
+	loadBalancer, err := get(r, f.loadBalancers, "load_balancer")
+	if err != nil {
+		return 0, nil, err
+	}
+	if f.locked("load_balancer", loadBalancer.ID) {
+		return 0, nil, newFakeError(http.StatusLocked, hcloud.ErrorCodeLocked, "load balancer is locked")
+	}
+
+	var req schema.LoadBalancerActionAddTargetRequest
+	if err := decodeBody(r, &req); err != nil {
+		return 0, nil, err
+	}
+	if req.Type != "server" || req.Server == nil {
+		return 0, nil, invalidInput("unsupported target type: %s", req.Type)
+	}
+
+	server, ok := f.servers[req.Server.ID]
+	if !ok {
+		return 0, nil, invalidInput("server not found: %d", req.Server.ID)
+	}
+	if slices.ContainsFunc(loadBalancer.Targets, func(target schema.LoadBalancerTarget) bool {
+		return target.Server != nil && target.Server.ID == server.ID
+	}) {
+		return 0, nil, newFakeError(http.StatusConflict, hcloud.ErrorCodeTargetAlreadyDefined, "target already defined")
+	}
+
+	usePrivateIP := req.UsePrivateIP != nil && *req.UsePrivateIP
+	if usePrivateIP && !slices.ContainsFunc(server.PrivateNet, func(serverNet schema.ServerPrivateNet) bool {
+		return slices.ContainsFunc(loadBalancer.PrivateNet, func(lbNet schema.LoadBalancerPrivateNet) bool {
+			return lbNet.Network == serverNet.Network
+		})
+	}) {
+		return 0, nil, newFakeError(http.StatusUnprocessableEntity, hcloud.ErrorCodeServerNotAttachedToNetwork, "server not attached to a network of the load balancer")
+	}
+
+	loadBalancer.Targets = append(loadBalancer.Targets, schema.LoadBalancerTarget{
+		Type:         "server",
+		Server:       &schema.LoadBalancerTargetServer{ID: server.ID},
+		UsePrivateIP: usePrivateIP,
+	})
+
+	action := f.newAction("add_target", []schema.ActionResourceReference{
+		{ID: loadBalancer.ID, Type: "load_balancer"},
+		{ID: server.ID, Type: "server"},
+	}, nil)
+
+	return http.StatusCreated, schema.LoadBalancerActionAddTargetResponse{Action: *action}, nil
+}
+
+func (f *FakeAPI) removeLoadBalancerTarget(r *http.Request) (int, any, error) {
+	loadBalancer, err := get(r, f.loadBalancers, "load_balancer")
+	if err != nil {
+		return 0, nil, err
+	}
+	if f.locked("load_balancer", loadBalancer.ID) {
+		return 0, nil, newFakeError(http.StatusLocked, hcloud.ErrorCodeLocked, "load balancer is locked")
+	}
+
+	var req schema.LoadBalancerActionRemoveTargetRequest
+	if err := decodeBody(r, &req); err != nil {
+		return 0, nil, err
+	}
+	if req.Type != "server" || req.Server == nil {
+		return 0, nil, invalidInput("unsupported target type: %s", req.Type)
+	}
+
+	index := slices.IndexFunc(loadBalancer.Targets, func(target schema.LoadBalancerTarget) bool {
+		return target.Server != nil && target.Server.ID == req.Server.ID
+	})
+	if index == -1 {
+		return 0, nil, notFound("target")
+	}
+	loadBalancer.Targets = slices.Delete(loadBalancer.Targets, index, index+1)
+
+	action := f.newAction("remove_target", []schema.ActionResourceReference{
+		{ID: loadBalancer.ID, Type: "load_balancer"},
+		{ID: req.Server.ID, Type: "server"},
+	}, nil)
+
+	return http.StatusCreated, schema.LoadBalancerActionRemoveTargetResponse{Action: *action}, nil
+}
+
+// SSH keys
+
+func (f *FakeAPI) listSSHKeys(r *http.Request) (int, any, error) {
+	query := r.URL.Query()
+
+	sshKeys := make(map[int64]*schema.SSHKey, len(f.sshKeys))
+	for id, sshKey := range f.sshKeys {
+		if query.Has("fingerprint") && query.Get("fingerprint") != sshKey.Fingerprint {
+			continue
+		}
+		sshKeys[id] = sshKey
+	}
+
+	return list(r, sshKeys, "ssh_keys", func(k *schema.SSHKey) (string, map[string]string) {
+		return k.Name, k.Labels
+	})
+}
+
+func (f *FakeAPI) postSSHKey(r *http.Request) (int, any, error) {
+	var req schema.SSHKeyCreateRequest
+	if err := decodeBody(r, &req); err != nil {
+		return 0, nil, err
+	}
+
+	sshKey, err := f.createSSHKey(req)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusCreated, schema.SSHKeyCreateResponse{SSHKey: *sshKey}, nil
+}
+
+func (f *FakeAPI) createSSHKey(req schema.SSHKeyCreateRequest) (*schema.SSHKey, error) {
+	fingerprint, err := sshutil.GetPublicKeyFingerprint([]byte(req.PublicKey))
+	if err != nil {
+		return nil, invalidInput("invalid public key: %s", err)
+	}
+
+	for _, sshKey := range f.sshKeys {
+		if sshKey.Name == req.Name {
+			return nil, newFakeError(http.StatusConflict, hcloud.ErrorCodeUniquenessError, "SSH key with the same name already exists")
+		}
+		if sshKey.Fingerprint == fingerprint {
+			return nil, newFakeError(http.StatusConflict, hcloud.ErrorCodeUniquenessError, "SSH key with the same fingerprint already exists")
+		}
+	}
+
+	sshKey := &schema.SSHKey{
+		ID:          f.nextID(),
+		Name:        req.Name,
+		Fingerprint: fingerprint,
+		PublicKey:   req.PublicKey,
+		Labels:      labelsFrom(req.Labels),
+		Created:     f.now(),
+	}
+	f.sshKeys[sshKey.ID] = sshKey
+
+	return sshKey, nil
+}
+
+func (f *FakeAPI) getSSHKey(r *http.Request) (int, any, error) {
+	sshKey, err := get(r, f.sshKeys, "SSH key")
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, schema.SSHKeyGetResponse{SSHKey: *sshKey}, nil
+}
+
+func (f *FakeAPI) putSSHKey(r *http.Request) (int, any, error) {
+	sshKey, err := get(r, f.sshKeys, "SSH key")
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var req schema.SSHKeyUpdateRequest
+	if err := decodeBody(r, &req); err != nil {
+		return 0, nil, err
+	}
+
+	if req.Name != "" && req.Name != sshKey.Name {
+		for _, other := range f.sshKeys {
+			if other.Name == req.Name {
+				return 0, nil, newFakeError(http.StatusConflict, hcloud.ErrorCodeUniquenessError, "SSH key with the same name already exists")
+			}
+		}
+		sshKey.Name = req.Name
+	}
+	if req.Labels != nil {
+		sshKey.Labels = *req.Labels
+	}
+
+	return http.StatusOK, schema.SSHKeyUpdateResponse{SSHKey: *sshKey}, nil
+}
+
+func (f *FakeAPI) deleteSSHKey(r *http.Request) (int, any, error) {
+	sshKey, err := get(r, f.sshKeys, "SSH key")
+	if err != nil {
+		return 0, nil, err
+	}
+	delete(f.sshKeys, sshKey.ID)
+	return http.StatusNoContent, nil, nil
+}
+
+// Primary IPs
+
+func (f *FakeAPI) listPrimaryIPs(r *http.Request) (int, any, error) {
+	return list(r, f.primaryIPs, "primary_ips", func(p *schema.PrimaryIP) (string, map[string]string) {
+		return p.Name, p.Labels
+	})
+}
+
+func (f *FakeAPI) postPrimaryIP(r *http.Request) (int, any, error) {
+	var req schema.PrimaryIPCreateRequest
+	if err := decodeBody(r, &req); err != nil {
+		return 0, nil, err
+	}
+
+	primaryIP, err := f.createPrimaryIP(req)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusCreated, schema.PrimaryIPCreateResponse{PrimaryIP: *primaryIP}, nil
+}
+
+func (f *FakeAPI) createPrimaryIP(req schema.PrimaryIPCreateRequest) (*schema.PrimaryIP, error) {
+	if req.Type != "ipv4" && req.Type != "ipv6" {
+		return nil, invalidInput("invalid primary ip type: %s", req.Type)
+	}
+
+	var datacenter *schema.Datacenter
+	for _, dc := range f.datacenters {
+		if dc.Name == req.Datacenter {
+			datacenter = dc
+		}
+	}
+	if datacenter == nil {
+		return nil, notFound("datacenter")
+	}
+
+	for _, primaryIP := range f.primaryIPs {
+		if req.Name != "" && primaryIP.Name == req.Name {
+			return nil, newFakeError(http.StatusConflict, hcloud.ErrorCodeUniquenessError, "primary ip with the same name already exists")
+		}
+	}
+
+	id := f.nextID()
+	if req.Name == "" {
+		req.Name = fmt.Sprintf("primary_ip-%d", id)
+	}
+
+	primaryIP := &schema.PrimaryIP{
+		ID:           id,
+		Name:         req.Name,
+		Type:         req.Type,
+		Labels:       req.Labels,
+		AssigneeType: "server",
+		AutoDelete:   valueOf(req.AutoDelete),
+		Created:      f.now(),
+		Datacenter:   *datacenter,
+	}
+	if primaryIP.Labels == nil {
+		primaryIP.Labels = map[string]string{}
+	}
+	if req.Type == "ipv4" {
+		primaryIP.IP = fmt.Sprintf("192.0.2.%d", primaryIP.ID%250+1)
+	} else {
+		primaryIP.IP = fmt.Sprintf("2001:db8:%x::/64", primaryIP.ID)
+	}
+	f.primaryIPs[primaryIP.ID] = primaryIP
+
+	return primaryIP, nil
+}
+
+func (f *FakeAPI) getPrimaryIP(r *http.Request) (int, any, error) {
+	primaryIP, err := get(r, f.primaryIPs, "primary ip")
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, schema.PrimaryIPGetResponse{PrimaryIP: *primaryIP}, nil
+}
+
+func (f *FakeAPI) deletePrimaryIP(r *http.Request) (int, any, error) {
+	primaryIP, err := get(r, f.primaryIPs, "primary ip")
+	if err != nil {
+		return 0, nil, err
+	}
+	if primaryIP.AssigneeID != nil {
+		return 0, nil, newFakeError(http.StatusLocked, hcloud.ErrorCodeLocked, "primary ip is assigned to a server")
+	}
+	delete(f.primaryIPs, primaryIP.ID)
+	return http.StatusNoContent, nil, nil
+}
+
+// Servers
+
+func (f *FakeAPI) listServers(r *http.Request) (int, any, error) {
+	return list(r, f.servers, "servers", func(s *schema.Server) (string, map[string]string) {
+		return s.Name, s.Labels
+	})
+}
+
+func (f *FakeAPI) postServer(r *http.Request) (int, any, error) {
+	var req schema.ServerCreateRequest
+	if err := decodeBody(r, &req); err != nil {
+		return 0, nil, err
+	}
+
+	server, action, err := f.createServer(req)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusCreated, schema.ServerCreateResponse{Server: *server, Action: *action, NextActions: []schema.Action{}}, nil
+}
+
+func (f *FakeAPI) createServer(req schema.ServerCreateRequest) (*schema.Server, *schema.Action, error) {
+	if req.Name == "" {
+		return nil, nil, invalidInput("missing server name")
+	}
+	for _, server := range f.servers {
+		if server.Name == req.Name {
+			return nil, nil, newFakeError(http.StatusConflict, hcloud.ErrorCodeUniquenessError, "server name is already used")
+		}
+	}
+
+	// Datacenter
+	var datacenter *schema.Datacenter
+	for _, id := range slices.Sorted(maps.Keys(f.datacenters)) {
+		dc := f.datacenters[id]
+		if (req.Datacenter != "" && matchIDOrName(req.Datacenter, dc.ID, dc.Name)) ||
+			(req.Datacenter == "" && matchIDOrName(req.Location, dc.Location.ID, dc.Location.Name)) {
+			datacenter = dc
+			break
+		}
+	}
+	if datacenter == nil {
+		return nil, nil, invalidInput("datacenter or location not found")
+	}
+
+	// Server type
+	var serverType *schema.ServerType
+	for _, st := range f.serverTypes {
+		if st.ID == req.ServerType.ID || st.Name == req.ServerType.Name {
+			serverType = st
+		}
+	}
+	if serverType == nil {
+		return nil, nil, invalidInput("server type not found")
+	}
+	if !slices.Contains(datacenter.ServerTypes.Available, serverType.ID) {
+		return nil, nil, newFakeError(http.StatusPreconditionFailed, hcloud.ErrorCodeResourceUnavailable,
+			"server type %s is unavailable in %s", serverType.Name, datacenter.Name)
+	}
+
+	// Image
+	var image *schema.Image
+	for _, i := range f.images {
+		if i.ID == req.Image.ID || (valueOf(i.Name) == req.Image.Name && i.Architecture == serverType.Architecture) {
+			image = i
+		}
+	}
+	if image == nil {
+		return nil, nil, invalidInput("image not found")
+	}
+	if image.Architecture != serverType.Architecture {
+		return nil, nil, invalidInput("image architecture does not match the server type architecture")
+	}
+
+	// SSH keys
+	for _, id := range req.SSHKeys {
+		if _, ok := f.sshKeys[id]; !ok {
+			return nil, nil, invalidInput("ssh key not found: %d", id)
+		}
+	}
+
+	// Volumes
+	for _, id := range req.Volumes {
+		volume, ok := f.volumes[id]
+		if !ok {
+			return nil, nil, invalidInput("volume not found: %d", id)
+		}
+		if volume.Server != nil {
+			return nil, nil, newFakeError(http.StatusConflict, hcloud.ErrorCodeVolumeAlreadyAttached, "volume is already attached to a server")
+		}
+		if volume.Location.ID != datacenter.Location.ID {
+			return nil, nil, invalidInput("volume is not in the server location")
+		}
+	}
+
+	// Networks
+	for _, id := range req.Networks {
+		if _, ok := f.networks[id]; !ok {
+			return nil, nil, invalidInput("network not found: %d", id)
+		}
+	}
+
+	// Primary IPs
+	publicNet := req.PublicNet
+	if publicNet == nil {
+		publicNet = &schema.ServerCreatePublicNet{EnableIPv4: true, EnableIPv6: true}
+	}
+	for _, id := range []int64{publicNet.IPv4ID, publicNet.IPv6ID} {
+		if id == 0 {
+			continue
+		}
+		primaryIP, ok := f.primaryIPs[id]
+		if !ok {
+			return nil, nil, invalidInput("primary ip not found: %d", id)
+		}
+		if primaryIP.AssigneeID != nil {
+			return nil, nil, newFakeError(http.StatusConflict, hcloud.ErrorCodeConflict, "primary ip is already assigned")
+		}
+		if primaryIP.Datacenter.ID != datacenter.ID {
+			return nil, nil, invalidInput("primary ip is not in the server datacenter")
+		}
+	}
+
+	server := &schema.Server{
+		ID:              f.nextID(),
+		Name:            req.Name,
+		Status:          "initializing",
+		Created:         f.now(),
+		ServerType:      *serverType,
+		Datacenter:      *datacenter,
+		Image:           image,
+		Labels:          labelsFrom(req.Labels),
+		Volumes:         []int64{},
+		PrivateNet:      []schema.ServerPrivateNet{},
+		PrimaryDiskSize: serverType.Disk,
+		LoadBalancers:   []int64{},
+	}
+
+	assign := func(enabled bool, id int64, ipType string) *schema.PrimaryIP {
+		if !enabled {
+			return nil
+		}
+		if id == 0 {
+			// Errors are not expected, the datacenter exists.
+			primaryIP, _ := f.createPrimaryIP(schema.PrimaryIPCreateRequest{
+				Type: ipType, Datacenter: datacenter.Name, AutoDelete: hcloud.Ptr(true),
+			})
+			id = primaryIP.ID
+		}
+		primaryIP := f.primaryIPs[id]
+		primaryIP.AssigneeID = hcloud.Ptr(server.ID)
+		return primaryIP
+	}
+	if ipv4 := assign(publicNet.EnableIPv4, publicNet.IPv4ID, "ipv4"); ipv4 != nil {
+		server.PublicNet.IPv4 = schema.ServerPublicNetIPv4{ID: ipv4.ID, IP: ipv4.IP}
+	}
+	if ipv6 := assign(publicNet.EnableIPv6, publicNet.IPv6ID, "ipv6"); ipv6 != nil {
+		server.PublicNet.IPv6 = schema.ServerPublicNetIPv6{ID: ipv6.ID, IP: ipv6.IP}
+	}
+
+	for _, id := range req.Volumes {
+		f.volumes[id].Server = hcloud.Ptr(server.ID)
+		server.Volumes = append(server.Volumes, id)
+	}
+
+	for _, id := range req.Networks {
+		network := f.networks[id]
+		network.Servers = append(network.Servers, server.ID)
+		server.PrivateNet = append(server.PrivateNet, schema.ServerPrivateNet{Network: id, IP: f.nextNetworkIP(network)})
+	}
+
+	f.servers[server.ID] = server
+
+	action := f.newAction("create_server", []schema.ActionResourceReference{{ID: server.ID, Type: "server"}}, func() {
+		if server.Status == "initializing" {
+			server.Status = "running"
+		}
+	})
+
+	return server, action, nil
+}
+
+func (f *FakeAPI) nextNetworkIP(network *schema.Network) string {
+	ip, ok := f.networkIPs[network.ID]
+	if !ok {
+		prefix, err := netip.ParsePrefix(network.IPRange)
+		if err != nil {
+			return ""
+		}
+		// The first address is the network gateway.
+		ip = prefix.Addr().Next()
+	}
+	ip = ip.Next()
+	f.networkIPs[network.ID] = ip
+	return ip.String()
+}
+
+func (f *FakeAPI) getServer(r *http.Request) (int, any, error) {
+	server, err := get(r, f.servers, "server")
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, schema.ServerGetResponse{Server: *server}, nil
+}
+
+func (f *FakeAPI) deleteServer(r *http.Request) (int, any, error) {
+	server, err := get(r, f.servers, "server")
+	if err != nil {
+		return 0, nil, err
+	}
+	if server.Protection.Delete {
+		return 0, nil, newFakeError(http.StatusForbidden, hcloud.ErrorCodeProtected, "server is protected")
+	}
+
+	server.Status = "deleting"
+
+	action := f.newAction("delete_server", []schema.ActionResourceReference{{ID: server.ID, Type: "server"}}, func() {
+		delete(f.servers, server.ID)
+
+		for _, volume := range f.volumes {
+			if volume.Server != nil && *volume.Server == server.ID {
+				volume.Server = nil
+			}
+		}
+		for _, primaryIP := range f.primaryIPs {
+			if primaryIP.AssigneeID != nil && *primaryIP.AssigneeID == server.ID {
+				if primaryIP.AutoDelete {
+					delete(f.primaryIPs, primaryIP.ID)
+				} else {
+					primaryIP.AssigneeID = nil
+				}
+			}
+		}
+		for _, network := range f.networks {
+			network.Servers = slices.DeleteFunc(network.Servers, func(id int64) bool { return id == server.ID })
+		}
+		for _, loadBalancer := range f.loadBalancers {
+			loadBalancer.Targets = slices.DeleteFunc(loadBalancer.Targets, func(target schema.LoadBalancerTarget) bool {
+				return target.Server != nil && target.Server.ID == server.ID
+			})
+		}
+	})
+
+	return http.StatusOK, schema.ServerDeleteResponse{Action: *action}, nil
+}
+
+func (f *FakeAPI) putServer(r *http.Request) (int, any, error) {
+	server, err := get(r, f.servers, "server")
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var req schema.ServerUpdateRequest
+	if err := decodeBody(r, &req); err != nil {
+		return 0, nil, err
+	}
+
+	if req.Name != "" && req.Name != server.Name {
+		for _, other := range f.servers {
+			if other.Name == req.Name {
+				return 0, nil, newFakeError(http.StatusConflict, hcloud.ErrorCodeUniquenessError, "server name is already used")
+			}
+		}
+		server.Name = req.Name
+	}
+	if req.Labels != nil {
+		server.Labels = *req.Labels
+	}
+
+	return http.StatusOK, schema.ServerUpdateResponse{Server: *server}, nil
+}
+
+func (f *FakeAPI) rebuildServer(r *http.Request) (int, any, error) {
+	server, err := get(r, f.servers, "server")
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if server.Protection.Rebuild {
+		return 0, nil, newFakeError(http.StatusForbidden, hcloud.ErrorCodeProtected, "server is protected")
+	}
+
+	var req schema.ServerActionRebuildRequest
+	if err := decodeBody(r, &req); err != nil {
+		return 0, nil, err
+	}
+
+	var image *schema.Image
+	for _, i := range f.images {
+		if i.ID == req.Image.ID || (valueOf(i.Name) == req.Image.Name && i.Architecture == server.ServerType.Architecture) {
+			image = i
+		}
+	}
+	if image == nil {
+		return 0, nil, invalidInput("image not found")
+	}
+
+	server.Image = image
+	server.Status = "rebuilding"
+
+	action := f.newAction("rebuild_server", []schema.ActionResourceReference{{ID: server.ID, Type: "server"}}, func() {
+		server.Status = "running"
+	})
+
+	return http.StatusCreated, schema.ServerActionRebuildResponse{Action: *action}, nil
+}
+
+func (f *FakeAPI) shutdownServer(r *http.Request) (int, any, error) {
+	server, err := get(r, f.servers, "server")
+	if err != nil {
+		return 0, nil, err
+	}
+
+	action := f.newAction("shutdown_server", []schema.ActionResourceReference{{ID: server.ID, Type: "server"}}, func() {
+		if !f.shutdownIgnored[server.ID] && server.Status == "running" {
+			server.Status = "off"
+		}
+	})
+
+	return http.StatusCreated, schema.ServerActionShutdownResponse{Action: *action}, nil
+}
+
+func (f *FakeAPI) changeServerProtection(r *http.Request) (int, any, error) {
+	server, err := get(r, f.servers, "server")
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var req schema.ServerActionChangeProtectionRequest
+	if err := decodeBody(r, &req); err != nil {
+		return 0, nil, err
+	}
+
+	if req.Delete != nil {
+		server.Protection.Delete = *req.Delete
+	}
+	if req.Rebuild != nil {
+		server.Protection.Rebuild = *req.Rebuild
+	}
+
+	action := f.newAction("change_protection", []schema.ActionResourceReference{{ID: server.ID, Type: "server"}}, nil)
+
+	return http.StatusCreated, schema.ServerActionChangeProtectionResponse{Action: *action}, nil
+}
+
+// Volumes
+
+func (f *FakeAPI) listVolumes(r *http.Request) (int, any, error) {
+	return list(r, f.volumes, "volumes", func(v *schema.Volume) (string, map[string]string) {
+		return v.Name, v.Labels
+	})
+}
+
+func (f *FakeAPI) postVolume(r *http.Request) (int, any, error) {
+	var req schema.VolumeCreateRequest
+	if err := decodeBody(r, &req); err != nil {
+		return 0, nil, err
+	}
+
+	if req.Name == "" {
+		return 0, nil, invalidInput("missing volume name")
+	}
+	if req.Size < 10 {
+		return 0, nil, invalidInput("invalid volume size: %d", req.Size)
+	}
+	for _, volume := range f.volumes {
+		if volume.Name == req.Name {
+			return 0, nil, newFakeError(http.StatusConflict, hcloud.ErrorCodeUniquenessError, "volume name is already used")
+		}
+	}
+
+	var location *schema.Location
+	if req.Location != nil {
+		for _, l := range f.locations {
+			if l.ID == req.Location.ID || l.Name == req.Location.Name {
+				location = l
+			}
+		}
+	}
+	if location == nil {
+		return 0, nil, invalidInput("location not found")
+	}
+
+	volume := &schema.Volume{
+		ID:       f.nextID(),
+		Name:     req.Name,
+		Status:   "creating",
+		Location: *location,
+		Size:     req.Size,
+		Format:   req.Format,
+		Labels:   labelsFrom(req.Labels),
+		Created:  f.now(),
+	}
+	volume.LinuxDevice = fmt.Sprintf("/dev/disk/by-id/scsi-0HC_Volume_%d", volume.ID)
+	f.volumes[volume.ID] = volume
+
+	action := f.newAction("create_volume", []schema.ActionResourceReference{{ID: volume.ID, Type: "volume"}}, func() {
+		volume.Status = "available"
+	})
+
+	return http.StatusCreated, schema.VolumeCreateResponse{Volume: *volume, Action: action, NextActions: []schema.Action{}}, nil
+}
+
+func (f *FakeAPI) getVolume(r *http.Request) (int, any, error) {
+	volume, err := get(r, f.volumes, "volume")
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, schema.VolumeGetResponse{Volume: *volume}, nil
+}
+
+func (f *FakeAPI) putVolume(r *http.Request) (int, any, error) {
+	volume, err := get(r, f.volumes, "volume")
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var req schema.VolumeUpdateRequest
+	if err := decodeBody(r, &req); err != nil {
+		return 0, nil, err
+	}
+
+	if req.Name != "" && req.Name != volume.Name {
+		for _, other := range f.volumes {
+			if other.Name == req.Name {
+				return 0, nil, newFakeError(http.StatusConflict, hcloud.ErrorCodeUniquenessError, "volume name is already used")
+			}
+		}
+		volume.Name = req.Name
+	}
+	if req.Labels != nil {
+		volume.Labels = *req.Labels
+	}
+
+	return http.StatusOK, schema.VolumeUpdateResponse{Volume: *volume}, nil
+}
+
+func (f *FakeAPI) deleteVolume(r *http.Request) (int, any, error) {
+	volume, err := get(r, f.volumes, "volume")
+	if err != nil {
+		return 0, nil, err
+	}
+	if volume.Protection.Delete {
+		return 0, nil, newFakeError(http.StatusForbidden, hcloud.ErrorCodeProtected, "volume is protected")
+	}
+	if volume.Server != nil {
+		return 0, nil, newFakeError(http.StatusLocked, hcloud.ErrorCodeLocked, "volume is attached to a server")
+	}
+	delete(f.volumes, volume.ID)
+	return http.StatusNoContent, nil, nil
+}
+
+func (f *FakeAPI) changeVolumeProtection(r *http.Request) (int, any, error) {
+	volume, err := get(r, f.volumes, "volume")
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var req schema.VolumeActionChangeProtectionRequest
+	if err := decodeBody(r, &req); err != nil {
+		return 0, nil, err
+	}
+
+	if req.Delete != nil {
+		volume.Protection.Delete = *req.Delete
+	}
+
+	action := f.newAction("change_protection", []schema.ActionResourceReference{{ID: volume.ID, Type: "volume"}}, nil)
+
+	return http.StatusCreated, schema.VolumeActionChangeProtectionResponse{Action: *action}, nil
+}
+
+func labelsFrom(labels *map[string]string) map[string]string {
+	result := map[string]string{}
+	if labels != nil {
+		maps.Copy(result, *labels)
+	}
+	return result
+}
+
+func valueOf[T any](ptr *T) T {
+	var value T
+	if ptr != nil {
+		value = *ptr
+	}
+	return value
+}
+
+// matchIDOrName returns whether the value is either the ID or the name of a resource.
+func matchIDOrName(value string, id int64, name string) bool {
+	return value == name || value == strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,187 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/exp/kit/sshutil"
+)
+
+func TestLabelSelector(t *testing.T) {
+	labels := map[string]string{"instance-group": "fleeting", "env": "prod"}
+
+	testCases := []struct {
+		selector string
+		want     bool
+	}{
+		{selector: "", want: true},
+		{selector: "instance-group=fleeting", want: true},
+		{selector: "instance-group==fleeting", want: true},
+		{selector: "instance-group=other", want: false},
+		{selector: "instance-group!=other", want: true},
+		{selector: "env", want: true},
+		{selector: "!env", want: false},
+		{selector: "!missing", want: true},
+		{selector: "env in (dev, prod)", want: true},
+		{selector: "env notin (dev,prod)", want: false},
+		{selector: "instance-group=fleeting,env in (dev,prod),!missing", want: true},
+		{selector: "instance-group=fleeting,env=dev", want: false},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.selector, func(t *testing.T) {
+			requirements, err := parseLabelSelector(testCase.selector)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.want, matchLabelSelector(requirements, labels))
+		})
+	}
+
+	_, err := parseLabelSelector("env in dev")
+	require.Error(t, err)
+	_, err = parseLabelSelector("a=b,,c=d")
+	require.Error(t, err)
+}
+
+func TestFakeAPI(t *testing.T) {
+	t.Run("pagination and label selector", func(t *testing.T) {
+		ctx := context.Background()
+		api := NewFakeAPI(t)
+		client := api.Client()
+
+		for i := range 60 {
+			group := "fleeting"
+			if i%2 == 1 {
+				group = "other"
+			}
+			api.AddServer(fmt.Sprintf("server-%d", i), map[string]string{"instance-group": group})
+		}
+
+		servers, err := client.Server.All(ctx)
+		require.NoError(t, err)
+		require.Len(t, servers, 60)
+
+		servers, err = client.Server.AllWithOpts(ctx, hcloud.ServerListOpts{
+			ListOpts: hcloud.ListOpts{LabelSelector: "instance-group=fleeting"},
+		})
+		require.NoError(t, err)
+		require.Len(t, servers, 30)
+
+		server, _, err := client.Server.GetByName(ctx, "server-3")
+		require.NoError(t, err)
+		require.Equal(t, "other", server.Labels["instance-group"])
+	})
+
+	t.Run("ssh keys", func(t *testing.T) {
+		ctx := context.Background()
+		api := NewFakeAPI(t)
+		client := api.Client()
+
+		_, publicKey, err := sshutil.GenerateKeyPair()
+		require.NoError(t, err)
+		fingerprint, err := sshutil.GetPublicKeyFingerprint(publicKey)
+		require.NoError(t, err)
+
+		sshKey, _, err := client.SSHKey.Create(ctx, hcloud.SSHKeyCreateOpts{Name: "fleeting", PublicKey: string(publicKey)})
+		require.NoError(t, err)
+
+		found, _, err := client.SSHKey.GetByFingerprint(ctx, fingerprint)
+		require.NoError(t, err)
+		require.Equal(t, sshKey.ID, found.ID)
+
+		_, _, err = client.SSHKey.Create(ctx, hcloud.SSHKeyCreateOpts{Name: "fleeting", PublicKey: string(publicKey)})
+		require.True(t, hcloud.IsError(err, hcloud.ErrorCodeUniquenessError))
+
+		_, err = client.SSHKey.Delete(ctx, sshKey)
+		require.NoError(t, err)
+		require.Empty(t, api.SSHKeys())
+	})
+
+	t.Run("primary ips", func(t *testing.T) {
+		ctx := context.Background()
+		api := NewFakeAPI(t)
+		client := api.Client()
+
+		primaryIP := api.AddPrimaryIP("hel1-dc2", "ipv4", map[string]string{"pool": "fleeting"})
+
+		result, _, err := client.Server.Create(ctx, hcloud.ServerCreateOpts{
+			Name:       "server",
+			ServerType: &hcloud.ServerType{Name: "cpx11"},
+			Image:      &hcloud.Image{Name: "debian-12"},
+			Location:   &hcloud.Location{Name: "hel1"},
+			PublicNet: &hcloud.ServerCreatePublicNet{
+				EnableIPv4: true,
+				IPv4:       &hcloud.PrimaryIP{ID: primaryIP.ID},
+			},
+		})
+		require.NoError(t, err)
+		require.Equal(t, primaryIP.IP, result.Server.PublicNet.IPv4.IP.String())
+		require.Equal(t, hcloud.ServerStatusInitializing, result.Server.Status)
+
+		require.NoError(t, client.Action.WaitFor(ctx, result.Action))
+
+		server, _, err := client.Server.GetByID(ctx, result.Server.ID)
+		require.NoError(t, err)
+		require.Equal(t, hcloud.ServerStatusRunning, server.Status)
+
+		deleteResult, _, err := client.Server.DeleteWithResult(ctx, server)
+		require.NoError(t, err)
+		require.NoError(t, client.Action.WaitFor(ctx, deleteResult.Action))
+
+		// The Primary IP is not deleted with the server.
+		primaryIPs := api.PrimaryIPs()
+		require.Len(t, primaryIPs, 1)
+		require.Nil(t, primaryIPs[0].AssigneeID)
+		require.Empty(t, api.Servers())
+	})
+	t.Run("load balancer targets", func(t *testing.T) {
+		ctx := context.Background()
+		api := NewFakeAPI(t)
+		client := api.Client()
+
+		network := api.AddNetwork("fleeting", "10.0.0.0/16")
+		loadBalancer := api.AddLoadBalancer("fleeting", network.ID)
+		server := api.AddServer("server", nil)
+
+		lb, _, err := client.LoadBalancer.Get(ctx, "fleeting")
+		require.NoError(t, err)
+		require.NotNil(t, lb)
+		require.Len(t, lb.PrivateNet, 1)
+		require.Equal(t, network.ID, lb.PrivateNet[0].Network.ID)
+
+		// The server is not attached to the network of the load balancer.
+		_, _, err = client.LoadBalancer.AddServerTarget(ctx, lb, hcloud.LoadBalancerAddServerTargetOpts{
+			Server: &hcloud.Server{ID: server.ID}, UsePrivateIP: hcloud.Ptr(true),
+		})
+		require.True(t, hcloud.IsError(err, hcloud.ErrorCodeServerNotAttachedToNetwork), err)
+
+		action, _, err := client.LoadBalancer.AddServerTarget(ctx, lb, hcloud.LoadBalancerAddServerTargetOpts{
+			Server: &hcloud.Server{ID: server.ID},
+		})
+		require.NoError(t, err)
+		require.NoError(t, client.Action.WaitFor(ctx, action))
+
+		_, _, err = client.LoadBalancer.AddServerTarget(ctx, lb, hcloud.LoadBalancerAddServerTargetOpts{
+			Server: &hcloud.Server{ID: server.ID},
+		})
+		require.True(t, hcloud.IsError(err, hcloud.ErrorCodeTargetAlreadyDefined), err)
+
+		lb, _, err = client.LoadBalancer.GetByID(ctx, loadBalancer.ID)
+		require.NoError(t, err)
+		require.Len(t, lb.Targets, 1)
+		require.Equal(t, server.ID, lb.Targets[0].Server.Server.ID)
+
+		// The target is removed with the server.
+		deleteResult, _, err := client.Server.DeleteWithResult(ctx, &hcloud.Server{ID: server.ID})
+		require.NoError(t, err)
+		require.NoError(t, client.Action.WaitFor(ctx, deleteResult.Action))
+
+		require.Empty(t, api.LoadBalancers()[0].Targets)
+
+		_, _, err = client.LoadBalancer.RemoveServerTarget(ctx, lb, &hcloud.Server{ID: server.ID})
+		require.True(t, hcloud.IsError(err, hcloud.ErrorCodeNotFound), err)
+	})
+}
@@ -0,0 +1,279 @@
+package testutils
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// FakeDNSRecord is a record of the [FakeDNS] API.
+type FakeDNSRecord struct {
+	ID     string `json:"id"`
+	ZoneID string `json:"zone_id"`
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	TTL    int    `json:"ttl,omitempty"`
+}
+
+type fakeDNSZone struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// FakeDNS is a stateful in-memory fake of the Hetzner DNS API, that models the zones
+// and records used by the plugin.
+type FakeDNS struct {
+	server *httptest.Server
+
+	mu     sync.Mutex
+	lastID int
+
+	token   string
+	zones   map[string]*fakeDNSZone
+	records map[string]*FakeDNSRecord
+
+	// failStatus is the status code of the error returned to every request, the
+	// requests are handled when zero.
+	failStatus int
+}
+
+// NewFakeDNS starts a new [FakeDNS] server with the given zones, which is closed when
+// the test ends. The requests must be authenticated with the token.
+func NewFakeDNS(t testing.TB, token string, zones ...string) *FakeDNS {
+	t.Helper()
+
+	f := &FakeDNS{
+		token:   token,
+		zones:   make(map[string]*fakeDNSZone),
+		records: make(map[string]*FakeDNSRecord),
+	}
+	for _, name := range zones {
+		zone := &fakeDNSZone{ID: f.nextID(), Name: name}
+		f.zones[zone.ID] = zone
+	}
+
+	f.server = httptest.NewServer(f.handler())
+	t.Cleanup(f.server.Close)
+
+	return f
+}
+
+// URL returns the endpoint of the fake API.
+func (f *FakeDNS) URL() string {
+	return f.server.URL
+}
+
+// ZoneID returns the ID of a zone, or an empty string if not found.
+func (f *FakeDNS) ZoneID(name string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, zone := range f.zones {
+		if zone.Name == name {
+			return zone.ID
+		}
+	}
+	return ""
+}
+
+// Records returns a copy of the records, sorted by name, type and value.
+func (f *FakeDNS) Records() []FakeDNSRecord {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	records := make([]FakeDNSRecord, 0, len(f.records))
+	for _, record := range f.records {
+		records = append(records, *record)
+	}
+	slices.SortFunc(records, func(a, b FakeDNSRecord) int {
+		return cmp.Or(
+			cmp.Compare(a.Name, b.Name),
+			cmp.Compare(a.Type, b.Type),
+			cmp.Compare(a.Value, b.Value),
+		)
+	})
+	return records
+}
+
+// AddRecord adds a record to a zone, and returns its ID.
+func (f *FakeDNS) AddRecord(record FakeDNSRecord) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	record.ID = f.nextID()
+	f.records[record.ID] = &record
+	return record.ID
+}
+
+// SetFailStatus makes every request fail with the status code, the requests are
+// handled again when zero.
+func (f *FakeDNS) SetFailStatus(status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.failStatus = status
+}
+
+func (f *FakeDNS) nextID() string {
+	f.lastID++
+	return fmt.Sprintf("dns-%d", f.lastID)
+}
+
+func (f *FakeDNS) handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /zones", f.handle(f.listZones))
+	mux.HandleFunc("GET /records", f.handle(f.listRecords))
+	mux.HandleFunc("POST /records", f.handle(f.postRecord))
+	mux.HandleFunc("DELETE /records/{id}", f.handle(f.deleteRecord))
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeDNSError(w, http.StatusNotFound, fmt.Sprintf("route %s %s not found", r.Method, r.URL.Path))
+	})
+
+	return mux
+}
+
+// fakeDNSError is an error response of the [FakeDNS] API.
+type fakeDNSError struct {
+	status  int
+	message string
+}
+
+func (e *fakeDNSError) Error() string {
+	return e.message
+}
+
+func (f *FakeDNS) handle(fn func(r *http.Request) (int, any, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		if f.failStatus != 0 {
+			writeDNSError(w, f.failStatus, "injected failure")
+			return
+		}
+		if r.Header.Get("Auth-API-Token") != f.token {
+			writeDNSError(w, http.StatusUnauthorized, "invalid authentication credentials")
+			return
+		}
+
+		status, body, err := fn(r)
+		if err != nil {
+			dnsErr, ok := err.(*fakeDNSError)
+			if !ok {
+				dnsErr = &fakeDNSError{status: http.StatusInternalServerError, message: err.Error()}
+			}
+			writeDNSError(w, dnsErr.status, dnsErr.message)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if body != nil {
+			_ = json.NewEncoder(w).Encode(body)
+		}
+	}
+}
+
+func writeDNSError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{"message": message, "code": status},
+	})
+}
+
+func (f *FakeDNS) listZones(r *http.Request) (int, any, error) {
+	name := r.URL.Query().Get("name")
+
+	zones := make([]fakeDNSZone, 0)
+	for _, id := range slices.Sorted(maps.Keys(f.zones)) {
+		zone := f.zones[id]
+		if name == "" || zone.Name == name {
+			zones = append(zones, *zone)
+		}
+	}
+	return http.StatusOK, map[string]any{"zones": zones}, nil
+}
+
+func (f *FakeDNS) listRecords(r *http.Request) (int, any, error) {
+	query := r.URL.Query()
+
+	zoneID := query.Get("zone_id")
+	if _, ok := f.zones[zoneID]; !ok {
+		return 0, nil, &fakeDNSError{status: http.StatusNotFound, message: "zone not found"}
+	}
+
+	records := make([]FakeDNSRecord, 0)
+	for _, id := range slices.Sorted(maps.Keys(f.records)) {
+		if record := f.records[id]; record.ZoneID == zoneID {
+			records = append(records, *record)
+		}
+	}
+
+	page, perPage := 1, len(records)
+	if value, err := strconv.Atoi(query.Get("page")); err == nil && value > 0 {
+		page = value
+	}
+	if value, err := strconv.Atoi(query.Get("per_page")); err == nil && value > 0 {
+		perPage = value
+	}
+	lastPage := 1
+	if perPage > 0 && len(records) > 0 {
+		lastPage = (len(records) + perPage - 1) / perPage
+	}
+
+	start := min((page-1)*perPage, len(records))
+	end := min(start+perPage, len(records))
+
+	return http.StatusOK, map[string]any{
+		"records": records[start:end],
+		"meta": map[string]any{
+			"pagination": map[string]any{"page": page, "per_page": perPage, "last_page": lastPage},
+		},
+	}, nil
+}
+
+func (f *FakeDNS) postRecord(r *http.Request) (int, any, error) {
+	var record FakeDNSRecord
+	if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+		return 0, nil, &fakeDNSError{status: http.StatusBadRequest, message: fmt.Sprintf("invalid request body: %s", err)}
+	}
+
+	if _, ok := f.zones[record.ZoneID]; !ok {
+		return 0, nil, &fakeDNSError{status: http.StatusNotFound, message: "zone not found"}
+	}
+	if record.Name == "" || record.Value == "" {
+		return 0, nil, &fakeDNSError{status: http.StatusUnprocessableEntity, message: "name and value are required"}
+	}
+	switch record.Type {
+	case "A", "AAAA", "TXT", "CNAME":
+	default:
+		return 0, nil, &fakeDNSError{status: http.StatusUnprocessableEntity, message: fmt.Sprintf("invalid record type: %s", record.Type)}
+	}
+
+	record.ID = f.nextID()
+	f.records[record.ID] = &record
+
+	return http.StatusOK, map[string]any{"record": record}, nil
+}
+
+func (f *FakeDNS) deleteRecord(r *http.Request) (int, any, error) {
+	id := r.PathValue("id")
+	if _, ok := f.records[id]; !ok {
+		return 0, nil, &fakeDNSError{status: http.StatusNotFound, message: "record not found"}
+	}
+
+	delete(f.records, id)
+
+	return http.StatusOK, nil, nil
+}
@@ -0,0 +1,277 @@
+package testutils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/schema"
+)
+
+// Fault describes a failure injected by a [FaultInjector] in the requests matching
+// the method and the path.
+type Fault struct {
+	// Method of the matched requests, all methods are matched when empty.
+	Method string
+	// Path of the matched requests, using the [path.Match] syntax. All paths are
+	// matched when empty.
+	Path string
+
+	// Probability of injecting the fault in a matched request, the fault is always
+	// injected when zero.
+	Probability float64
+	// Times is the maximum number of injections, unlimited when zero.
+	Times int
+
+	// Delay is added before handling the request.
+	Delay time.Duration
+
+	// Status and Code of the error response returned instead of forwarding the request.
+	Status int
+	Code   hcloud.ErrorCode
+
+	// FailAction forwards the request, and fails the actions returned in the response
+	// with the Code, or with a generic action failure when Code is empty.
+	FailAction bool
+}
+
+func (f *Fault) match(req *http.Request) bool {
+	if f.Method != "" && f.Method != req.Method {
+		return false
+	}
+	if f.Path != "" {
+		if ok, _ := path.Match(f.Path, req.URL.Path); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// FaultInjector is a [http.RoundTripper] injecting faults in the requests sent to the
+// API, for example to test the cleanup of the resources after failures.
+type FaultInjector struct {
+	next http.RoundTripper
+
+	mu     sync.Mutex
+	rand   *rand.Rand
+	faults []Fault
+	counts []int
+
+	// failedActions holds the error code of the actions to fail, indexed by action ID.
+	failedActions map[int64]string
+
+	injected []string
+}
+
+// NewFaultInjector returns a fault injector forwarding the requests to the default
+// transport. The seed makes the injected faults reproducible.
+func NewFaultInjector(seed uint64, faults ...Fault) *FaultInjector {
+	i := &FaultInjector{
+		next:          http.DefaultTransport,
+		rand:          rand.New(rand.NewPCG(seed, seed)),
+		failedActions: make(map[int64]string),
+	}
+	i.SetFaults(faults...)
+	return i
+}
+
+// SetFaults replaces the faults to inject. Actions that already failed stay failed.
+func (i *FaultInjector) SetFaults(faults ...Fault) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.faults = faults
+	i.counts = make([]int, len(faults))
+}
+
+// Injected returns a description of the injected faults, useful to debug a failing
+// randomized test.
+func (i *FaultInjector) Injected() []string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return append([]string{}, i.injected...)
+}
+
+// Client returns a client sending its requests to the endpoint through the fault
+// injector.
+func (i *FaultInjector) Client(endpoint string) *hcloud.Client {
+	return MakeTestClient(endpoint, hcloud.WithHTTPClient(&http.Client{Transport: i}))
+}
+
+// pick returns the fault to inject in the request, if any.
+func (i *FaultInjector) pick(req *http.Request) *Fault {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for index := range i.faults {
+		fault := &i.faults[index]
+		if !fault.match(req) {
+			continue
+		}
+		if fault.Times > 0 && i.counts[index] >= fault.Times {
+			continue
+		}
+		if fault.Probability > 0 && i.rand.Float64() >= fault.Probability {
+			continue
+		}
+
+		i.counts[index]++
+		i.injected = append(i.injected, fmt.Sprintf("%s %s: %+v", req.Method, req.URL.Path, *fault))
+		return fault
+	}
+	return nil
+}
+
+func (i *FaultInjector) RoundTrip(req *http.Request) (*http.Response, error) {
+	fault := i.pick(req)
+
+	if fault != nil && fault.Delay > 0 {
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(fault.Delay):
+		}
+	}
+
+	if fault != nil && fault.Status != 0 {
+		return errorResponse(req, fault.Status, fault.Code)
+	}
+
+	resp, err := i.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	failAction := fault != nil && fault.FailAction
+	isActionRequest := strings.HasPrefix(req.URL.Path, "/actions")
+	if !failAction && !isActionRequest {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if failAction {
+		code := string(fault.Code)
+		if code == "" {
+			code = "action_failed"
+		}
+		i.recordFailedActions(body, code)
+	}
+	if isActionRequest {
+		body = i.rewriteActions(body)
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Del("Content-Length")
+
+	return resp, nil
+}
+
+// actionsBody holds the actions found in a response body.
+type actionsBody struct {
+	Action      *schema.Action  `json:"action,omitempty"`
+	Actions     []schema.Action `json:"actions,omitempty"`
+	NextActions []schema.Action `json:"next_actions,omitempty"`
+}
+
+func (i *FaultInjector) recordFailedActions(body []byte, code string) {
+	var actions actionsBody
+	if err := json.Unmarshal(body, &actions); err != nil {
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if actions.Action != nil {
+		i.failedActions[actions.Action.ID] = code
+	}
+	for _, action := range append(actions.Actions, actions.NextActions...) {
+		i.failedActions[action.ID] = code
+	}
+}
+
+// rewriteActions fails the actions recorded as failed in a response body.
+func (i *FaultInjector) rewriteActions(body []byte) []byte {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return body
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	fail := func(action *schema.Action) {
+		code, ok := i.failedActions[action.ID]
+		if !ok {
+			return
+		}
+		action.Status = "error"
+		action.Error = &schema.ActionError{Code: code, Message: "injected action failure"}
+		if action.Finished == nil {
+			action.Finished = hcloud.Ptr(time.Now())
+		}
+	}
+
+	if data, ok := raw["action"]; ok {
+		var action schema.Action
+		if err := json.Unmarshal(data, &action); err == nil {
+			fail(&action)
+			raw["action"], _ = json.Marshal(action)
+		}
+	}
+	if data, ok := raw["actions"]; ok {
+		var actions []schema.Action
+		if err := json.Unmarshal(data, &actions); err == nil {
+			for index := range actions {
+				fail(&actions[index])
+			}
+			raw["actions"], _ = json.Marshal(actions)
+		}
+	}
+
+	result, err := json.Marshal(raw)
+	if err != nil {
+		return body
+	}
+	return result
+}
+
+func errorResponse(req *http.Request, status int, code hcloud.ErrorCode) (*http.Response, error) {
+	if code == "" {
+		code = hcloud.ErrorCodeServiceError
+	}
+
+	body, err := json.Marshal(schema.ErrorResponse{
+		Error: schema.Error{Code: string(code), Message: "injected fault"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
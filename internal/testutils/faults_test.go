@@ -0,0 +1,59 @@
+package testutils
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+func TestFaultInjector(t *testing.T) {
+	t.Run("error response", func(t *testing.T) {
+		ctx := context.Background()
+		api := NewFakeAPI(t)
+		injector := NewFaultInjector(0, Fault{
+			Method: "GET", Path: "/servers/*",
+			Status: http.StatusTooManyRequests, Code: hcloud.ErrorCodeRateLimitExceeded,
+			Times: 1,
+		})
+		client := injector.Client(api.URL())
+
+		server := api.AddServer("server", nil)
+
+		_, _, err := client.Server.GetByID(ctx, server.ID)
+		require.NoError(t, err, "rate limit errors are retried by the client")
+
+		injector.SetFaults(Fault{Path: "/servers/*", Status: http.StatusUnprocessableEntity, Code: hcloud.ErrorCodeInvalidInput})
+
+		_, _, err = client.Server.GetByID(ctx, server.ID)
+		require.True(t, hcloud.IsError(err, hcloud.ErrorCodeInvalidInput))
+
+		_, err = client.Server.All(ctx)
+		require.NoError(t, err)
+
+		require.Len(t, injector.Injected(), 2)
+	})
+
+	t.Run("failed action", func(t *testing.T) {
+		ctx := context.Background()
+		api := NewFakeAPI(t)
+		injector := NewFaultInjector(0, Fault{Method: "POST", Path: "/volumes", FailAction: true, Code: "volume_failed"})
+		client := injector.Client(api.URL())
+
+		result, _, err := client.Volume.Create(ctx, hcloud.VolumeCreateOpts{
+			Name: "volume", Size: 10, Location: &hcloud.Location{Name: "hel1"},
+		})
+		require.NoError(t, err)
+
+		err = client.Action.WaitFor(ctx, result.Action)
+		var actionErr hcloud.ActionError
+		require.ErrorAs(t, err, &actionErr)
+		require.Equal(t, "volume_failed", actionErr.Code)
+
+		// The resource exists even though the action failed.
+		require.Len(t, api.Volumes(), 1)
+	})
+}
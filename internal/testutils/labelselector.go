@@ -0,0 +1,123 @@
+package testutils
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// labelRequirement is a single expression of a label selector.
+type labelRequirement struct {
+	key      string
+	operator string
+	values   []string
+}
+
+// parseLabelSelector parses a label selector, using the syntax described in
+// https://docs.hetzner.cloud/#label-selector.
+func parseLabelSelector(selector string) ([]labelRequirement, error) {
+	expressions := make([]string, 0)
+
+	// Split on the commas that are not inside a set of values.
+	depth, start := 0, 0
+	for i, c := range selector {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				expressions = append(expressions, selector[start:i])
+				start = i + 1
+			}
+		}
+	}
+	expressions = append(expressions, selector[start:])
+
+	requirements := make([]labelRequirement, 0, len(expressions))
+	for _, expression := range expressions {
+		expression = strings.TrimSpace(expression)
+		if expression == "" {
+			if len(expressions) == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("invalid label selector: %q", selector)
+		}
+
+		requirement, err := parseLabelRequirement(expression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid label selector: %q: %w", selector, err)
+		}
+		requirements = append(requirements, requirement)
+	}
+
+	return requirements, nil
+}
+
+func parseLabelRequirement(expression string) (labelRequirement, error) {
+	if key, ok := strings.CutPrefix(expression, "!"); ok {
+		return labelRequirement{key: strings.TrimSpace(key), operator: "!"}, nil
+	}
+
+	for _, operator := range []string{"==", "!=", "="} {
+		if key, value, ok := strings.Cut(expression, operator); ok {
+			if operator == "==" {
+				operator = "="
+			}
+			return labelRequirement{
+				key:      strings.TrimSpace(key),
+				operator: operator,
+				values:   []string{strings.TrimSpace(value)},
+			}, nil
+		}
+	}
+
+	for _, operator := range []string{" notin ", " in "} {
+		if key, values, ok := strings.Cut(expression, operator); ok {
+			values = strings.TrimSpace(values)
+			if !strings.HasPrefix(values, "(") || !strings.HasSuffix(values, ")") {
+				return labelRequirement{}, fmt.Errorf("invalid set of values: %s", values)
+			}
+
+			requirement := labelRequirement{key: strings.TrimSpace(key), operator: strings.TrimSpace(operator)}
+			for _, value := range strings.Split(values[1:len(values)-1], ",") {
+				requirement.values = append(requirement.values, strings.TrimSpace(value))
+			}
+			return requirement, nil
+		}
+	}
+
+	if strings.ContainsAny(expression, " ()") {
+		return labelRequirement{}, fmt.Errorf("invalid expression: %s", expression)
+	}
+
+	return labelRequirement{key: expression, operator: "exists"}, nil
+}
+
+// matchLabelSelector returns whether the labels match all the requirements.
+func matchLabelSelector(requirements []labelRequirement, labels map[string]string) bool {
+	for _, requirement := range requirements {
+		value, exists := labels[requirement.key]
+
+		var match bool
+		switch requirement.operator {
+		case "exists":
+			match = exists
+		case "!":
+			match = !exists
+		case "=":
+			match = exists && value == requirement.values[0]
+		case "!=":
+			match = !exists || value != requirement.values[0]
+		case "in":
+			match = exists && slices.Contains(requirement.values, value)
+		case "notin":
+			match = !exists || !slices.Contains(requirement.values, value)
+		}
+		if !match {
+			return false
+		}
+	}
+	return true
+}
@@ -34,6 +34,21 @@ var (
 			},
 		},
 	}
+	GetDatacentersRequest = mockutil.Request{
+		Method: "GET", Path: "/datacenters?page=1&per_page=50",
+		Status: 200,
+		JSON: schema.DatacenterListResponse{
+			Datacenters: []schema.Datacenter{
+				{ID: 3, Name: "hel1-dc2",
+					Location: schema.Location{ID: 3, Name: "hel1"},
+					ServerTypes: schema.DatacenterServerTypes{
+						Supported: []int64{1, 2},
+						Available: []int64{1, 2},
+					},
+				},
+			},
+		},
+	}
 	GetImageDebian12Request = mockutil.Request{
 		Method: "GET", Path: "/images?architecture=x86&include_deprecated=true&name=debian-12",
 		Status: 200,
@@ -0,0 +1,103 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+)
+
+func TestEnabled(t *testing.T) {
+	testCases := []struct {
+		name string
+		env  map[string]string
+		want bool
+	}{
+		{
+			name: "not configured",
+			want: false,
+		},
+		{
+			name: "endpoint",
+			env:  map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://localhost:4318"},
+			want: true,
+		},
+		{
+			name: "traces endpoint",
+			env:  map[string]string{"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "http://localhost:4318/v1/traces"},
+			want: true,
+		},
+		{
+			name: "otlp exporter",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT": "http://localhost:4318",
+				"OTEL_TRACES_EXPORTER":        "otlp",
+			},
+			want: true,
+		},
+		{
+			name: "no exporter",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT": "http://localhost:4318",
+				"OTEL_TRACES_EXPORTER":        "none",
+			},
+			want: false,
+		},
+		{
+			name: "sdk disabled",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT": "http://localhost:4318",
+				"OTEL_SDK_DISABLED":           "TRUE",
+			},
+			want: false,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			for _, key := range []string{
+				"OTEL_SDK_DISABLED",
+				"OTEL_TRACES_EXPORTER",
+				"OTEL_EXPORTER_OTLP_ENDPOINT",
+				"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT",
+			} {
+				t.Setenv(key, testCase.env[key])
+			}
+
+			assert.Equal(t, testCase.want, Enabled())
+		})
+	}
+}
+
+func TestSetup(t *testing.T) {
+	ctx := context.Background()
+
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	t.Run("disabled", func(t *testing.T) {
+		t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+		t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+
+		shutdown, err := Setup(ctx, "fleeting-plugin-hetzner", "dev")
+		require.NoError(t, err)
+		require.NoError(t, shutdown(ctx))
+
+		assert.Equal(t, previous, otel.GetTracerProvider())
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318")
+		t.Setenv("OTEL_SDK_DISABLED", "")
+		t.Setenv("OTEL_TRACES_EXPORTER", "")
+
+		shutdown, err := Setup(ctx, "fleeting-plugin-hetzner", "dev")
+		require.NoError(t, err)
+
+		assert.NotEqual(t, previous, otel.GetTracerProvider())
+
+		// No span was recorded, nothing is exported
+		require.NoError(t, shutdown(ctx))
+	})
+}
@@ -0,0 +1,90 @@
+package hetzner
+
+import (
+	"context"
+
+	"github.com/hashicorp/go-hclog"
+	"gitlab.com/gitlab-org/fleeting/fleeting/provider"
+
+	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/instancegroup"
+)
+
+// InstanceDetails describes an instance for the operator commands.
+type InstanceDetails struct {
+	Instance *instancegroup.Instance
+
+	// State is the state reported to the autoscaler, empty when the instance is not
+	// reported.
+	State provider.State
+
+	ConnectInfo provider.ConnectInfo
+}
+
+// Open initializes the instance group for the operator commands. Unlike
+// [InstanceGroup.Init], the connector SSH key is not uploaded.
+func (g *InstanceGroup) Open(ctx context.Context, log hclog.Logger, settings provider.Settings) error {
+	g.settings = settings
+	g.log = log.With("location", g.Location, "name", g.Name)
+
+	if err := g.validate(); err != nil {
+		return err
+	}
+
+	if err := g.populate(); err != nil {
+		return err
+	}
+
+	g.client = g.newClient()
+
+	g.group = instancegroup.New(g.client, g.log, g.Name, g.groupConfig())
+
+	return g.group.Init(ctx)
+}
+
+// Instances returns the details of all the instances in the instance group.
+func (g *InstanceGroup) Instances(ctx context.Context) ([]InstanceDetails, error) {
+	instances, err := g.group.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]InstanceDetails, 0, len(instances))
+	for _, instance := range instances {
+		result = append(result, g.instanceDetails(instance))
+	}
+
+	return result, nil
+}
+
+// Delete deletes the instances and their resources. Unlike [InstanceGroup.Decrease],
+// the instances are never rebuilt or parked.
+func (g *InstanceGroup) Delete(ctx context.Context, iids []string) ([]string, error) {
+	return g.group.Delete(ctx, iids)
+}
+
+// Instance returns the details of an instance in the instance group.
+func (g *InstanceGroup) Instance(ctx context.Context, iid string) (*InstanceDetails, error) {
+	instance, err := g.group.Get(ctx, iid)
+	if err != nil {
+		return nil, err
+	}
+
+	details := g.instanceDetails(instance)
+
+	return &details, nil
+}
+
+func (g *InstanceGroup) instanceDetails(instance *instancegroup.Instance) InstanceDetails {
+	details := InstanceDetails{
+		Instance: instance,
+		State:    g.instanceState(instance),
+	}
+
+	info, err := g.connectInfo(instance)
+	if err != nil {
+		g.log.Warn("could not get instance connect info", "id", instance.IID(), "error", err)
+	}
+	details.ConnectInfo = info
+
+	return details
+}
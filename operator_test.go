@@ -0,0 +1,112 @@
+package hetzner
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/gitlab-org/fleeting/fleeting/provider"
+	"go.uber.org/mock/gomock"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/schema"
+
+	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/instancegroup"
+)
+
+func TestInstances(t *testing.T) {
+	testCases := []struct {
+		name string
+		run  func(t *testing.T, mock *instancegroup.MockInstanceGroup, group *InstanceGroup, ctx context.Context)
+	}{
+		{name: "success",
+			run: func(t *testing.T, mock *instancegroup.MockInstanceGroup, group *InstanceGroup, ctx context.Context) {
+				mock.EXPECT().
+					List(ctx).
+					Return([]*instancegroup.Instance{
+						instancegroup.InstanceFromServer(hcloud.ServerFromSchema(schema.Server{
+							ID: 1, Name: "fleeting-a", Status: "running",
+							ServerType: schema.ServerType{Name: "cpx11", Architecture: "x86"},
+							PublicNet: schema.ServerPublicNet{
+								IPv4: schema.ServerPublicNetIPv4{IP: "37.1.1.1"},
+							},
+						})),
+						instancegroup.InstanceFromServer(hcloud.ServerFromSchema(schema.Server{
+							ID: 2, Name: "fleeting-b", Status: "migrating",
+							ServerType: schema.ServerType{Name: "cpx11", Architecture: "x86"},
+						})),
+						instancegroup.InstanceFromServer(hcloud.ServerFromSchema(schema.Server{
+							ID: 3, Name: "fleeting-c", Status: "rebuilding",
+							ServerType: schema.ServerType{Name: "cpx11", Architecture: "x86"},
+						})),
+					}, nil)
+
+				result, err := group.Instances(ctx)
+				require.NoError(t, err)
+				require.Len(t, result, 3)
+
+				require.Equal(t, "fleeting-a:1", result[0].Instance.IID())
+				require.Equal(t, provider.StateRunning, result[0].State)
+				require.Equal(t, "37.1.1.1", result[0].ConnectInfo.ExternalAddr)
+				require.Equal(t, "amd64", result[0].ConnectInfo.Arch)
+
+				require.Equal(t, "fleeting-b:2", result[1].Instance.IID())
+				require.Equal(t, provider.State(""), result[1].State)
+
+				// The rebuilt instances are reported as new instances
+				require.Equal(t, "fleeting-c:3", result[2].Instance.IID())
+				require.Equal(t, provider.StateCreating, result[2].State)
+			},
+		},
+		{name: "failure",
+			run: func(t *testing.T, mock *instancegroup.MockInstanceGroup, group *InstanceGroup, ctx context.Context) {
+				mock.EXPECT().
+					List(ctx).
+					Return(nil, fmt.Errorf("some error"))
+
+				_, err := group.Instances(ctx)
+				require.EqualError(t, err, "some error")
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mock := instancegroup.NewMockInstanceGroup(ctrl)
+			group := &InstanceGroup{
+				log:      hclog.New(hclog.DefaultOptions),
+				settings: provider.Settings{},
+				group:    mock,
+			}
+
+			testCase.run(t, mock, group, context.Background())
+		})
+	}
+}
+
+func TestInstance(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mock := instancegroup.NewMockInstanceGroup(ctrl)
+	group := &InstanceGroup{
+		log:      hclog.New(hclog.DefaultOptions),
+		settings: provider.Settings{},
+		group:    mock,
+	}
+	ctx := context.Background()
+
+	mock.EXPECT().
+		Get(ctx, "fleeting-a:1").
+		Return(instancegroup.InstanceFromServer(hcloud.ServerFromSchema(schema.Server{
+			ID: 1, Name: "fleeting-a", Status: "initializing",
+			ServerType: schema.ServerType{Name: "cpx11", Architecture: "arm"},
+			PrivateNet: []schema.ServerPrivateNet{{IP: "10.0.1.2"}},
+		})), nil)
+
+	result, err := group.Instance(ctx, "fleeting-a:1")
+	require.NoError(t, err)
+	require.Equal(t, provider.StateCreating, result.State)
+	require.Equal(t, "arm64", result.ConnectInfo.Arch)
+	require.Equal(t, "10.0.1.2", result.ConnectInfo.InternalAddr)
+}
@@ -8,21 +8,34 @@ import (
 	"net/http"
 	"net/netip"
 	"path"
+	"slices"
 	"time"
 
 	"github.com/hashicorp/go-hclog"
 	"gitlab.com/gitlab-org/fleeting/fleeting/provider"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/hetznercloud/hcloud-go/v2/hcloud"
 	"github.com/hetznercloud/hcloud-go/v2/hcloud/exp/kit/sshutil"
 
+	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/eventlog"
 	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/instancegroup"
+	"gitlab.com/hetznercloud/fleeting-plugin-hetzner/internal/tracing"
 )
 
 var _ provider.InstanceGroup = (*InstanceGroup)(nil)
 
+// tracer returns the tracer of the package from the global tracer provider, which is
+// registered during the init.
+func tracer() trace.Tracer {
+	return otel.Tracer("gitlab.com/hetznercloud/fleeting-plugin-hetzner")
+}
+
 type InstanceGroup struct {
-	Name string `json:"name"`
+	Name         string `json:"name"`
+	NameTemplate string `json:"name_template"`
 
 	Token    string `json:"token"`
 	Endpoint string `json:"endpoint"`
@@ -32,9 +45,26 @@ type InstanceGroup struct {
 	Image        string        `json:"image"`
 	UserData     string        `json:"user_data"`
 	UserDataFile string        `json:"user_data_file"`
+	SSHKeys      []string      `json:"ssh_keys"`
+	OS           string        `json:"os"`
 
 	VolumeSize int `json:"volume_size"`
 
+	InstanceCacheTTL        Duration `json:"instance_cache_ttl"`
+	AsyncIncrease           bool     `json:"async_increase"`
+	RecycleMode             string   `json:"recycle_mode"`
+	ParkingEnabled          bool     `json:"parking_enabled"`
+	ParkingMargin           Duration `json:"parking_margin"`
+	GracefulShutdownTimeout Duration `json:"graceful_shutdown_timeout"`
+	ServerCreateTimeout     Duration `json:"server_create_timeout"`
+	VolumeCreateTimeout     Duration `json:"volume_create_timeout"`
+	ServerDeleteTimeout     Duration `json:"server_delete_timeout"`
+	ProtectInstances        bool     `json:"protect_instances"`
+	EventLogPath            string   `json:"event_log_path"`
+
+	Hooks Hooks `json:"hooks"`
+	DNS   *DNS  `json:"dns"`
+
 	PublicIPv4Disabled   bool   `json:"public_ipv4_disabled"`
 	PublicIPv6Disabled   bool   `json:"public_ipv6_disabled"`
 	PublicIPPoolEnabled  bool   `json:"public_ip_pool_enabled"`
@@ -42,6 +72,12 @@ type InstanceGroup struct {
 
 	PrivateNetworks []string `json:"private_networks"`
 
+	LoadBalancers            []string `json:"load_balancers"`
+	LoadBalancerUsePrivateIP bool     `json:"load_balancer_use_private_ip"`
+
+	Labels     map[string]string `json:"labels"`
+	OwnerToken string            `json:"owner_token"`
+
 	sshKey *hcloud.SSHKey
 	labels map[string]string
 
@@ -52,8 +88,27 @@ type InstanceGroup struct {
 
 	client *hcloud.Client
 	group  instancegroup.InstanceGroup
+
+	breaker *circuitBreaker
+	// retryBackoff is the delay before retrying the instances that failed with
+	// transient errors.
+	retryBackoff time.Duration
+
+	events *eventlog.Log
+
+	shutdownTracing func(context.Context) error
+	// states holds the last states reported for the instances, indexed by IID.
+	states map[string]provider.State
+
+	// lastParkingCheck is the time of the last periodic deletion of the expired parked
+	// instances.
+	lastParkingCheck time.Time
 }
 
+// parkingInterval is the interval between the periodic deletions of the expired
+// parked instances.
+const parkingInterval = time.Minute
+
 func (g *InstanceGroup) Init(ctx context.Context, log hclog.Logger, settings provider.Settings) (info provider.ProviderInfo, err error) {
 	g.settings = settings
 	g.log = log.With("location", g.Location, "name", g.Name)
@@ -62,32 +117,40 @@ func (g *InstanceGroup) Init(ctx context.Context, log hclog.Logger, settings pro
 		return
 	}
 
-	if err = g.populate(); err != nil {
+	g.shutdownTracing, err = tracing.Setup(ctx, Version.Name, Version.String())
+	if err != nil {
 		return
 	}
+	defer func(ctx context.Context) {
+		// The tracer provider is only shut down by Shutdown once initialized.
+		if err != nil {
+			if shutdownErr := g.shutdownTracing(ctx); shutdownErr != nil {
+				g.log.Warn("could not shutdown tracing", "error", shutdownErr)
+			}
+			g.shutdownTracing = nil
+		}
+	}(ctx)
 
-	// Create client
-	clientOptions := []hcloud.ClientOption{
-		hcloud.WithApplication(Version.Name, Version.String()),
-		hcloud.WithToken(g.Token),
-		hcloud.WithHTTPClient(&http.Client{
-			Timeout: 15 * time.Second,
-		}),
-		hcloud.WithPollOpts(hcloud.PollOpts{
-			BackoffFunc: hcloud.ExponentialBackoffWithOpts(hcloud.ExponentialBackoffOpts{
-				Base:       time.Second,
-				Multiplier: 2.0,
-				Cap:        5 * time.Second,
-			}),
-		}),
+	ctx, span := tracer().Start(ctx, "Init")
+	defer func() { tracing.End(span, err) }()
+
+	if err = g.populate(); err != nil {
+		return
 	}
-	if g.Endpoint != "" {
-		clientOptions = append(clientOptions, hcloud.WithEndpoint(g.Endpoint))
+
+	g.client = g.newClient()
+
+	if g.EventLogPath != "" {
+		g.events, err = eventlog.Open(g.EventLogPath, g.Name, g.log)
+		if err != nil {
+			return
+		}
 	}
-	g.client = hcloud.NewClient(clientOptions...)
 
 	// Prepare credentials
-	if !g.settings.UseStaticCredentials {
+	if g.windowsEnabled() {
+		g.log.Info("using generated windows administrator password")
+	} else if !g.settings.UseStaticCredentials {
 		g.log.Info("generating ssh key")
 		sshPrivateKey, sshPublicKey, err := sshutil.GenerateKeyPair()
 		if err != nil {
@@ -114,7 +177,52 @@ func (g *InstanceGroup) Init(ctx context.Context, log hclog.Logger, settings pro
 	}
 
 	// Create instance group
+	g.group = instancegroup.New(g.client, g.log, g.Name, g.groupConfig())
+	g.breaker = &circuitBreaker{}
+	g.retryBackoff = defaultRetryBackoff
+
+	if err = g.group.Init(ctx); err != nil {
+		return
+	}
+
+	if err := g.group.CheckOwners(ctx); err != nil {
+		g.log.Warn("could not check the owners of the resources", "error", err)
+	}
+
+	return provider.ProviderInfo{
+		ID:        path.Join("hetzner", g.Location, g.Name),
+		MaxSize:   math.MaxInt,
+		Version:   Version.String(),
+		BuildInfo: Version.BuildInfo(),
+	}, nil
+}
+
+// newClient creates a Hetzner Cloud API client from the plugin config.
+func (g *InstanceGroup) newClient() *hcloud.Client {
+	clientOptions := []hcloud.ClientOption{
+		hcloud.WithApplication(Version.Name, Version.String()),
+		hcloud.WithToken(g.Token),
+		hcloud.WithHTTPClient(&http.Client{
+			Timeout: 15 * time.Second,
+		}),
+		hcloud.WithPollOpts(hcloud.PollOpts{
+			BackoffFunc: hcloud.ExponentialBackoffWithOpts(hcloud.ExponentialBackoffOpts{
+				Base:       time.Second,
+				Multiplier: 2.0,
+				Cap:        5 * time.Second,
+			}),
+		}),
+	}
+	if g.Endpoint != "" {
+		clientOptions = append(clientOptions, hcloud.WithEndpoint(g.Endpoint))
+	}
+	return hcloud.NewClient(clientOptions...)
+}
+
+// groupConfig creates the instance group config from the plugin config.
+func (g *InstanceGroup) groupConfig() instancegroup.Config {
 	groupConfig := instancegroup.Config{
+		NameTemplate:         g.NameTemplate,
 		Location:             g.Location,
 		ServerTypes:          g.ServerTypes,
 		Image:                g.Image,
@@ -125,28 +233,68 @@ func (g *InstanceGroup) Init(ctx context.Context, log hclog.Logger, settings pro
 		PublicIPPoolSelector: g.PublicIPPoolSelector,
 		PrivateNetworks:      g.PrivateNetworks,
 		Labels:               g.labels,
+		OwnerToken:           g.OwnerToken,
 		VolumeSize:           g.VolumeSize,
+
+		LoadBalancers:            g.LoadBalancers,
+		LoadBalancerUsePrivateIP: g.LoadBalancerUsePrivateIP,
+
+		InstanceCacheTTL:        time.Duration(g.InstanceCacheTTL),
+		AsyncIncrease:           g.AsyncIncrease,
+		RecycleMode:             g.RecycleMode,
+		ParkingEnabled:          g.ParkingEnabled,
+		ParkingMargin:           time.Duration(g.ParkingMargin),
+		GracefulShutdownTimeout: time.Duration(g.GracefulShutdownTimeout),
+		ServerCreateTimeout:     time.Duration(g.ServerCreateTimeout),
+		VolumeCreateTimeout:     time.Duration(g.VolumeCreateTimeout),
+		ServerDeleteTimeout:     time.Duration(g.ServerDeleteTimeout),
+		ProtectInstances:        g.ProtectInstances,
+		EventLog:                g.events,
+
+		Hooks: instancegroup.Hooks{
+			PreCreate:  g.Hooks.PreCreate.groupHook(),
+			PostCreate: g.Hooks.PostCreate.groupHook(),
+			PreDelete:  g.Hooks.PreDelete.groupHook(),
+			PostDelete: g.Hooks.PostDelete.groupHook(),
+		},
 	}
 
-	if g.sshKey != nil {
-		groupConfig.SSHKeys = []string{g.sshKey.Name}
+	if g.DNS != nil {
+		groupConfig.DNS = &instancegroup.DNSConfig{
+			Endpoint:       g.DNS.Endpoint,
+			Token:          g.DNS.Token,
+			Zone:           g.DNS.Zone,
+			RecordTemplate: g.DNS.RecordTemplate,
+			TTL:            g.DNS.TTL,
+		}
 	}
 
-	g.group = instancegroup.New(g.client, g.log, g.Name, groupConfig)
+	if g.windowsEnabled() {
+		groupConfig.WindowsEnabled = true
+		groupConfig.WindowsUsername = g.settings.Username
+		if g.settings.UseStaticCredentials {
+			groupConfig.WindowsPassword = g.settings.Password
+		}
+	}
 
-	if err = g.group.Init(ctx); err != nil {
-		return
+	groupConfig.SSHKeys = make([]string, 0, len(g.SSHKeys)+1)
+	if g.sshKey != nil {
+		groupConfig.SSHKeys = append(groupConfig.SSHKeys, g.sshKey.Name)
+		groupConfig.SSHKeyUserLabel = g.sshKeyUserLabel()
+	}
+	for _, sshKey := range g.SSHKeys {
+		if !slices.Contains(groupConfig.SSHKeys, sshKey) {
+			groupConfig.SSHKeys = append(groupConfig.SSHKeys, sshKey)
+		}
 	}
 
-	return provider.ProviderInfo{
-		ID:        path.Join("hetzner", g.Location, g.Name),
-		MaxSize:   math.MaxInt,
-		Version:   Version.String(),
-		BuildInfo: Version.BuildInfo(),
-	}, nil
+	return groupConfig
 }
 
-func (g *InstanceGroup) Update(ctx context.Context, update func(string, provider.State)) error {
+func (g *InstanceGroup) Update(ctx context.Context, update func(string, provider.State)) (err error) {
+	ctx, span := tracer().Start(ctx, "Update")
+	defer func() { tracing.End(span, err) }()
+
 	instances, err := g.group.List(ctx)
 	if err != nil {
 		return err
@@ -154,43 +302,105 @@ func (g *InstanceGroup) Update(ctx context.Context, update func(string, provider
 
 	g.size = len(instances)
 
+	parking := g.ParkingEnabled || g.RecycleMode == instancegroup.RecycleModeRebuild
+	if parking && time.Since(g.lastParkingCheck) >= parkingInterval {
+		g.lastParkingCheck = time.Now()
+		if parkingErr := g.group.ExpireParked(ctx); parkingErr != nil {
+			g.log.Error("could not delete the expired parked instances", "error", parkingErr)
+		}
+	}
+
+	states := make(map[string]provider.State, len(instances))
 	for _, instance := range instances {
-		id := instance.IID()
+		state := g.instanceState(instance)
+		if state == "" {
+			continue
+		}
 
-		var state provider.State
+		if previous := g.states[instance.IID()]; previous != state {
+			g.events.Record(eventlog.Event{
+				Type:          eventlog.InstanceStateChanged,
+				IID:           instance.IID(),
+				Name:          instance.Name,
+				ID:            instance.ID,
+				State:         string(state),
+				PreviousState: string(previous),
+			})
+		}
+		states[instance.IID()] = state
 
-		switch instance.Server.Status {
-		case hcloud.ServerStatusStopping, hcloud.ServerStatusDeleting:
-			state = provider.StateDeleting
+		update(instance.IID(), state)
+	}
+	g.states = states
 
-		// Server creation always go through `initializing` and `off`. Since we never
-		// shutdown servers, we can assume that "off" is still in the creation phase.
-		case hcloud.ServerStatusOff:
-			state = provider.StateCreating
+	return nil
+}
 
-		case hcloud.ServerStatusInitializing, hcloud.ServerStatusStarting:
-			state = provider.StateCreating
+// instanceState maps the status of the instance server to the state reported to the
+// autoscaler. An empty state is returned when the instance must not be reported.
+func (g *InstanceGroup) instanceState(instance *instancegroup.Instance) provider.State {
+	if instance.Deleting {
+		return provider.StateDeleting
+	}
 
-		case hcloud.ServerStatusRunning:
-			state = provider.StateRunning
+	switch instance.Server.Status {
+	case hcloud.ServerStatusStopping, hcloud.ServerStatusDeleting:
+		return provider.StateDeleting
 
-		case hcloud.ServerStatusMigrating, hcloud.ServerStatusRebuilding, hcloud.ServerStatusUnknown:
-			g.log.Debug("unhandled instance status", "id", id, "status", instance.Server.Status)
-			continue
+	// Server creation always go through `initializing` and `off`. Since servers are
+	// only shutdown right before their deletion, we can assume that "off" is still in
+	// the creation phase.
+	case hcloud.ServerStatusOff:
+		return provider.StateCreating
 
-		default:
-			g.log.Error("unexpected instance status", "id", id, "status", instance.Server.Status)
-			continue
-		}
+	// Servers are only rebuilt when recycling the instances, and are reported as new
+	// instances.
+	case hcloud.ServerStatusInitializing, hcloud.ServerStatusStarting, hcloud.ServerStatusRebuilding:
+		return provider.StateCreating
 
-		update(id, state)
-	}
+	case hcloud.ServerStatusRunning:
+		return provider.StateRunning
 
-	return nil
+	case hcloud.ServerStatusMigrating, hcloud.ServerStatusUnknown:
+		g.log.Debug("unhandled instance status", "id", instance.IID(), "status", instance.Server.Status)
+		return ""
+
+	default:
+		g.log.Error("unexpected instance status", "id", instance.IID(), "status", instance.Server.Status)
+		return ""
+	}
 }
 
-func (g *InstanceGroup) Increase(ctx context.Context, delta int) (int, error) {
+func (g *InstanceGroup) Increase(ctx context.Context, delta int) (_ int, err error) {
+	ctx, span := tracer().Start(ctx, "Increase", trace.WithAttributes(attribute.Int("delta", delta)))
+	defer func() { tracing.End(span, err) }()
+
+	delta, cooldown := g.breaker.allow(delta)
+	if delta == 0 {
+		g.log.Debug("skipping instances creation after capacity errors", "remaining_cooldown", cooldown)
+		return 0, nil
+	}
+
 	created, err := g.group.Increase(ctx, delta)
+	g.logInstanceErrors("could not create instance", err)
+
+	// Retry the instances that failed with transient errors once.
+	if retry, remaining := splitRetryable(err); len(retry) > 0 {
+		g.log.Warn("retrying instances creation after transient errors", "count", len(retry), "backoff", g.retryBackoff)
+
+		if g.backoff(ctx) {
+			var retried []string
+			retried, err = g.group.Increase(ctx, len(retry))
+			g.logInstanceErrors("could not create instance", err)
+
+			created = append(created, retried...)
+			err = errors.Join(remaining, err)
+		}
+	}
+
+	if cooldown := g.breaker.record(len(created), err); cooldown > 0 {
+		g.log.Warn("pausing instances creation after capacity errors", "cooldown", cooldown)
+	}
 
 	g.size += len(created)
 
@@ -201,12 +411,37 @@ func (g *InstanceGroup) Increase(ctx context.Context, delta int) (int, error) {
 	return len(created), err
 }
 
-func (g *InstanceGroup) Decrease(ctx context.Context, iids []string) ([]string, error) {
+func (g *InstanceGroup) Decrease(ctx context.Context, iids []string) (_ []string, err error) {
+	ctx, span := tracer().Start(ctx, "Decrease", trace.WithAttributes(attribute.StringSlice("iids", iids)))
+	defer func() { tracing.End(span, err) }()
+
 	if len(iids) == 0 {
 		return nil, nil
 	}
 
 	deleted, err := g.group.Decrease(ctx, iids)
+	g.logInstanceErrors("could not delete instance", err)
+
+	// Retry the instances that failed with transient errors once. The delete hooks
+	// already ran for the failed instances, and must not run twice.
+	if retry, remaining := splitRetryable(err); len(retry) > 0 && g.Hooks.PreDelete == nil && g.Hooks.PostDelete == nil {
+		failed := slices.DeleteFunc(slices.Clone(iids), func(iid string) bool {
+			instance, err := instancegroup.InstanceFromIID(iid)
+			return err != nil || !slices.Contains(retry, instance.Name) || slices.Contains(deleted, iid)
+		})
+		if len(failed) > 0 {
+			g.log.Warn("retrying instances deletion after transient errors", "count", len(failed), "backoff", g.retryBackoff)
+
+			if g.backoff(ctx) {
+				var retried []string
+				retried, err = g.group.Decrease(ctx, failed)
+				g.logInstanceErrors("could not delete instance", err)
+
+				deleted = append(deleted, retried...)
+				err = errors.Join(remaining, err)
+			}
+		}
+	}
 
 	g.size -= len(deleted)
 
@@ -217,16 +452,126 @@ func (g *InstanceGroup) Decrease(ctx context.Context, iids []string) ([]string,
 	return deleted, err
 }
 
-func (g *InstanceGroup) ConnectInfo(ctx context.Context, iid string) (provider.ConnectInfo, error) {
-	info := provider.ConnectInfo{ConnectorConfig: g.settings.ConnectorConfig}
+// logInstanceErrors logs the instance errors returned by the instance group.
+func (g *InstanceGroup) logInstanceErrors(msg string, err error) {
+	for _, instanceErr := range instancegroup.InstanceErrors(err) {
+		log := g.log.Error
+		if instanceErr.Cause == instancegroup.CauseTransient {
+			log = g.log.Warn
+		}
+		log(msg,
+			"name", instanceErr.Name,
+			"handler", instanceErr.Handler,
+			"cause", instanceErr.Cause,
+			"error", instanceErr.Err,
+		)
+	}
+}
+
+// defaultRetryBackoff is the delay before retrying the instances that failed with
+// transient errors.
+const defaultRetryBackoff = 5 * time.Second
+
+// backoff waits before retrying the instances, and returns false when the context is
+// done.
+func (g *InstanceGroup) backoff(ctx context.Context) bool {
+	timer := time.NewTimer(g.retryBackoff)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// splitRetryable splits the names of the instances worth retrying from the other
+// errors. Only the instances that failed with a single transient error are retried:
+// timeouts already waited for the instances, and an instance that also failed to be
+// cleaned up may have left its server behind.
+func splitRetryable(err error) ([]string, error) {
+	errs := flattenErrors(err)
+
+	counts := make(map[string]int, len(errs))
+	for _, instanceErr := range instancegroup.InstanceErrors(err) {
+		counts[instanceErr.Name]++
+	}
+
+	retry := make([]string, 0)
+	remaining := make([]error, 0)
+	for _, err := range errs {
+		var instanceErr *instancegroup.InstanceError
+		var timeoutErr *instancegroup.TimeoutError
+		if errors.As(err, &instanceErr) &&
+			instanceErr.Cause == instancegroup.CauseTransient &&
+			counts[instanceErr.Name] == 1 &&
+			!errors.As(err, &timeoutErr) {
+			retry = append(retry, instanceErr.Name)
+			continue
+		}
+		remaining = append(remaining, err)
+	}
+
+	return retry, errors.Join(remaining...)
+}
+
+// flattenErrors returns the errors joined in an error.
+func flattenErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		result := make([]error, 0)
+		for _, err := range joined.Unwrap() {
+			result = append(result, flattenErrors(err)...)
+		}
+		return result
+	}
+	return []error{err}
+}
+
+func (g *InstanceGroup) ConnectInfo(ctx context.Context, iid string) (_ provider.ConnectInfo, err error) {
+	ctx, span := tracer().Start(ctx, "ConnectInfo", trace.WithAttributes(attribute.String("iid", iid)))
+	defer func() { tracing.End(span, err) }()
 
 	instance, err := g.group.Get(ctx, iid)
 	if err != nil {
-		return info, fmt.Errorf("could not get instance: %w", err)
+		return provider.ConnectInfo{ConnectorConfig: g.settings.ConnectorConfig}, fmt.Errorf("could not get instance: %w", err)
+	}
+
+	info, err := g.connectInfo(instance)
+	if err != nil {
+		return info, err
+	}
+
+	if g.windowsEnabled() && info.Password == "" {
+		return info, fmt.Errorf("could not get instance password: %s", iid)
 	}
 
-	info.ID = iid
-	info.OS = instance.Server.Image.OSFlavor
+	return info, nil
+}
+
+// connectInfo returns the connection details of an instance.
+func (g *InstanceGroup) connectInfo(instance *instancegroup.Instance) (provider.ConnectInfo, error) {
+	info := provider.ConnectInfo{ConnectorConfig: g.settings.ConnectorConfig}
+
+	info.ID = instance.IID()
+
+	switch {
+	case g.OS != "":
+		info.OS = g.OS
+	case g.windowsEnabled():
+		info.OS = "windows"
+	case instance.Server.Image != nil:
+		info.OS = instance.Server.Image.OSFlavor
+	}
+
+	// The generated passwords are only known by the plugin that created the instances,
+	// fall back to the static password otherwise.
+	if g.windowsEnabled() && instance.Password != "" {
+		info.Password = instance.Password
+	}
 
 	switch instance.Server.ServerType.Architecture {
 	case hcloud.ArchitectureX86:
@@ -253,22 +598,67 @@ func (g *InstanceGroup) ConnectInfo(ctx context.Context, iid string) (provider.C
 		info.InternalAddr = instance.Server.PrivateNet[0].IP.String()
 	}
 
-	return info, err
+	return info, nil
+}
+
+// windowsEnabled reports whether the instances are Windows servers reached using WinRM.
+func (g *InstanceGroup) windowsEnabled() bool {
+	return g.settings.Protocol == provider.ProtocolWinRM || g.settings.Protocol == provider.ProtocolWinRMHttps
 }
 
-func (g *InstanceGroup) Heartbeat(_ context.Context, _ string) error {
-	// no-op
+func (g *InstanceGroup) Heartbeat(ctx context.Context, iid string) (err error) {
+	ctx, span := tracer().Start(ctx, "Heartbeat", trace.WithAttributes(attribute.String("iid", iid)))
+	defer func() { tracing.End(span, err) }()
+
+	// Without the instances cache, the heartbeats would use the API rate limit.
+	if g.InstanceCacheTTL == 0 {
+		return nil
+	}
+
+	instance, err := g.group.Get(ctx, iid)
+	if errors.Is(err, instancegroup.ErrInstanceNotFound) {
+		return err
+	}
+	if err != nil {
+		// The instance is only unhealthy when it is gone, not when the API fails.
+		g.log.Warn("could not check instance heartbeat", "iid", iid, "error", err)
+		return nil
+	}
+
+	if g.instanceState(instance) == provider.StateDeleting {
+		return fmt.Errorf("instance is being deleted: %s", iid)
+	}
+
 	return nil
 }
 
 func (g *InstanceGroup) Shutdown(ctx context.Context) error {
 	errs := make([]error, 0)
 
+	// The increases tracked in the background still record events and spans.
+	if g.group != nil {
+		if err := g.group.Wait(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
 	if g.sshKey != nil {
-		g.log.Debug("deleting ssh key", "id", fmt.Sprint(g.sshKey.ID))
-		_, err := g.client.SSHKey.Delete(ctx, g.sshKey)
+		g.log.Debug("releasing ssh key", "id", fmt.Sprint(g.sshKey.ID))
+		deleted, err := instancegroup.ReleaseSSHKey(ctx, g.client, g.sshKey, g.sshKeyUserLabel())
 		if err != nil {
 			errs = append(errs, err)
+		} else if !deleted {
+			g.log.Info("keeping ssh key used by other instance groups", "name", g.sshKey.Name)
+		}
+	}
+
+	if err := g.events.Close(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if g.shutdownTracing != nil {
+		if err := g.shutdownTracing(ctx); err != nil {
+			errs = append(errs, err)
 		}
 	}
 